@@ -1,45 +1,280 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
 
 	"github.com/joho/godotenv"
+	"github.com/rbungay/racedatabase-api/internal/api/router"
 	"github.com/rbungay/racedatabase-api/internal/api/runsignup/services"
+	"github.com/rbungay/racedatabase-api/internal/api/runsignup/storage"
+)
+
+// The connection pool's defaults, sized for Supabase's connection pooler
+const (
+	defaultDBMaxOpenConns    = 10
+	defaultDBMaxIdleConns    = 5
+	defaultDBConnMaxLifetime = 30 * time.Minute
+	defaultDBConnMaxIdleTime = 5 * time.Minute
+)
+
+const (
+	defaultShutdownTimeout = 30 * time.Second
+	readHeaderTimeout      = 10 * time.Second
+	defaultCORSMaxAge      = 10 * time.Minute
 )
 
 func main() {
+	// Logging is configured from the environment, so .env is read first and
+	// reported once logging is set up
 	err := godotenv.Load()
+	setupLogging()
 	if err != nil {
 		log.Println("Warning: No .env file found, using system env variables.")
 	} else {
 		log.Println(".env file loaded successfully.")
 	}
 
+	supabaseStorage, err := openStorage()
+	if err != nil {
+		log.Fatalf("Failed to connect to Supabase: %v", err)
+	}
+	if supabaseStorage != nil {
+		defer supabaseStorage.Close()
+	}
+
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "serve":
+			serve(supabaseStorage)
+			return
+		case "worker":
+			worker(supabaseStorage)
+			return
+		case "sync":
+			if err := syncCommand(os.Args[2:]); err != nil {
+				log.Fatalf("Sync failed: %v", err)
+			}
+			return
+		case "retry-failed":
+			if err := retryFailedCommand(os.Args[2:]); err != nil {
+				log.Fatalf("Retry failed: %v", err)
+			}
+			return
+		}
+	}
+
 	// Test fetch for New Jersey races
 	events, err := services.FetchEvents("NJ", "", "", "", "", "", "", "", "")
 	if err != nil {
 		log.Fatalf("Error fetching events: %v", err)
 	}
 	fmt.Printf("Successfully fetched %d events from New Jersey\n", len(events))
+}
 
-	// Comment out existing server code
-	/*
-	http.HandleFunc("/runsignup/events", handlers.RunSignupEventsHandler)
+// setupLogging makes structured logging the default, as JSON when
+// LOG_FORMAT is json and as text otherwise. Output from the log package goes
+// through it too.
+func setupLogging() {
+	var handler slog.Handler = slog.NewTextHandler(os.Stderr, nil)
+	if os.Getenv("LOG_FORMAT") == "json" {
+		handler = slog.NewJSONHandler(os.Stderr, nil)
+	}
+	slog.SetDefault(slog.New(handler))
+}
+
+// openStorage connects to SUPABASE_DB_URL with a pool sized by the DB_*
+// variables, publishes the pool's counters and hands the connection to the
+// services. It returns nil when SUPABASE_DB_URL is not set.
+func openStorage() (*storage.SupabaseStorage, error) {
+	dbURL := os.Getenv("SUPABASE_DB_URL")
+	if dbURL == "" {
+		return nil, nil
+	}
 
-	http.HandleFunc("/runsignup/race/", handlers.RunSignupRaceDetailsHandler(services.FetchRaceDetails))
+	var pool storage.PoolConfig
+	var err error
+	if pool.MaxOpenConns, err = intEnv("DB_MAX_OPEN_CONNS", defaultDBMaxOpenConns); err != nil {
+		return nil, err
+	}
+	if pool.MaxIdleConns, err = intEnv("DB_MAX_IDLE_CONNS", defaultDBMaxIdleConns); err != nil {
+		return nil, err
+	}
+	if pool.ConnMaxLifetime, err = durationEnv("DB_CONN_MAX_LIFETIME", defaultDBConnMaxLifetime); err != nil {
+		return nil, err
+	}
+	if pool.ConnMaxIdleTime, err = durationEnv("DB_CONN_MAX_IDLE_TIME", defaultDBConnMaxIdleTime); err != nil {
+		return nil, err
+	}
 
+	supabaseStorage, err := storage.NewSupabaseStorage(dbURL, pool)
+	if err != nil {
+		return nil, err
+	}
+	supabaseStorage.Publish("db_pool")
+	services.UseStorage(supabaseStorage)
+	return supabaseStorage, nil
+}
+
+// serve runs the HTTP API until SIGINT or SIGTERM. The stored race routes
+// are only served with a database, in which case the states in SYNC_STATES
+// are also synced in the background, and the admin routes are served when
+// ADMIN_TOKEN is set too.
+//
+// With a database every route but the docs needs an API key, unless
+// REQUIRE_API_KEYS is false. Keys are issued through the admin routes.
+//
+// On a signal the server stops accepting connections and gives in-flight
+// requests and sync jobs SHUTDOWN_TIMEOUT to finish. Sync jobs stop at the
+// next race and are left interrupted, to be resumed later.
+func serve(supabaseStorage *storage.SupabaseStorage) {
+	shutdownTimeout, err := durationEnv("SHUTDOWN_TIMEOUT", defaultShutdownTimeout)
+	if err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
+	}
+	corsMaxAge, err := durationEnv("CORS_MAX_AGE", defaultCORSMaxAge)
+	if err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
+	}
+	requireKeys, err := boolEnv("REQUIRE_API_KEYS", supabaseStorage != nil)
+	if err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
+	}
+	if requireKeys && supabaseStorage == nil {
+		log.Fatal("REQUIRE_API_KEYS needs SUPABASE_DB_URL to check keys against")
+	}
+	// Without an admin token no key could ever be issued, so every request
+	// would be refused
+	adminToken := os.Getenv("ADMIN_TOKEN")
+	if requireKeys && adminToken == "" {
+		log.Fatal("REQUIRE_API_KEYS needs ADMIN_TOKEN to issue keys with; set it or set REQUIRE_API_KEYS=false")
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	var (
+		store      router.RaceStore
+		syncs      *services.SyncManager
+		keys       *services.APIKeyManager
+		background sync.WaitGroup
+	)
+	if supabaseStorage != nil {
+		store = supabaseStorage
+		syncs = services.NewSyncManager(ctx, supabaseStorage)
+		keys = services.NewAPIKeyManager(supabaseStorage)
+
+		syncScheduler, err := newSyncScheduler(supabaseStorage)
+		if err != nil {
+			log.Fatalf("Invalid sync configuration: %v", err)
+		}
+		if syncScheduler != nil {
+			background.Add(1)
+			go func() {
+				defer background.Done()
+				syncScheduler.Start(ctx)
+			}()
+		}
+	} else {
+		log.Println("Warning: SUPABASE_DB_URL is not set, stored race routes are disabled.")
+	}
 
 	port := os.Getenv("PORT")
 	if port == "" {
 		port = "8080"
 	}
 
+	var authenticate func(string) error
+	if requireKeys {
+		authenticate = keys.Authenticate
+	} else {
+		log.Println("Warning: API keys are not required, the API is open to anyone.")
+	}
 
+	mux := router.New(services.FetchRaceDetails, store, authenticate)
+	if adminToken != "" && syncs != nil {
+		router.RegisterAdmin(mux, adminToken, syncs, keys)
+	}
+
+	handler := router.Wrap(mux, router.Options{
+		Logger: slog.Default(),
+		CORS:   router.CORSConfig(splitList(os.Getenv("CORS_ALLOWED_ORIGINS")), corsMaxAge),
+	})
+
+	server := &http.Server{
+		Addr:              ":" + port,
+		Handler:           handler,
+		ReadHeaderTimeout: readHeaderTimeout,
+	}
+	served := make(chan error, 1)
+	go func() {
+		served <- server.ListenAndServe()
+	}()
 	fmt.Println("Server is running on http://localhost:" + port)
-	err = http.ListenAndServe(":"+port, nil)
-	if err != nil {
+
+	select {
+	case err := <-served:
 		log.Fatalf("Failed to start server: %v", err)
+	case <-ctx.Done():
 	}
-	*/
+	// A second signal kills the process
+	stop()
+	log.Printf("Shutting down, waiting up to %v for in-flight work", shutdownTimeout)
+
+	deadline, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	if syncs != nil {
+		background.Add(1)
+		go func() {
+			defer background.Done()
+			syncs.Wait()
+		}()
+	}
+	if err := server.Shutdown(deadline); err != nil {
+		log.Printf("Failed to drain in-flight requests: %v", err)
+	}
+
+	drained := make(chan struct{})
+	go func() {
+		background.Wait()
+		close(drained)
+	}()
+	select {
+	case <-drained:
+		log.Println("Server stopped")
+	case <-deadline.Done():
+		log.Println("Gave up waiting for sync jobs, run `sync -resume` to finish them")
+	}
+}
+
+// worker only runs the scheduled syncs of the states in SYNC_STATES, until
+// interrupted
+func worker(supabaseStorage *storage.SupabaseStorage) {
+	if supabaseStorage == nil {
+		log.Fatal("SUPABASE_DB_URL must be set to run the sync worker")
+	}
+
+	syncScheduler, err := newSyncScheduler(supabaseStorage)
+	if err != nil {
+		log.Fatalf("Invalid sync configuration: %v", err)
+	}
+	if syncScheduler == nil {
+		log.Fatal("SYNC_STATES must list at least one state to sync")
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	log.Println("Sync worker started")
+	syncScheduler.Start(ctx)
+	log.Println("Sync worker stopped")
 }
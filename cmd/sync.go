@@ -0,0 +1,224 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/rbungay/racedatabase-api/internal/api/runsignup/constants"
+	"github.com/rbungay/racedatabase-api/internal/api/runsignup/models"
+	"github.com/rbungay/racedatabase-api/internal/api/runsignup/services"
+	"github.com/rbungay/racedatabase-api/internal/scheduler"
+)
+
+const (
+	defaultSyncSchedule   = "0 */6 * * *"
+	defaultSyncJitter     = 5 * time.Minute
+	defaultSyncStaleAfter = 2 * time.Hour
+	defaultRetrySchedule  = "*/15 * * * *"
+)
+
+// syncCommand runs a one-off sync job, or resumes the last unfinished one
+// with -resume:
+//
+//	go run ./cmd sync [-mode auto|full|incremental] [-event-types a,b] STATE...
+//	go run ./cmd sync -resume
+func syncCommand(args []string) error {
+	flags := flag.NewFlagSet("sync", flag.ExitOnError)
+	resume := flags.Bool("resume", false, "continue the last unfinished sync job from its checkpoints")
+	modeFlag := flags.String("mode", "auto", "auto, full or incremental")
+	eventTypesFlag := flags.String("event-types", "", "comma separated event types (default all)")
+	flags.Parse(args)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	var (
+		job *models.SyncJob
+		err error
+	)
+	if *resume {
+		job, err = services.ResumeSync(ctx)
+		if job == nil && err == nil {
+			fmt.Println("No unfinished sync job to resume")
+			return nil
+		}
+	} else {
+		mode, parseErr := services.ParseSyncMode(*modeFlag)
+		if parseErr != nil {
+			return parseErr
+		}
+		if flags.NArg() == 0 {
+			return fmt.Errorf("usage: sync [-mode auto|full|incremental] [-event-types a,b] STATE... | sync -resume")
+		}
+		job, err = services.RunSync(ctx, flags.Args(), syncEventTypes(*eventTypesFlag), mode)
+	}
+
+	if job != nil {
+		fmt.Printf("Sync job %d %s: %d races fetched, %d saved, %d failed\n",
+			job.ID, job.Status, job.RacesFetched, job.RacesSaved, job.RacesFailed)
+		if job.Status == models.SyncJobInterrupted {
+			fmt.Println("Run `sync -resume` to continue it")
+		}
+	}
+	return err
+}
+
+// retryFailedCommand retries the failed races whose backoff has elapsed:
+//
+//	go run ./cmd retry-failed [-limit 100]
+func retryFailedCommand(args []string) error {
+	flags := flag.NewFlagSet("retry-failed", flag.ExitOnError)
+	limit := flags.Int("limit", 100, "maximum number of races to retry")
+	flags.Parse(args)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	result, err := services.RetryFailedIngests(ctx, *limit)
+	if result != nil {
+		fmt.Printf("Retried %d failed races: %d recovered, %d failed again\n", result.Retried, result.Recovered, result.Failed)
+	}
+	return err
+}
+
+// syncEventTypes reads a comma separated list of event types, defaulting to
+// all of them
+func syncEventTypes(value string) []string {
+	eventTypes := splitList(value)
+	if len(eventTypes) == 0 {
+		for eventType := range constants.ValidEventTypes {
+			eventTypes = append(eventTypes, eventType)
+		}
+		sort.Strings(eventTypes)
+	}
+	return eventTypes
+}
+
+// newSyncScheduler builds a scheduler with one job per state in SYNC_STATES.
+// Each state runs on SYNC_SCHEDULE_<STATE>, or SYNC_SCHEDULE, and syncs the
+// event types in SYNC_EVENT_TYPES, or all of them. Failed races are retried
+// on FAILED_INGEST_RETRY_SCHEDULE. It returns nil when no states are
+// configured.
+func newSyncScheduler(store scheduler.RunStore) (*scheduler.Scheduler, error) {
+	states := splitList(os.Getenv("SYNC_STATES"))
+	if len(states) == 0 {
+		return nil, nil
+	}
+
+	eventTypes := syncEventTypes(os.Getenv("SYNC_EVENT_TYPES"))
+	for _, eventType := range eventTypes {
+		if !constants.ValidEventTypes[eventType] {
+			return nil, fmt.Errorf("SYNC_EVENT_TYPES: invalid event type %q", eventType)
+		}
+	}
+
+	jitter, err := durationEnv("SYNC_JITTER", defaultSyncJitter)
+	if err != nil {
+		return nil, err
+	}
+	staleAfter, err := durationEnv("SYNC_STALE_AFTER", defaultSyncStaleAfter)
+	if err != nil {
+		return nil, err
+	}
+
+	retrySpec := os.Getenv("FAILED_INGEST_RETRY_SCHEDULE")
+	if retrySpec == "" {
+		retrySpec = defaultRetrySchedule
+	}
+	retrySchedule, err := scheduler.Parse(retrySpec)
+	if err != nil {
+		return nil, fmt.Errorf("FAILED_INGEST_RETRY_SCHEDULE: %w", err)
+	}
+
+	s := scheduler.New(store, staleAfter)
+	s.Add(scheduler.Job{
+		Name:     "retry-failed-ingest",
+		Schedule: retrySchedule,
+		Jitter:   jitter,
+		Run: func(ctx context.Context) (int, error) {
+			result, err := services.RetryFailedIngests(ctx, 100)
+			if result == nil {
+				return 0, err
+			}
+			return result.Recovered, err
+		},
+	})
+	for _, state := range states {
+		state := strings.ToUpper(state)
+		spec := os.Getenv("SYNC_SCHEDULE_" + state)
+		if spec == "" {
+			spec = os.Getenv("SYNC_SCHEDULE")
+		}
+		if spec == "" {
+			spec = defaultSyncSchedule
+		}
+		schedule, err := scheduler.Parse(spec)
+		if err != nil {
+			return nil, fmt.Errorf("schedule for %s: %w", state, err)
+		}
+
+		s.Add(scheduler.Job{
+			Name:     "sync:" + state,
+			Schedule: schedule,
+			Jitter:   jitter,
+			Run: func(ctx context.Context) (int, error) {
+				return services.SyncState(ctx, state, eventTypes, services.SyncAuto)
+			},
+		})
+	}
+	return s, nil
+}
+
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func intEnv(key string, fallback int) (int, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("%s: invalid number %q", key, value)
+	}
+	return n, nil
+}
+
+func boolEnv(key string, fallback bool) (bool, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback, nil
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("%s: invalid boolean %q", key, value)
+	}
+	return b, nil
+}
+
+func durationEnv(key string, fallback time.Duration) (time.Duration, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("%s: invalid duration %q", key, value)
+	}
+	return d, nil
+}
@@ -0,0 +1,33 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAccessLog(t *testing.T) {
+	var logs bytes.Buffer
+	mux := http.NewServeMux()
+	mux.Handle("GET /v1/races/{id}", jsonHandler(http.StatusNotFound, `{"code":"not_found"}`))
+	handler := AccessLog(slog.New(slog.NewJSONHandler(&logs, nil)), mux)
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/v1/races/42", nil))
+
+	var record map[string]any
+	if err := json.Unmarshal(logs.Bytes(), &record); err != nil {
+		t.Fatalf("Expected one JSON record, got %q", logs.String())
+	}
+	if record["method"] != "GET" || record["path"] != "/v1/races/42" || record["route"] != "GET /v1/races/{id}" {
+		t.Errorf("Unexpected request attributes: %v", record)
+	}
+	if record["status"] != float64(http.StatusNotFound) || record["bytes"] != float64(len(`{"code":"not_found"}`)) {
+		t.Errorf("Unexpected response attributes: %v", record)
+	}
+	if record["level"] != "INFO" {
+		t.Errorf("Expected a client error to be logged at info level, got %v", record["level"])
+	}
+}
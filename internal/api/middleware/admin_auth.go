@@ -0,0 +1,25 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	apperrors "github.com/rbungay/racedatabase-api/pkg/errors"
+)
+
+// RequireToken only lets through requests carrying token as a bearer
+// credential. Responses are never cached, since they are for one client.
+func RequireToken(token string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "no-store")
+
+		given, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || token == "" || subtle.ConstantTimeCompare([]byte(given), []byte(token)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="admin"`)
+			apperrors.WriteProblem(w, r, apperrors.Unauthenticated("A valid admin token is required."))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,54 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRequireToken(t *testing.T) {
+	handler := RequireToken("s3cret", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+
+	tests := []struct {
+		authorization string
+		want          int
+	}{
+		{"Bearer s3cret", http.StatusOK},
+		{"Bearer wrong", http.StatusUnauthorized},
+		{"s3cret", http.StatusUnauthorized},
+		{"", http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", "/admin/sync/1", nil)
+		if tt.authorization != "" {
+			req.Header.Set("Authorization", tt.authorization)
+		}
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+
+		if rr.Code != tt.want {
+			t.Errorf("Authorization %q: got status %d, want %d", tt.authorization, rr.Code, tt.want)
+		}
+		if rr.Header().Get("Cache-Control") != "no-store" {
+			t.Errorf("Authorization %q: admin responses should not be cached", tt.authorization)
+		}
+		if rr.Code == http.StatusUnauthorized && rr.Header().Get("WWW-Authenticate") == "" {
+			t.Errorf("Authorization %q: expected a WWW-Authenticate challenge", tt.authorization)
+		}
+	}
+}
+
+func TestRequireToken_EmptyTokenRejectsEverything(t *testing.T) {
+	handler := RequireToken("", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	req := httptest.NewRequest("GET", "/admin/sync/1", nil)
+	req.Header.Set("Authorization", "Bearer ")
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusUnauthorized {
+		t.Errorf("Expected an unset token to reject requests, got %d", rr.Code)
+	}
+}
@@ -0,0 +1,28 @@
+package middleware
+
+import (
+	"net/http"
+
+	apperrors "github.com/rbungay/racedatabase-api/pkg/errors"
+)
+
+// APIKeyHeader is the request header API keys are sent in
+const APIKeyHeader = "X-API-Key"
+
+// RequireAPIKey only lets through requests whose key authenticate accepts.
+// Responses vary by key, so shared caches keep one copy per client rather
+// than serving one client's response to another.
+func RequireAPIKey(authenticate func(key string) error, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", APIKeyHeader)
+
+		if err := authenticate(r.Header.Get(APIKeyHeader)); err != nil {
+			if apperrors.KindOf(err) == apperrors.KindUnauthenticated {
+				w.Header().Set("WWW-Authenticate", `APIKey realm="api", header="`+APIKeyHeader+`"`)
+			}
+			apperrors.WriteProblem(w, r, err)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
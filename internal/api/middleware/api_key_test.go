@@ -0,0 +1,65 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	apperrors "github.com/rbungay/racedatabase-api/pkg/errors"
+)
+
+func TestRequireAPIKey(t *testing.T) {
+	authenticate := func(key string) error {
+		switch key {
+		case "good":
+			return nil
+		case "busy":
+			return apperrors.RateLimited(nil, 30*time.Second)
+		case "broken":
+			return errors.New("database is down")
+		}
+		return apperrors.Unauthenticated("Invalid API key.")
+	}
+	handler := RequireAPIKey(authenticate, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+
+	tests := []struct {
+		key  string
+		want int
+	}{
+		{"good", http.StatusOK},
+		{"", http.StatusUnauthorized},
+		{"wrong", http.StatusUnauthorized},
+		{"busy", http.StatusTooManyRequests},
+		{"broken", http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", "/v1/races", nil)
+		if tt.key != "" {
+			req.Header.Set(APIKeyHeader, tt.key)
+		}
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+
+		if rr.Code != tt.want {
+			t.Errorf("Key %q: got status %d, want %d", tt.key, rr.Code, tt.want)
+		}
+		if rr.Header().Get("Vary") != APIKeyHeader {
+			t.Errorf("Key %q: got Vary %q, want %q", tt.key, rr.Header().Get("Vary"), APIKeyHeader)
+		}
+		if challenged := rr.Header().Get("WWW-Authenticate") != ""; challenged != (tt.want == http.StatusUnauthorized) {
+			t.Errorf("Key %q: got WWW-Authenticate %q", tt.key, rr.Header().Get("WWW-Authenticate"))
+		}
+	}
+
+	req := httptest.NewRequest("GET", "/v1/races", nil)
+	req.Header.Set(APIKeyHeader, "busy")
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	if got := rr.Header().Get("Retry-After"); got != "30" {
+		t.Errorf("Rate limited response has Retry-After %q, want 30", got)
+	}
+}
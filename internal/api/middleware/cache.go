@@ -0,0 +1,101 @@
+// Package middleware holds HTTP middleware shared by the API's routes.
+package middleware
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// PartialResultsHeader marks responses that are missing results; they are
+// never cached. It matches handlers.PartialResultsHeader.
+const PartialResultsHeader = "X-Partial-Results"
+
+// bufferedResponse holds a handler's status and body so headers can be
+// added once the whole payload is known
+type bufferedResponse struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (b *bufferedResponse) WriteHeader(status int) {
+	if b.status == 0 {
+		b.status = status
+	}
+}
+
+func (b *bufferedResponse) Write(p []byte) (int, error) {
+	if b.status == 0 {
+		b.status = http.StatusOK
+	}
+	return b.body.Write(p)
+}
+
+// Cache sets a strong ETag computed from the response body and a
+// Cache-Control header allowing shared caches to keep successful responses
+// for maxAge, and answers a matching If-None-Match with 304 Not Modified.
+// Error and partial responses are passed through marked no-store.
+func Cache(maxAge time.Duration, next http.Handler) http.Handler {
+	return cache(fmt.Sprintf("public, max-age=%d", int(maxAge.Seconds())), next)
+}
+
+// PrivateCache is Cache for routes behind API key auth. Responses are marked
+// private so shared caches never hand one key's response to another client.
+func PrivateCache(maxAge time.Duration, next http.Handler) http.Handler {
+	return cache(fmt.Sprintf("private, max-age=%d", int(maxAge.Seconds())), next)
+}
+
+func cache(cacheControl string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		buffered := &bufferedResponse{ResponseWriter: w}
+		next.ServeHTTP(buffered, r)
+		if buffered.status == 0 {
+			buffered.status = http.StatusOK
+		}
+
+		if buffered.status != http.StatusOK || w.Header().Get(PartialResultsHeader) != "" {
+			w.Header().Set("Cache-Control", "no-store")
+			w.WriteHeader(buffered.status)
+			w.Write(buffered.body.Bytes())
+			return
+		}
+
+		etag := ETag(buffered.body.Bytes())
+		w.Header().Set("ETag", etag)
+		w.Header().Set("Cache-Control", cacheControl)
+
+		if ifNoneMatch := r.Header.Get("If-None-Match"); ifNoneMatch != "" && etagMatches(ifNoneMatch, etag) {
+			// A 304 carries no body, so drop the headers that describe one
+			w.Header().Del("Content-Type")
+			w.Header().Del("Content-Length")
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+
+		w.WriteHeader(buffered.status)
+		w.Write(buffered.body.Bytes())
+	})
+}
+
+// ETag returns a strong entity tag for a response body
+func ETag(body []byte) string {
+	sum := sha256.Sum256(body)
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// etagMatches reports whether an If-None-Match header matches etag, using
+// the weak comparison RFC 9110 requires for If-None-Match
+func etagMatches(ifNoneMatch, etag string) bool {
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
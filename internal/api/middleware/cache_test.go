@@ -0,0 +1,129 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func jsonHandler(status int, body string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		w.Write([]byte(body))
+	})
+}
+
+func get(t *testing.T, handler http.Handler, ifNoneMatch string) *httptest.ResponseRecorder {
+	t.Helper()
+	req, err := http.NewRequest("GET", "/v1/events?state=NJ", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ifNoneMatch != "" {
+		req.Header.Set("If-None-Match", ifNoneMatch)
+	}
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	return rr
+}
+
+func TestCache_SetsETagAndCacheControl(t *testing.T) {
+	handler := Cache(5*time.Minute, jsonHandler(http.StatusOK, `{"events":[]}`))
+
+	rr := get(t, handler, "")
+	if rr.Code != http.StatusOK {
+		t.Errorf("Handler returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+	}
+	if got := rr.Header().Get("ETag"); got != ETag([]byte(`{"events":[]}`)) {
+		t.Errorf("Unexpected ETag: %v", got)
+	}
+	if got := rr.Header().Get("Cache-Control"); got != "public, max-age=300" {
+		t.Errorf("Unexpected Cache-Control: %v", got)
+	}
+	if rr.Body.String() != `{"events":[]}` {
+		t.Errorf("Unexpected response body: %v", rr.Body.String())
+	}
+}
+
+func TestPrivateCache(t *testing.T) {
+	handler := PrivateCache(5*time.Minute, jsonHandler(http.StatusOK, `{"events":[]}`))
+
+	rr := get(t, handler, "")
+	if got := rr.Header().Get("Cache-Control"); got != "private, max-age=300" {
+		t.Errorf("Unexpected Cache-Control: %v", got)
+	}
+	if rr.Header().Get("ETag") == "" {
+		t.Errorf("Expected an ETag")
+	}
+	if got := get(t, handler, rr.Header().Get("ETag")).Code; got != http.StatusNotModified {
+		t.Errorf("Handler returned wrong status code: got %v want %v", got, http.StatusNotModified)
+	}
+}
+
+func TestCache_NotModified(t *testing.T) {
+	handler := Cache(time.Minute, jsonHandler(http.StatusOK, `{"events":[]}`))
+	etag := get(t, handler, "").Header().Get("ETag")
+
+	tests := []struct {
+		ifNoneMatch string
+		status      int
+	}{
+		{etag, http.StatusNotModified},
+		{"W/" + etag, http.StatusNotModified},
+		{`"other", ` + etag, http.StatusNotModified},
+		{"*", http.StatusNotModified},
+		{`"other"`, http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		rr := get(t, handler, tt.ifNoneMatch)
+		if rr.Code != tt.status {
+			t.Errorf("If-None-Match %s: got status %v want %v", tt.ifNoneMatch, rr.Code, tt.status)
+		}
+		if tt.status == http.StatusNotModified {
+			if rr.Body.Len() != 0 {
+				t.Errorf("If-None-Match %s: 304 should have no body, got %q", tt.ifNoneMatch, rr.Body.String())
+			}
+			if rr.Header().Get("ETag") != etag {
+				t.Errorf("If-None-Match %s: 304 should repeat the ETag", tt.ifNoneMatch)
+			}
+		}
+	}
+}
+
+func TestCache_ETagChangesWithPayload(t *testing.T) {
+	first := get(t, Cache(time.Minute, jsonHandler(http.StatusOK, `{"events":[1]}`)), "").Header().Get("ETag")
+	second := get(t, Cache(time.Minute, jsonHandler(http.StatusOK, `{"events":[2]}`)), first)
+
+	if second.Code != http.StatusOK {
+		t.Errorf("A changed payload should not be Not Modified: got %v", second.Code)
+	}
+	if second.Header().Get("ETag") == first {
+		t.Errorf("Expected a new ETag for a changed payload")
+	}
+}
+
+func TestCache_SkipsErrorsAndPartialResults(t *testing.T) {
+	partial := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(PartialResultsHeader, "true")
+		w.Write([]byte(`{"events":[],"warnings":[]}`))
+	})
+
+	for name, handler := range map[string]http.Handler{
+		"error":   Cache(time.Minute, jsonHandler(http.StatusBadGateway, `{"code":"upstream_unavailable"}`)),
+		"partial": Cache(time.Minute, partial),
+	} {
+		rr := get(t, handler, "*")
+		if rr.Code == http.StatusNotModified {
+			t.Errorf("%s: response should not be Not Modified", name)
+		}
+		if rr.Header().Get("ETag") != "" {
+			t.Errorf("%s: response should not have an ETag", name)
+		}
+		if got := rr.Header().Get("Cache-Control"); got != "no-store" {
+			t.Errorf("%s: unexpected Cache-Control: %v", name, got)
+		}
+	}
+}
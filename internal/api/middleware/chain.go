@@ -0,0 +1,14 @@
+package middleware
+
+import "net/http"
+
+// Middleware wraps a handler with behaviour shared by many routes
+type Middleware func(http.Handler) http.Handler
+
+// Chain wraps h in middlewares, the first of them outermost
+func Chain(h http.Handler, middlewares ...Middleware) http.Handler {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		h = middlewares[i](h)
+	}
+	return h
+}
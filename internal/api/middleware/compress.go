@@ -0,0 +1,185 @@
+package middleware
+
+import (
+	"compress/gzip"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/andybalholm/brotli"
+)
+
+// compressMinSize is the smallest body worth compressing
+const compressMinSize = 1024
+
+// encoders are the content codings Compress produces, most preferred first
+var encoders = []struct {
+	name string
+	pool *sync.Pool
+}{
+	{"br", &sync.Pool{New: func() any { return brotli.NewWriterLevel(nil, 4) }}},
+	{"gzip", &sync.Pool{New: func() any { return gzip.NewWriter(nil) }}},
+}
+
+type resettableWriter interface {
+	io.WriteCloser
+	Reset(io.Writer)
+}
+
+// compressibleTypes are the media types worth compressing
+var compressibleTypes = map[string]bool{
+	"application/json":         true,
+	"application/problem+json": true,
+	"application/javascript":   true,
+	"image/svg+xml":            true,
+}
+
+func compressible(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	return strings.HasPrefix(mediaType, "text/") || compressibleTypes[mediaType]
+}
+
+// negotiateEncoding picks the preferred encoder acceptable to an
+// Accept-Encoding header, or -1 when none is
+func negotiateEncoding(acceptEncoding string) int {
+	best, bestQ := -1, 0.0
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		coding, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		q := 1.0
+		if value, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			var err error
+			if q, err = strconv.ParseFloat(value, 64); err != nil {
+				continue
+			}
+		}
+		for i, encoder := range encoders {
+			if strings.EqualFold(coding, encoder.name) || coding == "*" {
+				if q > bestQ || (q == bestQ && q > 0 && i < best) {
+					best, bestQ = i, q
+				}
+			}
+		}
+	}
+	return best
+}
+
+// compressWriter holds back the start of a response until it knows whether
+// the body is big enough to compress
+type compressWriter struct {
+	http.ResponseWriter
+	encoder int
+	status  int
+	buf     []byte
+	started bool
+	writer  resettableWriter
+}
+
+func (c *compressWriter) WriteHeader(status int) {
+	if c.status == 0 {
+		c.status = status
+	}
+}
+
+func (c *compressWriter) Write(p []byte) (int, error) {
+	if c.status == 0 {
+		c.status = http.StatusOK
+	}
+	if !c.started {
+		c.buf = append(c.buf, p...)
+		if len(c.buf) < compressMinSize {
+			return len(p), nil
+		}
+		if err := c.start(); err != nil {
+			return 0, err
+		}
+		return len(p), nil
+	}
+	if c.writer != nil {
+		return c.writer.Write(p)
+	}
+	return c.ResponseWriter.Write(p)
+}
+
+func (c *compressWriter) Unwrap() http.ResponseWriter {
+	return c.ResponseWriter
+}
+
+// start sends the headers, compressing the body if it is big enough and of
+// a compressible type, then whatever was held back
+func (c *compressWriter) start() error {
+	c.started = true
+	header := c.Header()
+	if header.Get("Content-Type") == "" && len(c.buf) > 0 {
+		header.Set("Content-Type", http.DetectContentType(c.buf))
+	}
+
+	if len(c.buf) >= compressMinSize && header.Get("Content-Encoding") == "" && compressible(header.Get("Content-Type")) {
+		header.Set("Content-Encoding", encoders[c.encoder].name)
+		header.Del("Content-Length")
+		// The body's bytes differ per encoding, so a strong ETag of the
+		// identity body no longer applies to them
+		if etag := header.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+			header.Set("ETag", "W/"+etag)
+		}
+		c.writer = encoders[c.encoder].pool.Get().(resettableWriter)
+		c.writer.Reset(c.ResponseWriter)
+	}
+
+	if c.status == 0 {
+		c.status = http.StatusOK
+	}
+	c.ResponseWriter.WriteHeader(c.status)
+	if len(c.buf) == 0 {
+		return nil
+	}
+	var err error
+	if c.writer != nil {
+		_, err = c.writer.Write(c.buf)
+	} else {
+		_, err = c.ResponseWriter.Write(c.buf)
+	}
+	c.buf = nil
+	return err
+}
+
+// close flushes the response, finishing the compressed stream
+func (c *compressWriter) close() {
+	if !c.started {
+		if c.status == 0 && len(c.buf) == 0 {
+			return
+		}
+		c.start()
+	}
+	if c.writer != nil {
+		c.writer.Close()
+		c.writer.Reset(nil)
+		encoders[c.encoder].pool.Put(c.writer)
+	}
+}
+
+// Compress encodes responses of at least 1 KiB with brotli or gzip,
+// whichever the client prefers. Small bodies, responses that are already
+// encoded and types such as images are sent as they are.
+func Compress(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Accept-Encoding")
+
+		encoder := negotiateEncoding(r.Header.Get("Accept-Encoding"))
+		if encoder < 0 || r.Method == http.MethodHead {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		cw := &compressWriter{ResponseWriter: w, encoder: encoder}
+		next.ServeHTTP(cw, r)
+		// Not deferred: after a panic the held back response is dropped so
+		// Recover can still answer with an error
+		cw.close()
+	})
+}
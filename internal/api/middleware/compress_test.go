@@ -0,0 +1,101 @@
+package middleware
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/andybalholm/brotli"
+)
+
+var largeBody = `{"events":[` + strings.Repeat(`{"name":"5K Run"},`, 200) + `{}]}`
+
+func compressedGet(t *testing.T, handler http.Handler, acceptEncoding string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest("GET", "/v1/events", nil)
+	if acceptEncoding != "" {
+		req.Header.Set("Accept-Encoding", acceptEncoding)
+	}
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	return rr
+}
+
+func TestCompress_Gzip(t *testing.T) {
+	rr := compressedGet(t, Compress(jsonHandler(http.StatusOK, largeBody)), "gzip")
+
+	if got := rr.Header().Get("Content-Encoding"); got != "gzip" {
+		t.Fatalf("Unexpected Content-Encoding: %q", got)
+	}
+	reader, err := gzip.NewReader(rr.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(reader)
+	if string(body) != largeBody {
+		t.Errorf("Decompressed body does not match")
+	}
+}
+
+func TestCompress_PrefersBrotli(t *testing.T) {
+	rr := compressedGet(t, Compress(jsonHandler(http.StatusOK, largeBody)), "gzip, deflate, br")
+
+	if got := rr.Header().Get("Content-Encoding"); got != "br" {
+		t.Fatalf("Unexpected Content-Encoding: %q", got)
+	}
+	body, _ := io.ReadAll(brotli.NewReader(rr.Body))
+	if string(body) != largeBody {
+		t.Errorf("Decompressed body does not match")
+	}
+}
+
+func TestCompress_Negotiation(t *testing.T) {
+	tests := []struct {
+		acceptEncoding string
+		want           string
+	}{
+		{"br;q=0.5, gzip", "gzip"},
+		{"br;q=0, gzip;q=0", ""},
+		{"identity", ""},
+		{"*", "br"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		rr := compressedGet(t, Compress(jsonHandler(http.StatusOK, largeBody)), tt.acceptEncoding)
+		if got := rr.Header().Get("Content-Encoding"); got != tt.want {
+			t.Errorf("Accept-Encoding %q: got %q want %q", tt.acceptEncoding, got, tt.want)
+		}
+	}
+}
+
+func TestCompress_SkipsSmallAndBinaryBodies(t *testing.T) {
+	image := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+		w.Write([]byte(largeBody))
+	})
+
+	for name, handler := range map[string]http.Handler{
+		"small":  Compress(jsonHandler(http.StatusOK, `{"events":[]}`)),
+		"binary": Compress(image),
+	} {
+		rr := compressedGet(t, handler, "gzip")
+		if got := rr.Header().Get("Content-Encoding"); got != "" {
+			t.Errorf("%s: expected no compression, got %q", name, got)
+		}
+		if rr.Header().Get("Vary") != "Accept-Encoding" {
+			t.Errorf("%s: expected responses to vary by Accept-Encoding", name)
+		}
+	}
+}
+
+func TestCompress_WeakensETag(t *testing.T) {
+	rr := compressedGet(t, Compress(Cache(time.Minute, jsonHandler(http.StatusOK, largeBody))), "gzip")
+
+	if got, want := rr.Header().Get("ETag"), "W/"+ETag([]byte(largeBody)); got != want {
+		t.Errorf("Unexpected ETag: got %q want %q", got, want)
+	}
+}
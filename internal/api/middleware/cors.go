@@ -0,0 +1,73 @@
+package middleware
+
+import (
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CORSConfig lists what browsers on other origins may do with the API
+type CORSConfig struct {
+	// AllowedOrigins are the origins allowed to call the API, or "*" for
+	// any. CORS is off when it is empty.
+	AllowedOrigins []string
+
+	AllowedMethods []string
+	AllowedHeaders []string
+
+	// ExposedHeaders are the response headers scripts may read
+	ExposedHeaders []string
+
+	// MaxAge is how long browsers may cache a preflight response
+	MaxAge time.Duration
+}
+
+func (c CORSConfig) allows(origin string) bool {
+	return slices.Contains(c.AllowedOrigins, "*") || slices.Contains(c.AllowedOrigins, origin)
+}
+
+// CORS adds the headers that let browsers on the configured origins call
+// the API, and answers their preflight requests itself. Requests from other
+// origins are served without them, so browsers block the response.
+func CORS(config CORSConfig, next http.Handler) http.Handler {
+	if len(config.AllowedOrigins) == 0 {
+		return next
+	}
+
+	allowedMethods := strings.Join(config.AllowedMethods, ", ")
+	allowedHeaders := strings.Join(config.AllowedHeaders, ", ")
+	exposedHeaders := strings.Join(config.ExposedHeaders, ", ")
+	maxAge := strconv.Itoa(int(config.MaxAge.Seconds()))
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		w.Header().Add("Vary", "Origin")
+		if origin == "" || !config.allows(origin) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Set("Access-Control-Allow-Origin", origin)
+
+		if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+			w.Header().Add("Vary", "Access-Control-Request-Method")
+			w.Header().Add("Vary", "Access-Control-Request-Headers")
+			w.Header().Set("Access-Control-Allow-Methods", allowedMethods)
+			if allowedHeaders != "" {
+				w.Header().Set("Access-Control-Allow-Headers", allowedHeaders)
+			}
+			if config.MaxAge > 0 {
+				w.Header().Set("Access-Control-Max-Age", maxAge)
+			}
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		if exposedHeaders != "" {
+			w.Header().Set("Access-Control-Expose-Headers", exposedHeaders)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
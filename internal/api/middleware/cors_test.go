@@ -0,0 +1,82 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+var testCORS = CORSConfig{
+	AllowedOrigins: []string{"https://app.example.com"},
+	AllowedMethods: []string{"GET", "POST"},
+	AllowedHeaders: []string{"Authorization"},
+	ExposedHeaders: []string{"ETag"},
+	MaxAge:         10 * time.Minute,
+}
+
+func TestCORS_AllowedOrigin(t *testing.T) {
+	handler := CORS(testCORS, jsonHandler(http.StatusOK, `{}`))
+
+	req := httptest.NewRequest("GET", "/v1/events", nil)
+	req.Header.Set("Origin", "https://app.example.com")
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	if got := rr.Header().Get("Access-Control-Allow-Origin"); got != "https://app.example.com" {
+		t.Errorf("Unexpected Access-Control-Allow-Origin: %q", got)
+	}
+	if got := rr.Header().Get("Access-Control-Expose-Headers"); got != "ETag" {
+		t.Errorf("Unexpected Access-Control-Expose-Headers: %q", got)
+	}
+}
+
+func TestCORS_Preflight(t *testing.T) {
+	called := false
+	handler := CORS(testCORS, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { called = true }))
+
+	req := httptest.NewRequest("OPTIONS", "/admin/sync", nil)
+	req.Header.Set("Origin", "https://app.example.com")
+	req.Header.Set("Access-Control-Request-Method", "POST")
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusNoContent || called {
+		t.Errorf("Expected the preflight to be answered by CORS, got %v", rr.Code)
+	}
+	if got := rr.Header().Get("Access-Control-Allow-Methods"); got != "GET, POST" {
+		t.Errorf("Unexpected Access-Control-Allow-Methods: %q", got)
+	}
+	if got := rr.Header().Get("Access-Control-Max-Age"); got != "600" {
+		t.Errorf("Unexpected Access-Control-Max-Age: %q", got)
+	}
+}
+
+func TestCORS_OtherOrigin(t *testing.T) {
+	handler := CORS(testCORS, jsonHandler(http.StatusOK, `{}`))
+
+	req := httptest.NewRequest("GET", "/v1/events", nil)
+	req.Header.Set("Origin", "https://evil.example.com")
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	if got := rr.Header().Get("Access-Control-Allow-Origin"); got != "" {
+		t.Errorf("Expected no CORS headers for another origin, got %q", got)
+	}
+	if rr.Header().Get("Vary") != "Origin" {
+		t.Errorf("Expected responses to vary by Origin")
+	}
+}
+
+func TestCORS_Wildcard(t *testing.T) {
+	handler := CORS(CORSConfig{AllowedOrigins: []string{"*"}}, jsonHandler(http.StatusOK, `{}`))
+
+	req := httptest.NewRequest("GET", "/v1/events", nil)
+	req.Header.Set("Origin", "https://anywhere.example.com")
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	if got := rr.Header().Get("Access-Control-Allow-Origin"); got != "https://anywhere.example.com" {
+		t.Errorf("Unexpected Access-Control-Allow-Origin: %q", got)
+	}
+}
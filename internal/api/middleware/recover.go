@@ -0,0 +1,40 @@
+package middleware
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+
+	apperrors "github.com/rbungay/racedatabase-api/pkg/errors"
+)
+
+// Recover turns a panicking handler into a logged 500 problem response. If
+// the handler had already started its response, the connection is aborted
+// instead so the client cannot mistake a truncated body for a whole one.
+func Recover(logger *slog.Logger, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sw := &statusWriter{ResponseWriter: w}
+		defer func() {
+			p := recover()
+			if p == nil {
+				return
+			}
+			if p == http.ErrAbortHandler {
+				panic(p)
+			}
+
+			logger.ErrorContext(r.Context(), "handler panicked",
+				slog.String("method", r.Method),
+				slog.String("path", r.URL.Path),
+				slog.Any("panic", p),
+				slog.String("stack", string(debug.Stack())))
+
+			if sw.status != 0 {
+				panic(http.ErrAbortHandler)
+			}
+			apperrors.WriteProblem(sw, r, fmt.Errorf("panic: %v", p))
+		}()
+		next.ServeHTTP(sw, r)
+	})
+}
@@ -0,0 +1,46 @@
+package middleware
+
+import (
+	"bytes"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	apperrors "github.com/rbungay/racedatabase-api/pkg/errors"
+)
+
+func TestRecover_WritesProblem(t *testing.T) {
+	var logs bytes.Buffer
+	handler := Recover(slog.New(slog.NewTextHandler(&logs, nil)), http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("json: unsupported value")
+	}))
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest("GET", "/v1/events", nil))
+
+	if rr.Code != http.StatusInternalServerError {
+		t.Errorf("Handler returned wrong status code: got %v want %v", rr.Code, http.StatusInternalServerError)
+	}
+	if got := rr.Header().Get("Content-Type"); got != apperrors.ProblemContentType {
+		t.Errorf("Expected a problem response, got %q", got)
+	}
+	if !strings.Contains(logs.String(), "json: unsupported value") || !strings.Contains(logs.String(), "stack=") {
+		t.Errorf("Expected the panic to be logged with its stack, got %q", logs.String())
+	}
+}
+
+func TestRecover_AbortsStartedResponses(t *testing.T) {
+	handler := Recover(slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil)), http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"events":[`))
+		panic("encoding failed")
+	}))
+
+	defer func() {
+		if p := recover(); p != http.ErrAbortHandler {
+			t.Errorf("Expected the connection to be aborted, got %v", p)
+		}
+	}()
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/v1/events", nil))
+}
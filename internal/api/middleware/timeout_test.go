@@ -0,0 +1,60 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestTimeout_PassesFastResponses(t *testing.T) {
+	handler := Timeout(time.Second, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"ok":true}`))
+	}))
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest("POST", "/admin/sync", nil))
+
+	if rr.Code != http.StatusCreated {
+		t.Errorf("Handler returned wrong status code: got %v want %v", rr.Code, http.StatusCreated)
+	}
+	if rr.Header().Get("Content-Type") != "application/json" || rr.Body.String() != `{"ok":true}` {
+		t.Errorf("Unexpected response: %v %q", rr.Header(), rr.Body.String())
+	}
+}
+
+func TestTimeout_SlowHandler(t *testing.T) {
+	cancelled := make(chan struct{})
+	handler := Timeout(10*time.Millisecond, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+		close(cancelled)
+		w.Write([]byte("too late"))
+	}))
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest("GET", "/v1/events", nil))
+
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Errorf("Handler returned wrong status code: got %v want %v", rr.Code, http.StatusServiceUnavailable)
+	}
+	select {
+	case <-cancelled:
+	case <-time.After(time.Second):
+		t.Fatal("Expected the handler's context to be cancelled")
+	}
+}
+
+func TestTimeout_PropagatesPanics(t *testing.T) {
+	handler := Timeout(time.Second, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	}))
+
+	defer func() {
+		if recover() == nil {
+			t.Errorf("Expected the panic to reach the caller")
+		}
+	}()
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/v1/events", nil))
+}
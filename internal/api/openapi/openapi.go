@@ -0,0 +1,32 @@
+// Package openapi serves the API's OpenAPI 3 document and a documentation
+// page rendered from it.
+package openapi
+
+import (
+	_ "embed"
+	"net/http"
+)
+
+//go:embed openapi.json
+var spec []byte
+
+//go:embed docs.html
+var docs []byte
+
+// Spec returns the OpenAPI document as JSON
+func Spec() []byte {
+	return spec
+}
+
+// SpecHandler serves GET /openapi.json
+func SpecHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(spec)
+}
+
+// DocsHandler serves GET /docs, a self-contained page that renders
+// /openapi.json without loading any third-party assets
+func DocsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write(docs)
+}
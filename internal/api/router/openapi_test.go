@@ -0,0 +1,543 @@
+package router
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rbungay/racedatabase-api/internal/api/openapi"
+	"github.com/rbungay/racedatabase-api/internal/api/runsignup/handlers"
+	"github.com/rbungay/racedatabase-api/internal/api/runsignup/models"
+	"github.com/rbungay/racedatabase-api/internal/api/runsignup/services"
+	apperrors "github.com/rbungay/racedatabase-api/pkg/errors"
+)
+
+func float(f float64) *float64 { return &f }
+
+// specRaceStore returns fully populated responses so every schema field is
+// exercised
+type specRaceStore struct{}
+
+func (specRaceStore) SearchRaces(query models.RaceQuery) ([]models.Race, error) {
+	return []models.Race{{
+		ID:            12345,
+		Name:          "Test Race",
+		Category:      "Runs",
+		NextDate:      "2025-06-01",
+		Location:      models.Location{City: "Newark", State: "NJ", PostalCode: "07102", Latitude: float(40.73), Longitude: float(-74.17)},
+		LastSeenAt:    "2025-05-01T00:00:00Z",
+		RemovedAt:     "2025-05-02T00:00:00Z",
+		DistanceMiles: float(1.5),
+		Rank:          float(0.8),
+		Highlights:    map[string]string{"name": "<mark>Test</mark> Race"},
+	}}, nil
+}
+
+func (specRaceStore) RaceFacets(query models.RaceQuery) (*models.Facets, error) {
+	counts := []models.FacetCount{{Value: "5k", Count: 1}}
+	return &models.Facets{Category: counts, EventType: counts, Distance: counts, Month: counts, Price: counts, City: counts}, nil
+}
+
+func (s specRaceStore) EventFacets(raceIDs []int) (*models.Facets, error) {
+	return s.RaceFacets(models.RaceQuery{})
+}
+
+func (specRaceStore) GetPriceHistory(raceID int) (*models.RacePriceHistory, error) {
+	return &models.RacePriceHistory{RaceID: raceID, Events: []models.EventPriceHistory{{
+		EventID: 98765,
+		Name:    "5K Run",
+		Prices:  []models.PriceChange{{Opens: "2025-01-01T00:00:00Z", Fee: 30, ProcFee: 2.5, RecordedAt: "2025-01-02T00:00:00Z"}},
+	}}}, nil
+}
+
+func (specRaceStore) GetRaceChanges(raceID int) (*models.RaceChangeLog, error) {
+	return &models.RaceChangeLog{RaceID: raceID, Changes: []models.RaceChange{{
+		ID:         1,
+		DetectedAt: "2025-01-02T00:00:00Z",
+		Diff: models.RaceDiff{
+			Fields:        []models.FieldChange{{Field: "name", Old: "Old", New: "New"}},
+			AddedEvents:   []models.EventRef{{EventID: 1, Name: "10K"}},
+			RemovedEvents: []models.EventRef{{EventID: 2, Name: "1 Mile"}},
+			ChangedEvents: []models.EventChange{{
+				EventID: 98765,
+				Name:    "5K Run",
+				Fees:    []models.FeeChange{{Opens: "2025-01-01T00:00:00Z", OldFee: nil, NewFee: float(35)}},
+			}},
+		},
+	}}}, nil
+}
+
+var specFetchEvents = func(state, city, eventType, startDate, endDate, minDistance, maxDistance, zipcode, radius string) ([]models.Event, error) {
+	switch city {
+	case "partial":
+		return []models.Event{{ID: 1, Name: "Test Race", Category: "Runs"}}, &services.PartialError{Failures: []services.EventTypeFailure{
+			{EventType: "triathlon", Err: apperrors.Timeout(errors.New("timeout"))},
+		}}
+	case "ratelimited":
+		return nil, apperrors.RateLimited(errors.New("429"), 0)
+	}
+	return []models.Event{{
+		ID:       1,
+		Name:     "Test Race",
+		Category: "Runs",
+		NextDate: "06/01/2025",
+		Location: models.Location{City: "Newark", State: "NJ", Latitude: float(40.73), Longitude: float(-74.17)},
+	}}, nil
+}
+
+// specAuthenticate accepts rdb_spec and finds rdb_spent over its quota
+func specAuthenticate(key string) error {
+	switch key {
+	case "rdb_spec":
+		return nil
+	case "rdb_spent":
+		return apperrors.QuotaExceeded(time.Hour)
+	}
+	return apperrors.Unauthenticated("Invalid API key.")
+}
+
+func timestamp(t time.Time) *time.Time { return &t }
+
+var specTime = time.Date(2025, 5, 1, 0, 0, 0, 0, time.UTC)
+
+// specSyncService returns fully populated jobs and failures for job 1 and
+// refuses every other job
+type specSyncService struct{}
+
+func (specSyncService) StartSync(states, eventTypes []string, mode services.SyncMode) (*models.SyncJob, error) {
+	return specSyncService{}.SyncJob(1)
+}
+
+func (specSyncService) SyncJob(id int64) (*models.SyncJob, error) {
+	if id != 1 {
+		return nil, apperrors.NotFound("Sync job %d not found.", id)
+	}
+	return &models.SyncJob{
+		ID:           id,
+		Status:       models.SyncJobFailed,
+		Mode:         string(services.SyncAuto),
+		States:       []string{"NJ"},
+		EventTypes:   []string{"running_race"},
+		RacesFetched: 2,
+		RacesSaved:   1,
+		RacesFailed:  1,
+		Error:        "RunSignup is unavailable",
+		CreatedAt:    specTime,
+		StartedAt:    timestamp(specTime),
+		FinishedAt:   timestamp(specTime),
+		UpdatedAt:    specTime,
+		Progress: []models.SyncCheckpoint{{
+			State:         "NJ",
+			EventType:     "running_race",
+			ModifiedSince: timestamp(specTime),
+			NextPage:      2,
+		}},
+	}, nil
+}
+
+func (specSyncService) CancelSync(id int64) (*models.SyncJob, error) {
+	if id != 1 {
+		return nil, apperrors.Conflict("Sync job %d is running in another process.", id)
+	}
+	return specSyncService{}.SyncJob(id)
+}
+
+func (specSyncService) FailedIngests(minAttempts, limit int) ([]models.FailedIngest, error) {
+	return []models.FailedIngest{{
+		RaceID:        12345,
+		Stage:         models.IngestStageSave,
+		Error:         "connection refused",
+		Attempts:      minAttempts,
+		FirstFailedAt: specTime,
+		LastFailedAt:  specTime,
+		NextRetryAt:   specTime,
+		Race:          models.Event{ID: 12345, Name: "Test Race", Category: "Runs", NextDate: "06/01/2025"},
+	}}, nil
+}
+
+func (specSyncService) StartRetry(limit int) (*models.RetryStarted, error) {
+	return &models.RetryStarted{Retrying: 1}, nil
+}
+
+// specKeyService returns a fully populated key 1 and finds every other key
+// already revoked
+type specKeyService struct{}
+
+func (specKeyService) IssueAPIKey(name string, rateLimit, dailyQuota int) (*models.IssuedAPIKey, error) {
+	key, _ := specKeyService{}.APIKey(1)
+	return &models.IssuedAPIKey{APIKey: *key, Key: "rdb_secret"}, nil
+}
+
+func (specKeyService) APIKeys() ([]models.APIKey, error) {
+	key, _ := specKeyService{}.APIKey(1)
+	return []models.APIKey{*key}, nil
+}
+
+func (specKeyService) APIKey(id int64) (*models.APIKey, error) {
+	return &models.APIKey{
+		ID:            id,
+		Name:          "client",
+		Prefix:        "rdb_abcd",
+		RateLimit:     60,
+		DailyQuota:    10000,
+		CreatedAt:     specTime,
+		RevokedAt:     timestamp(specTime),
+		RequestsToday: 5,
+		Usage:         []models.APIKeyUsage{{Day: "2025-05-01", Requests: 5, LastUsedAt: specTime}},
+	}, nil
+}
+
+func (specKeyService) RevokeAPIKey(id int64) (*models.APIKey, error) {
+	if id != 1 {
+		return nil, apperrors.Conflict("API key %d has already been revoked.", id)
+	}
+	return specKeyService{}.APIKey(id)
+}
+
+// TestOpenAPI_ResponsesMatchSpec calls every documented route and checks the
+// status, content type and body against the OpenAPI document
+func TestOpenAPI_ResponsesMatchSpec(t *testing.T) {
+	var spec map[string]interface{}
+	if err := json.Unmarshal(openapi.Spec(), &spec); err != nil {
+		t.Fatalf("openapi.json is not valid JSON: %v", err)
+	}
+
+	handlers.FetchEventsFunc = specFetchEvents
+	defer func() { handlers.FetchEventsFunc = services.FetchEvents }()
+	mux := New(mockFetchRaceDetails, specRaceStore{}, specAuthenticate)
+	RegisterAdmin(mux, "s3cret", specSyncService{}, specKeyService{})
+
+	targets := []string{
+		"/v1/events?state=NJ",
+		"/v1/events?state=NJ&facets=true",
+		"/v1/events?state=NJ&city=partial",
+		"/v1/events?state=NJ&city=ratelimited",
+		"/v1/events?state=XX&zipcode=abc",
+		"/v1/races",
+		"/v1/races?q=test&facets=true",
+		"/v1/races?limit=0",
+		"/v1/races/12345",
+		"/v1/races/99999",
+		"/v1/races/abc",
+		"/v1/races/12345/events/98765",
+		"/v1/races/12345/events/1",
+		"/v1/races/12345/price-history",
+		"/v1/races/12345/changes",
+		"/runsignup/events?state=NJ",
+		"/runsignup/events?state=NJ&city=partial",
+		"/runsignup/race/?race_id=12345",
+		"/runsignup/race/",
+		"/races",
+		"/races/12345/price-history",
+		"/races/12345/changes",
+		"/openapi.json",
+		"/docs",
+	}
+
+	type specRequest struct{ method, target, body, key, token string }
+
+	// Every target is requested with a valid key, and these with others
+	requests := []specRequest{
+		{"GET", "/v1/races/12345", "", "", ""},
+		{"GET", "/v1/races", "", "rdb_revoked", ""},
+		{"GET", "/v1/events?state=NJ", "", "rdb_spent", ""},
+	}
+	for _, target := range targets {
+		requests = append(requests, specRequest{"GET", target, "", "rdb_spec", ""})
+	}
+
+	// The admin routes take the admin token instead
+	requests = append(requests,
+		specRequest{"POST", "/admin/sync", `{"states": ["NJ"]}`, "", "s3cret"},
+		specRequest{"POST", "/admin/sync", `[]`, "", "s3cret"},
+		specRequest{"POST", "/admin/sync", `{"states": ["NJ"]}`, "", ""},
+		specRequest{"GET", "/admin/sync/1", "", "", "s3cret"},
+		specRequest{"GET", "/admin/sync/2", "", "", "s3cret"},
+		specRequest{"GET", "/admin/sync/abc", "", "", "s3cret"},
+		specRequest{"DELETE", "/admin/sync/1", "", "", "s3cret"},
+		specRequest{"DELETE", "/admin/sync/2", "", "", "s3cret"},
+		specRequest{"GET", "/admin/failed-ingest?min_attempts=5", "", "", "s3cret"},
+		specRequest{"GET", "/admin/failed-ingest?limit=0", "", "", "s3cret"},
+		specRequest{"POST", "/admin/failed-ingest/retry", "", "", "s3cret"},
+		specRequest{"POST", "/admin/api-keys", `{"name": "client"}`, "", "s3cret"},
+		specRequest{"GET", "/admin/api-keys", "", "", "s3cret"},
+		specRequest{"GET", "/admin/api-keys/1", "", "", "s3cret"},
+		specRequest{"DELETE", "/admin/api-keys/1", "", "", "s3cret"},
+		specRequest{"DELETE", "/admin/api-keys/2", "", "", "s3cret"},
+		specRequest{"GET", "/debug/vars", "", "", "s3cret"},
+		specRequest{"GET", "/debug/vars", "", "", "wrong"},
+	)
+
+	paths := spec["paths"].(map[string]interface{})
+	covered := map[string]bool{}
+	for _, request := range requests {
+		target := request.method + " " + request.target
+		req := httptest.NewRequest(request.method, request.target, strings.NewReader(request.body))
+		if request.key != "" {
+			req.Header.Set("X-API-Key", request.key)
+		}
+		if request.token != "" {
+			req.Header.Set("Authorization", "Bearer "+request.token)
+		}
+		_, pattern := mux.Handler(req)
+		path := strings.TrimPrefix(pattern, request.method+" ")
+		method := strings.ToLower(request.method)
+		covered[method+" "+path] = true
+
+		operation, ok := lookup(paths, path, method)
+		if !ok {
+			t.Errorf("%s: route %q is not documented", target, request.method+" "+path)
+			continue
+		}
+
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, req)
+
+		response, ok := lookup(operation.(map[string]interface{})["responses"], strconv.Itoa(rr.Code))
+		if !ok {
+			t.Errorf("%s: status %d is not documented", target, rr.Code)
+			continue
+		}
+		response = resolve(spec, response)
+
+		mediaType, _, _ := mime.ParseMediaType(rr.Header().Get("Content-Type"))
+		content, ok := lookup(response, "content", mediaType)
+		if !ok {
+			t.Errorf("%s: content type %q is not documented for status %d", target, mediaType, rr.Code)
+			continue
+		}
+		if !strings.HasSuffix(mediaType, "json") {
+			continue
+		}
+
+		var body interface{}
+		if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil {
+			t.Errorf("%s: invalid JSON body: %v", target, err)
+			continue
+		}
+		schema := content.(map[string]interface{})["schema"]
+		for _, problem := range validate(spec, schema, body, "body") {
+			t.Errorf("%s (%d): %s", target, rr.Code, problem)
+		}
+	}
+
+	for path, operations := range paths {
+		for method := range operations.(map[string]interface{}) {
+			if !covered[method+" "+path] {
+				t.Errorf("Documented route %s %s is not exercised by this test", strings.ToUpper(method), path)
+			}
+		}
+	}
+}
+
+// lookup walks nested JSON objects by key
+func lookup(v interface{}, keys ...string) (interface{}, bool) {
+	for _, key := range keys {
+		obj, ok := v.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		if v, ok = obj[key]; !ok {
+			return nil, false
+		}
+	}
+	return v, true
+}
+
+// resolve follows local $ref pointers such as #/components/schemas/Event
+func resolve(spec map[string]interface{}, v interface{}) interface{} {
+	for {
+		obj, ok := v.(map[string]interface{})
+		if !ok {
+			return v
+		}
+		ref, ok := obj["$ref"].(string)
+		if !ok {
+			return v
+		}
+		v, _ = lookup(spec, strings.Split(strings.TrimPrefix(ref, "#/"), "/")...)
+	}
+}
+
+// properties collects the properties declared by a schema and its allOf parts
+func properties(spec map[string]interface{}, schema map[string]interface{}) map[string]bool {
+	props := map[string]bool{}
+	if declared, ok := schema["properties"].(map[string]interface{}); ok {
+		for name := range declared {
+			props[name] = true
+		}
+	}
+	if parts, ok := schema["allOf"].([]interface{}); ok {
+		for _, part := range parts {
+			for name := range properties(spec, resolve(spec, part).(map[string]interface{})) {
+				props[name] = true
+			}
+		}
+	}
+	return props
+}
+
+// validate checks value against the subset of OpenAPI 3.0 schemas the spec
+// uses: $ref, allOf, type, nullable, enum, required, properties, items and
+// additionalProperties. Properties that the schema does not declare are
+// reported so undocumented fields are caught.
+func validate(spec map[string]interface{}, schemaValue, value interface{}, at string) []string {
+	schema, ok := resolve(spec, schemaValue).(map[string]interface{})
+	if !ok {
+		return []string{at + ": schema is not an object"}
+	}
+
+	if value == nil {
+		if schema["nullable"] == true {
+			return nil
+		}
+		return []string{at + ": is null"}
+	}
+
+	var problems []string
+	if parts, ok := schema["allOf"].([]interface{}); ok {
+		for _, part := range parts {
+			sub := resolve(spec, part).(map[string]interface{})
+			problems = append(problems, validateObjectFields(spec, sub, value, at)...)
+		}
+		problems = append(problems, unknownProperties(spec, schema, value, at)...)
+		return problems
+	}
+
+	if enum, ok := schema["enum"].([]interface{}); ok {
+		found := false
+		for _, allowed := range enum {
+			if allowed == value {
+				found = true
+			}
+		}
+		if !found {
+			problems = append(problems, fmt.Sprintf("%s: %v is not one of %v", at, value, enum))
+		}
+	}
+
+	switch schema["type"] {
+	case "string":
+		if _, ok := value.(string); !ok {
+			problems = append(problems, at+": is not a string")
+		}
+	case "integer":
+		if n, ok := value.(float64); !ok || n != float64(int64(n)) {
+			problems = append(problems, at+": is not an integer")
+		}
+	case "number":
+		if _, ok := value.(float64); !ok {
+			problems = append(problems, at+": is not a number")
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			problems = append(problems, at+": is not a boolean")
+		}
+	case "array":
+		items, ok := value.([]interface{})
+		if !ok {
+			return append(problems, at+": is not an array")
+		}
+		for i, item := range items {
+			problems = append(problems, validate(spec, schema["items"], item, fmt.Sprintf("%s[%d]", at, i))...)
+		}
+	case "object":
+		problems = append(problems, validateObjectFields(spec, schema, value, at)...)
+		problems = append(problems, unknownProperties(spec, schema, value, at)...)
+	}
+	return problems
+}
+
+// validateObjectFields checks required and declared properties of one
+// object schema, leaving unknown properties to unknownProperties
+func validateObjectFields(spec map[string]interface{}, schema map[string]interface{}, value interface{}, at string) []string {
+	obj, ok := value.(map[string]interface{})
+	if !ok {
+		return []string{at + ": is not an object"}
+	}
+
+	var problems []string
+	if required, ok := schema["required"].([]interface{}); ok {
+		for _, name := range required {
+			if _, ok := obj[name.(string)]; !ok {
+				problems = append(problems, fmt.Sprintf("%s: missing required property %q", at, name))
+			}
+		}
+	}
+
+	declared, _ := schema["properties"].(map[string]interface{})
+	names := make([]string, 0, len(obj))
+	for name := range obj {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if propSchema, ok := declared[name]; ok {
+			problems = append(problems, validate(spec, propSchema, obj[name], at+"."+name)...)
+		} else if extra, ok := schema["additionalProperties"].(map[string]interface{}); ok {
+			problems = append(problems, validate(spec, extra, obj[name], at+"."+name)...)
+		}
+	}
+	return problems
+}
+
+// unknownProperties reports properties not declared by schema or its allOf
+// parts, unless the schema allows additional properties
+func unknownProperties(spec map[string]interface{}, schema map[string]interface{}, value interface{}, at string) []string {
+	obj, ok := value.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	if _, ok := schema["additionalProperties"]; ok {
+		return nil
+	}
+	if _, ok := schema["properties"]; !ok && schema["allOf"] == nil {
+		// A bare {"type": "object"} accepts anything
+		return nil
+	}
+
+	declared := properties(spec, schema)
+	var problems []string
+	for name := range obj {
+		if !declared[name] {
+			problems = append(problems, fmt.Sprintf("%s: undocumented property %q", at, name))
+		}
+	}
+	sort.Strings(problems)
+	return problems
+}
+
+// TestValidate checks that the validator itself catches mismatches
+func TestValidate(t *testing.T) {
+	var spec map[string]interface{}
+	json.Unmarshal(openapi.Spec(), &spec)
+	eventSchema := map[string]interface{}{"$ref": "#/components/schemas/Event"}
+
+	tests := []struct {
+		name  string
+		body  string
+		valid bool
+	}{
+		{"valid", `{"race_id":1,"name":"","url":"","external_race_url":"","logo_url":"","category":"Runs","next_date":"","address":"","city":"","state":"","postal_code":""}`, true},
+		{"missing required", `{"race_id":1}`, false},
+		{"wrong type", `{"race_id":"1","name":"","url":"","external_race_url":"","logo_url":"","category":"Runs","next_date":"","address":"","city":"","state":"","postal_code":""}`, false},
+		{"bad enum", `{"race_id":1,"name":"","url":"","external_race_url":"","logo_url":"","category":"Rowing","next_date":"","address":"","city":"","state":"","postal_code":""}`, false},
+		{"undocumented", `{"race_id":1,"name":"","url":"","external_race_url":"","logo_url":"","category":"Runs","next_date":"","address":"","city":"","state":"","postal_code":"","extra":1}`, false},
+	}
+
+	for _, tt := range tests {
+		var body interface{}
+		json.Unmarshal([]byte(tt.body), &body)
+		problems := validate(spec, eventSchema, body, "body")
+		if valid := len(problems) == 0; valid != tt.valid {
+			t.Errorf("%s: got valid=%v want %v (%v)", tt.name, valid, tt.valid, problems)
+		}
+	}
+}
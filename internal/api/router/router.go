@@ -0,0 +1,196 @@
+// Package router maps the API's versioned routes, and the deprecated
+// routes that preceded them, onto the RunSignup handlers.
+package router
+
+import (
+	"expvar"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/rbungay/racedatabase-api/internal/api/middleware"
+	"github.com/rbungay/racedatabase-api/internal/api/openapi"
+	"github.com/rbungay/racedatabase-api/internal/api/runsignup/handlers"
+	"github.com/rbungay/racedatabase-api/internal/api/runsignup/models"
+	"github.com/rbungay/racedatabase-api/internal/api/runsignup/services"
+)
+
+// How long browsers and CDNs may cache each kind of response. Live RunSignup
+// listings change through the day; stored race data only changes when a
+// sync runs.
+const (
+	eventsMaxAge      = 5 * time.Minute
+	raceDetailsMaxAge = 15 * time.Minute
+	raceSearchMaxAge  = 5 * time.Minute
+	raceHistoryMaxAge = time.Hour
+	docsMaxAge        = time.Hour
+)
+
+// How long each kind of route may take before it is answered with a 503.
+// Live routes wait on RunSignup; stored routes only on the database.
+const (
+	liveTimeout   = 30 * time.Second
+	storedTimeout = 10 * time.Second
+	adminTimeout  = 10 * time.Second
+)
+
+// Options configures the middleware every request passes through
+type Options struct {
+	Logger *slog.Logger
+	CORS   middleware.CORSConfig
+}
+
+// CORSConfig lets browsers on origins use the API's methods and headers,
+// caching preflights for maxAge
+func CORSConfig(origins []string, maxAge time.Duration) middleware.CORSConfig {
+	return middleware.CORSConfig{
+		AllowedOrigins: origins,
+		AllowedMethods: []string{http.MethodGet, http.MethodPost, http.MethodDelete},
+		AllowedHeaders: []string{"Authorization", "Content-Type", "If-None-Match", middleware.APIKeyHeader},
+		ExposedHeaders: []string{"ETag", "Link", "Location", "Deprecation", "Retry-After", middleware.PartialResultsHeader},
+		MaxAge:         maxAge,
+	}
+}
+
+// Wrap puts handler behind the middleware every request passes through,
+// outermost first: access logs, CORS, panic recovery and compression
+func Wrap(handler http.Handler, opts Options) http.Handler {
+	return middleware.Chain(handler,
+		func(next http.Handler) http.Handler { return middleware.AccessLog(opts.Logger, next) },
+		func(next http.Handler) http.Handler { return middleware.CORS(opts.CORS, next) },
+		func(next http.Handler) http.Handler { return middleware.Recover(opts.Logger, next) },
+		middleware.Compress,
+	)
+}
+
+// RaceStore is the stored race data the /v1/races routes and event facets
+// are served from
+type RaceStore interface {
+	SearchRaces(query models.RaceQuery) ([]models.Race, error)
+	RaceFacets(query models.RaceQuery) (*models.Facets, error)
+	EventFacets(raceIDs []int) (*models.Facets, error)
+	GetPriceHistory(raceID int) (*models.RacePriceHistory, error)
+	GetRaceChanges(raceID int) (*models.RaceChangeLog, error)
+}
+
+// New returns a mux serving the /v1 API. Race details are fetched live with
+// fetchRaceDetails; the search, price history and change routes and event
+// facets need store and are left out when it is nil.
+//
+// When authenticate is set, every route but the docs needs an API key it
+// accepts. Without it the API is open to anyone.
+func New(fetchRaceDetails func(int) (*models.RaceDetails, error), store RaceStore, authenticate func(key string) error) *http.ServeMux {
+	mux := http.NewServeMux()
+
+	keyed := func(handler http.Handler) http.Handler {
+		if authenticate == nil {
+			return handler
+		}
+		return middleware.RequireAPIKey(authenticate, handler)
+	}
+	// Keyed responses must not be shared between clients by proxies
+	cached := middleware.Cache
+	if authenticate != nil {
+		cached = middleware.PrivateCache
+	}
+
+	mux.Handle("GET /openapi.json", middleware.Cache(docsMaxAge, http.HandlerFunc(openapi.SpecHandler)))
+	mux.Handle("GET /docs", middleware.Cache(docsMaxAge, http.HandlerFunc(openapi.DocsHandler)))
+
+	live := func(maxAge time.Duration, handler http.Handler) http.Handler {
+		return keyed(middleware.Timeout(liveTimeout, cached(maxAge, handler)))
+	}
+	var eventFacets func([]int) (*models.Facets, error)
+	if store != nil {
+		eventFacets = store.EventFacets
+	}
+	events := live(eventsMaxAge, handlers.EventsHandler(eventFacets))
+	raceDetails := live(raceDetailsMaxAge, handlers.RunSignupRaceDetailsHandler(fetchRaceDetails))
+	mux.Handle("GET /v1/events", events)
+	mux.Handle("GET /v1/races/{id}", raceDetails)
+	mux.Handle("GET /v1/races/{id}/events/{eventId}", live(raceDetailsMaxAge, handlers.RaceEventHandler(fetchRaceDetails)))
+
+	// Legacy routes, kept until clients move to /v1
+	legacyEvents := live(eventsMaxAge, http.HandlerFunc(handlers.RunSignupEventsHandler))
+	mux.Handle("/runsignup/events", Deprecated(legacyEvents, func(r *http.Request) string {
+		return "/v1/events"
+	}))
+	mux.Handle("/runsignup/race/", Deprecated(raceDetails, func(r *http.Request) string {
+		return "/v1/races/" + r.URL.Query().Get("race_id")
+	}))
+
+	if store == nil {
+		return mux
+	}
+
+	stored := func(maxAge time.Duration, handler http.Handler) http.Handler {
+		return keyed(middleware.Timeout(storedTimeout, cached(maxAge, handler)))
+	}
+	raceSearch := stored(raceSearchMaxAge, handlers.RaceSearchHandler(store.SearchRaces, store.RaceFacets))
+	priceHistory := stored(raceHistoryMaxAge, handlers.RacePriceHistoryHandler(store.GetPriceHistory))
+	raceChanges := stored(raceHistoryMaxAge, handlers.RaceChangesHandler(store.GetRaceChanges))
+	mux.Handle("GET /v1/races", raceSearch)
+	mux.Handle("GET /v1/races/{id}/price-history", priceHistory)
+	mux.Handle("GET /v1/races/{id}/changes", raceChanges)
+
+	mux.Handle("GET /races", Deprecated(raceSearch, func(r *http.Request) string {
+		return "/v1/races"
+	}))
+	mux.Handle("GET /races/{id}/price-history", Deprecated(priceHistory, func(r *http.Request) string {
+		return fmt.Sprintf("/v1/races/%s/price-history", r.PathValue("id"))
+	}))
+	mux.Handle("GET /races/{id}/changes", Deprecated(raceChanges, func(r *http.Request) string {
+		return fmt.Sprintf("/v1/races/%s/changes", r.PathValue("id"))
+	}))
+
+	return mux
+}
+
+// SyncService runs and monitors the sync jobs and the retry queue of
+// failed races behind the admin routes
+type SyncService interface {
+	StartSync(states, eventTypes []string, mode services.SyncMode) (*models.SyncJob, error)
+	SyncJob(id int64) (*models.SyncJob, error)
+	CancelSync(id int64) (*models.SyncJob, error)
+	FailedIngests(minAttempts, limit int) ([]models.FailedIngest, error)
+	StartRetry(limit int) (*models.RetryStarted, error)
+}
+
+// KeyService issues, lists and revokes API keys behind the admin routes
+type KeyService interface {
+	IssueAPIKey(name string, rateLimit, dailyQuota int) (*models.IssuedAPIKey, error)
+	APIKeys() ([]models.APIKey, error)
+	APIKey(id int64) (*models.APIKey, error)
+	RevokeAPIKey(id int64) (*models.APIKey, error)
+}
+
+// RegisterAdmin adds the /admin routes, and the expvar metrics at
+// /debug/vars, to mux. Every admin request must carry token as a bearer
+// credential.
+func RegisterAdmin(mux *http.ServeMux, token string, syncs SyncService, keys KeyService) {
+	admin := func(handler http.Handler) http.Handler {
+		return middleware.RequireToken(token, middleware.Timeout(adminTimeout, handler))
+	}
+
+	mux.Handle("POST /admin/sync", admin(handlers.StartSyncHandler(syncs.StartSync)))
+	mux.Handle("GET /admin/sync/{jobId}", admin(handlers.SyncJobHandler(syncs.SyncJob)))
+	mux.Handle("DELETE /admin/sync/{jobId}", admin(handlers.CancelSyncHandler(syncs.CancelSync)))
+	mux.Handle("GET /admin/failed-ingest", admin(handlers.FailedIngestsHandler(syncs.FailedIngests)))
+	mux.Handle("POST /admin/failed-ingest/retry", admin(handlers.RetryFailedIngestsHandler(syncs.StartRetry)))
+	mux.Handle("POST /admin/api-keys", admin(handlers.IssueAPIKeyHandler(keys.IssueAPIKey)))
+	mux.Handle("GET /admin/api-keys", admin(handlers.APIKeysHandler(keys.APIKeys)))
+	mux.Handle("GET /admin/api-keys/{keyId}", admin(handlers.APIKeyHandler(keys.APIKey)))
+	mux.Handle("DELETE /admin/api-keys/{keyId}", admin(handlers.RevokeAPIKeyHandler(keys.RevokeAPIKey)))
+	mux.Handle("GET /debug/vars", admin(expvar.Handler()))
+}
+
+// Deprecated marks responses from a legacy route with a Deprecation header
+// and a Link to the route that replaces it
+func Deprecated(next http.Handler, successor func(*http.Request) string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Deprecation", "true")
+		w.Header().Set("Link", fmt.Sprintf("<%s>; rel=\"successor-version\"", successor(r)))
+		next.ServeHTTP(w, r)
+	})
+}
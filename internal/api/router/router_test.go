@@ -0,0 +1,348 @@
+package router
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rbungay/racedatabase-api/internal/api/runsignup/models"
+	"github.com/rbungay/racedatabase-api/internal/api/runsignup/services"
+	apperrors "github.com/rbungay/racedatabase-api/pkg/errors"
+)
+
+var mockFetchRaceDetails = func(raceID int) (*models.RaceDetails, error) {
+	if raceID != 12345 {
+		return nil, apperrors.NotFound("Race %d not found.", raceID)
+	}
+	return &models.RaceDetails{
+		ID:   raceID,
+		Name: "Test Race",
+		Events: []models.EventDetails{
+			{EventID: 98765, Name: "5K Run", Distance: "5K"},
+		},
+	}, nil
+}
+
+// fakeRaceStore answers every query with a single race
+type fakeRaceStore struct{}
+
+func (fakeRaceStore) SearchRaces(query models.RaceQuery) ([]models.Race, error) {
+	return []models.Race{{ID: 12345, Name: "Test Race"}}, nil
+}
+
+func (fakeRaceStore) RaceFacets(query models.RaceQuery) (*models.Facets, error) {
+	return &models.Facets{}, nil
+}
+
+func (fakeRaceStore) EventFacets(raceIDs []int) (*models.Facets, error) {
+	return &models.Facets{}, nil
+}
+
+func (fakeRaceStore) GetPriceHistory(raceID int) (*models.RacePriceHistory, error) {
+	return &models.RacePriceHistory{RaceID: raceID}, nil
+}
+
+func (fakeRaceStore) GetRaceChanges(raceID int) (*models.RaceChangeLog, error) {
+	return &models.RaceChangeLog{RaceID: raceID}, nil
+}
+
+func serve(t *testing.T, handler http.Handler, method, target string) *httptest.ResponseRecorder {
+	t.Helper()
+	req, err := http.NewRequest(method, target, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	return rr
+}
+
+func TestNew_V1Routes(t *testing.T) {
+	mux := New(mockFetchRaceDetails, fakeRaceStore{}, nil)
+
+	tests := []struct {
+		target string
+		status int
+	}{
+		{"/v1/races/12345", http.StatusOK},
+		{"/v1/races/99999", http.StatusNotFound},
+		{"/v1/races/abc", http.StatusBadRequest},
+		{"/v1/races/12345/events/98765", http.StatusOK},
+		{"/v1/races/12345/events/1", http.StatusNotFound},
+		{"/v1/races", http.StatusOK},
+		{"/v1/races/12345/price-history", http.StatusOK},
+		{"/v1/races/12345/changes", http.StatusOK},
+		{"/v1/unknown", http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		rr := serve(t, mux, "GET", tt.target)
+		if rr.Code != tt.status {
+			t.Errorf("GET %s returned wrong status code: got %v want %v", tt.target, rr.Code, tt.status)
+		}
+		if rr.Header().Get("Deprecation") != "" {
+			t.Errorf("GET %s should not be deprecated", tt.target)
+		}
+	}
+}
+
+func TestNew_RaceEvent(t *testing.T) {
+	mux := New(mockFetchRaceDetails, nil, nil)
+
+	rr := serve(t, mux, "GET", "/v1/races/12345/events/98765")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Handler returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+	}
+
+	var event models.EventDetails
+	if err := json.Unmarshal(rr.Body.Bytes(), &event); err != nil {
+		t.Fatalf("Failed to parse JSON response: %v", err)
+	}
+	if event.EventID != 98765 || event.Name != "5K Run" {
+		t.Errorf("Unexpected event: %+v", event)
+	}
+}
+
+func TestNew_MethodNotAllowed(t *testing.T) {
+	mux := New(mockFetchRaceDetails, nil, nil)
+
+	rr := serve(t, mux, "POST", "/v1/races/12345")
+	if rr.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Handler returned wrong status code: got %v want %v", rr.Code, http.StatusMethodNotAllowed)
+	}
+}
+
+func TestNew_WithoutStore(t *testing.T) {
+	mux := New(mockFetchRaceDetails, nil, nil)
+
+	rr := serve(t, mux, "GET", "/v1/races")
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("Stored race search should not be routed without a store: got %v", rr.Code)
+	}
+}
+
+func TestNew_LegacyRoutes(t *testing.T) {
+	mux := New(mockFetchRaceDetails, fakeRaceStore{}, nil)
+
+	tests := []struct {
+		target    string
+		successor string
+	}{
+		{"/runsignup/race/?race_id=12345", "/v1/races/12345"},
+		{"/races", "/v1/races"},
+		{"/races/12345/price-history", "/v1/races/12345/price-history"},
+		{"/races/12345/changes", "/v1/races/12345/changes"},
+	}
+
+	for _, tt := range tests {
+		rr := serve(t, mux, "GET", tt.target)
+		if rr.Code != http.StatusOK {
+			t.Errorf("GET %s returned wrong status code: got %v want %v", tt.target, rr.Code, http.StatusOK)
+		}
+		if got := rr.Header().Get("Deprecation"); got != "true" {
+			t.Errorf("GET %s: unexpected Deprecation header %q", tt.target, got)
+		}
+		want := "<" + tt.successor + ">; rel=\"successor-version\""
+		if got := rr.Header().Get("Link"); got != want {
+			t.Errorf("GET %s: unexpected Link header: got %v want %v", tt.target, got, want)
+		}
+	}
+}
+
+func TestNew_CacheHeaders(t *testing.T) {
+	mux := New(mockFetchRaceDetails, fakeRaceStore{}, nil)
+
+	rr := serve(t, mux, "GET", "/v1/races/12345")
+	etag := rr.Header().Get("ETag")
+	if etag == "" {
+		t.Fatalf("Expected an ETag on race details")
+	}
+	if got := rr.Header().Get("Cache-Control"); got != "public, max-age=900" {
+		t.Errorf("Unexpected Cache-Control: %v", got)
+	}
+
+	req, err := http.NewRequest("GET", "/runsignup/race/?race_id=12345", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("If-None-Match", etag)
+	rr = httptest.NewRecorder()
+	mux.ServeHTTP(rr, req)
+	if rr.Code != http.StatusNotModified {
+		t.Errorf("Legacy route should share the ETag: got status %v want %v", rr.Code, http.StatusNotModified)
+	}
+
+	rr = serve(t, mux, "GET", "/v1/races/99999")
+	if rr.Header().Get("ETag") != "" || rr.Header().Get("Cache-Control") != "no-store" {
+		t.Errorf("Errors should not be cached: ETag %q, Cache-Control %q", rr.Header().Get("ETag"), rr.Header().Get("Cache-Control"))
+	}
+}
+
+func TestNew_PrivateCacheWithAPIKey(t *testing.T) {
+	mux := New(mockFetchRaceDetails, fakeRaceStore{}, func(key string) error { return nil })
+
+	tests := []struct {
+		target, want string
+	}{
+		{"/v1/races/12345", "private, max-age=900"},
+		{"/v1/races?state=NJ", "private, max-age=300"},
+		{"/openapi.json", "public, max-age=3600"},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", tt.target, nil)
+		req.Header.Set("X-API-Key", "rdb_good")
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, req)
+
+		if got := rr.Header().Get("Cache-Control"); got != tt.want {
+			t.Errorf("GET %s: unexpected Cache-Control: got %q, want %q", tt.target, got, tt.want)
+		}
+	}
+}
+
+// fakeSyncService knows a single job
+type fakeSyncService struct{}
+
+func (fakeSyncService) StartSync(states, eventTypes []string, mode services.SyncMode) (*models.SyncJob, error) {
+	return &models.SyncJob{ID: 1, Status: models.SyncJobPending, States: states}, nil
+}
+
+func (fakeSyncService) SyncJob(id int64) (*models.SyncJob, error) {
+	return &models.SyncJob{ID: id, Status: models.SyncJobRunning}, nil
+}
+
+func (fakeSyncService) CancelSync(id int64) (*models.SyncJob, error) {
+	return &models.SyncJob{ID: id, Status: models.SyncJobRunning}, nil
+}
+
+func (fakeSyncService) FailedIngests(minAttempts, limit int) ([]models.FailedIngest, error) {
+	return []models.FailedIngest{{RaceID: 12345, Stage: models.IngestStageFetch, Attempts: minAttempts}}, nil
+}
+
+func (fakeSyncService) StartRetry(limit int) (*models.RetryStarted, error) {
+	return &models.RetryStarted{Retrying: 1}, nil
+}
+
+// fakeKeyService knows a single revoked key
+type fakeKeyService struct{}
+
+func (fakeKeyService) IssueAPIKey(name string, rateLimit, dailyQuota int) (*models.IssuedAPIKey, error) {
+	return &models.IssuedAPIKey{APIKey: models.APIKey{ID: 1, Name: name}, Key: "rdb_secret"}, nil
+}
+
+func (fakeKeyService) APIKeys() ([]models.APIKey, error) {
+	return []models.APIKey{{ID: 1, Name: "client"}}, nil
+}
+
+func (fakeKeyService) APIKey(id int64) (*models.APIKey, error) {
+	return &models.APIKey{ID: id, Name: "client"}, nil
+}
+
+func (fakeKeyService) RevokeAPIKey(id int64) (*models.APIKey, error) {
+	return nil, apperrors.Conflict("API key %d has already been revoked.", id)
+}
+
+func TestRegisterAdmin(t *testing.T) {
+	mux := New(mockFetchRaceDetails, nil, nil)
+	RegisterAdmin(mux, "s3cret", fakeSyncService{}, fakeKeyService{})
+
+	tests := []struct {
+		method, target, body string
+		token                string
+		want                 int
+	}{
+		{"POST", "/admin/sync", `{"states": ["NJ"]}`, "s3cret", http.StatusAccepted},
+		{"GET", "/admin/sync/1", "", "s3cret", http.StatusOK},
+		{"DELETE", "/admin/sync/1", "", "s3cret", http.StatusAccepted},
+		{"GET", "/admin/failed-ingest?min_attempts=5", "", "s3cret", http.StatusOK},
+		{"POST", "/admin/failed-ingest/retry", "", "s3cret", http.StatusAccepted},
+		{"POST", "/admin/api-keys", `{"name": "client"}`, "s3cret", http.StatusCreated},
+		{"GET", "/admin/api-keys", "", "s3cret", http.StatusOK},
+		{"GET", "/admin/api-keys/1", "", "s3cret", http.StatusOK},
+		{"DELETE", "/admin/api-keys/1", "", "s3cret", http.StatusConflict},
+		{"POST", "/admin/api-keys", `{"name": "client"}`, "", http.StatusUnauthorized},
+		{"GET", "/debug/vars", "", "s3cret", http.StatusOK},
+		{"GET", "/debug/vars", "", "", http.StatusUnauthorized},
+		{"GET", "/admin/failed-ingest", "", "", http.StatusUnauthorized},
+		{"GET", "/admin/sync/1", "", "", http.StatusUnauthorized},
+		{"POST", "/admin/sync", `{"states": ["NJ"]}`, "wrong", http.StatusUnauthorized},
+		{"PUT", "/admin/sync/1", "", "s3cret", http.StatusMethodNotAllowed},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, tt.target, strings.NewReader(tt.body))
+		if tt.token != "" {
+			req.Header.Set("Authorization", "Bearer "+tt.token)
+		}
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, req)
+
+		if rr.Code != tt.want {
+			t.Errorf("%s %s: got status %d, want %d", tt.method, tt.target, rr.Code, tt.want)
+		}
+	}
+}
+
+func TestNew_RequiresAPIKey(t *testing.T) {
+	authenticate := func(key string) error {
+		if key != "rdb_good" {
+			return apperrors.Unauthenticated("Invalid API key.")
+		}
+		return nil
+	}
+	mux := New(mockFetchRaceDetails, fakeRaceStore{}, authenticate)
+
+	tests := []struct {
+		target, key string
+		want        int
+	}{
+		{"/v1/races/12345", "rdb_good", http.StatusOK},
+		{"/v1/races?state=NJ", "rdb_good", http.StatusOK},
+		{"/v1/races/12345", "", http.StatusUnauthorized},
+		{"/v1/races?state=NJ", "rdb_bad", http.StatusUnauthorized},
+		{"/runsignup/race/?race_id=12345", "", http.StatusUnauthorized},
+		{"/races/12345/changes", "", http.StatusUnauthorized},
+		{"/openapi.json", "", http.StatusOK},
+		{"/docs", "", http.StatusOK},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", tt.target, nil)
+		if tt.key != "" {
+			req.Header.Set("X-API-Key", tt.key)
+		}
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, req)
+
+		if rr.Code != tt.want {
+			t.Errorf("GET %s with key %q: got status %d, want %d", tt.target, tt.key, rr.Code, tt.want)
+		}
+	}
+}
+
+func TestWrap(t *testing.T) {
+	handler := Wrap(New(mockFetchRaceDetails, nil, nil), Options{
+		Logger: slog.New(slog.NewTextHandler(io.Discard, nil)),
+		CORS:   CORSConfig([]string{"https://app.example.com"}, time.Minute),
+	})
+
+	req := httptest.NewRequest("OPTIONS", "/v1/races/12345", nil)
+	req.Header.Set("Origin", "https://app.example.com")
+	req.Header.Set("Access-Control-Request-Method", "GET")
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	if rr.Code != http.StatusNoContent || rr.Header().Get("Access-Control-Allow-Origin") != "https://app.example.com" {
+		t.Errorf("Expected the preflight to be allowed, got %d %v", rr.Code, rr.Header())
+	}
+
+	req = httptest.NewRequest("GET", "/openapi.json", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK || rr.Header().Get("Content-Encoding") != "gzip" {
+		t.Errorf("Expected a compressed spec, got %d %q", rr.Code, rr.Header().Get("Content-Encoding"))
+	}
+}
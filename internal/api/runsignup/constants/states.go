@@ -0,0 +1,17 @@
+package constants
+
+// USPSStateCodes are the two-letter USPS codes RunSignup accepts for state:
+// the 50 states, DC, and the inhabited territories and military regions.
+var USPSStateCodes = map[string]bool{
+	"AL": true, "AK": true, "AZ": true, "AR": true, "CA": true, "CO": true,
+	"CT": true, "DE": true, "DC": true, "FL": true, "GA": true, "HI": true,
+	"ID": true, "IL": true, "IN": true, "IA": true, "KS": true, "KY": true,
+	"LA": true, "ME": true, "MD": true, "MA": true, "MI": true, "MN": true,
+	"MS": true, "MO": true, "MT": true, "NE": true, "NV": true, "NH": true,
+	"NJ": true, "NM": true, "NY": true, "NC": true, "ND": true, "OH": true,
+	"OK": true, "OR": true, "PA": true, "RI": true, "SC": true, "SD": true,
+	"TN": true, "TX": true, "UT": true, "VT": true, "VA": true, "WA": true,
+	"WV": true, "WI": true, "WY": true,
+	"AS": true, "GU": true, "MP": true, "PR": true, "VI": true,
+	"AA": true, "AE": true, "AP": true,
+}
@@ -0,0 +1,96 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/rbungay/racedatabase-api/internal/api/runsignup/models"
+	"github.com/rbungay/racedatabase-api/internal/api/runsignup/services"
+	apperrors "github.com/rbungay/racedatabase-api/pkg/errors"
+)
+
+// apiKeyRequest is the body of POST /admin/api-keys. Limits left out get
+// the defaults.
+type apiKeyRequest struct {
+	Name       string `json:"name"`
+	RateLimit  *int   `json:"rate_limit"`
+	DailyQuota *int   `json:"daily_quota"`
+}
+
+// IssueAPIKeyHandler serves POST /admin/api-keys, issuing a key. The
+// response is the only time the key itself is shown.
+func IssueAPIKeyHandler(issue func(name string, rateLimit, dailyQuota int) (*models.IssuedAPIKey, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req apiKeyRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			apperrors.WriteProblem(w, r, apperrors.InvalidArgument("Request body must be a JSON object with name, rate_limit and daily_quota."))
+			return
+		}
+		rateLimit, dailyQuota := services.DefaultAPIKeyRateLimit, services.DefaultAPIKeyDailyQuota
+		if req.RateLimit != nil {
+			rateLimit = *req.RateLimit
+		}
+		if req.DailyQuota != nil {
+			dailyQuota = *req.DailyQuota
+		}
+
+		key, err := issue(req.Name, rateLimit, dailyQuota)
+		if err != nil {
+			apperrors.WriteProblem(w, r, err)
+			return
+		}
+
+		w.Header().Set("Location", fmt.Sprintf("/admin/api-keys/%d", key.ID))
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(key)
+	}
+}
+
+// APIKeysHandler serves GET /admin/api-keys, listing every key with its
+// requests today
+func APIKeysHandler(list func() ([]models.APIKey, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		keys, err := list()
+		if err != nil {
+			apperrors.WriteProblem(w, r, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string][]models.APIKey{"keys": keys})
+	}
+}
+
+// APIKeyHandler serves GET /admin/api-keys/{keyId} with the key's recent
+// daily usage
+func APIKeyHandler(get func(int64) (*models.APIKey, error)) http.HandlerFunc {
+	return apiKeyAction(get)
+}
+
+// RevokeAPIKeyHandler serves DELETE /admin/api-keys/{keyId}, revoking the
+// key
+func RevokeAPIKeyHandler(revoke func(int64) (*models.APIKey, error)) http.HandlerFunc {
+	return apiKeyAction(revoke)
+}
+
+func apiKeyAction(action func(int64) (*models.APIKey, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		keyID, err := strconv.ParseInt(r.PathValue("keyId"), 10, 64)
+		if err != nil {
+			apperrors.WriteProblem(w, r, apperrors.InvalidArgument("Invalid API key id format"))
+			return
+		}
+
+		key, err := action(keyID)
+		if err != nil {
+			apperrors.WriteProblem(w, r, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(key)
+	}
+}
@@ -0,0 +1,127 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rbungay/racedatabase-api/internal/api/runsignup/models"
+	"github.com/rbungay/racedatabase-api/internal/api/runsignup/services"
+	apperrors "github.com/rbungay/racedatabase-api/pkg/errors"
+)
+
+func TestIssueAPIKeyHandler(t *testing.T) {
+	var gotName string
+	var gotRateLimit, gotDailyQuota int
+	issue := func(name string, rateLimit, dailyQuota int) (*models.IssuedAPIKey, error) {
+		gotName, gotRateLimit, gotDailyQuota = name, rateLimit, dailyQuota
+		return &models.IssuedAPIKey{
+			APIKey: models.APIKey{ID: 3, Name: name, Prefix: "rdb_abcdef", RateLimit: rateLimit, DailyQuota: dailyQuota},
+			Key:    "rdb_abcdefsecret",
+		}, nil
+	}
+
+	tests := []struct {
+		body                          string
+		wantRateLimit, wantDailyQuota int
+	}{
+		{`{"name": "Race Calendar"}`, services.DefaultAPIKeyRateLimit, services.DefaultAPIKeyDailyQuota},
+		{`{"name": "Race Calendar", "rate_limit": 0, "daily_quota": 500}`, 0, 500},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("POST", "/admin/api-keys", strings.NewReader(tt.body))
+		rr := httptest.NewRecorder()
+		IssueAPIKeyHandler(issue).ServeHTTP(rr, req)
+
+		if status := rr.Code; status != http.StatusCreated {
+			t.Fatalf("Handler returned wrong status code: got %v want %v", status, http.StatusCreated)
+		}
+		if location := rr.Header().Get("Location"); location != "/admin/api-keys/3" {
+			t.Errorf("Unexpected Location: %q", location)
+		}
+		if gotName != "Race Calendar" || gotRateLimit != tt.wantRateLimit || gotDailyQuota != tt.wantDailyQuota {
+			t.Errorf("Unexpected key request for %s: %q %d %d", tt.body, gotName, gotRateLimit, gotDailyQuota)
+		}
+
+		var key models.IssuedAPIKey
+		if err := json.Unmarshal(rr.Body.Bytes(), &key); err != nil {
+			t.Fatalf("Failed to parse response: %v", err)
+		}
+		if key.Key != "rdb_abcdefsecret" || key.ID != 3 {
+			t.Errorf("Unexpected issued key: %+v", key)
+		}
+	}
+}
+
+func TestIssueAPIKeyHandler_InvalidBody(t *testing.T) {
+	issue := func(name string, rateLimit, dailyQuota int) (*models.IssuedAPIKey, error) {
+		t.Fatal("A key should not be issued for an invalid request")
+		return nil, nil
+	}
+
+	req := httptest.NewRequest("POST", "/admin/api-keys", strings.NewReader(`not json`))
+	rr := httptest.NewRecorder()
+	IssueAPIKeyHandler(issue).ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusBadRequest {
+		t.Errorf("Handler returned wrong status code: got %v want %v", status, http.StatusBadRequest)
+	}
+}
+
+func TestAPIKeysHandler(t *testing.T) {
+	list := func() ([]models.APIKey, error) {
+		return []models.APIKey{{ID: 1, Name: "Race Calendar", Prefix: "rdb_abcdef", RequestsToday: 42}}, nil
+	}
+
+	req := httptest.NewRequest("GET", "/admin/api-keys", nil)
+	rr := httptest.NewRecorder()
+	APIKeysHandler(list).ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusOK {
+		t.Fatalf("Handler returned wrong status code: got %v want %v", status, http.StatusOK)
+	}
+	var response struct {
+		Keys []map[string]any `json:"keys"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	if len(response.Keys) != 1 || response.Keys[0]["requests_today"] != 42.0 {
+		t.Errorf("Unexpected keys: %+v", response.Keys)
+	}
+	if _, ok := response.Keys[0]["key"]; ok {
+		t.Errorf("Listed keys should not include the key itself")
+	}
+}
+
+func TestRevokeAPIKeyHandler(t *testing.T) {
+	revoke := func(id int64) (*models.APIKey, error) {
+		if id != 3 {
+			return nil, apperrors.NotFound("API key %d not found.", id)
+		}
+		revoked := time.Now()
+		return &models.APIKey{ID: 3, RevokedAt: &revoked}, nil
+	}
+
+	tests := []struct {
+		keyID string
+		want  int
+	}{
+		{"3", http.StatusOK},
+		{"4", http.StatusNotFound},
+		{"abc", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("DELETE", "/admin/api-keys/"+tt.keyID, nil)
+		req.SetPathValue("keyId", tt.keyID)
+		rr := httptest.NewRecorder()
+		RevokeAPIKeyHandler(revoke).ServeHTTP(rr, req)
+
+		if status := rr.Code; status != tt.want {
+			t.Errorf("Handler returned wrong status code for key %s: got %v want %v", tt.keyID, status, tt.want)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/rbungay/racedatabase-api/internal/api/runsignup/models"
+	apperrors "github.com/rbungay/racedatabase-api/pkg/errors"
+)
+
+const (
+	defaultFailedIngestMinAttempts = 3
+	defaultFailedIngestLimit       = 100
+	maxFailedIngestLimit           = 1000
+)
+
+// FailedIngestsHandler serves GET /admin/failed-ingest, listing races that
+// have failed at least min_attempts times (default 3)
+func FailedIngestsHandler(list func(minAttempts, limit int) ([]models.FailedIngest, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		minAttempts, ok := intParam(w, r, "min_attempts", defaultFailedIngestMinAttempts, 1, 1<<20)
+		if !ok {
+			return
+		}
+		limit, ok := intParam(w, r, "limit", defaultFailedIngestLimit, 1, maxFailedIngestLimit)
+		if !ok {
+			return
+		}
+
+		failures, err := list(minAttempts, limit)
+		if err != nil {
+			apperrors.WriteProblem(w, r, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string][]models.FailedIngest{"failures": failures})
+	}
+}
+
+// RetryFailedIngestsHandler serves POST /admin/failed-ingest/retry, starting
+// a background retry of up to limit queued races whose backoff has elapsed.
+// Races still failing stay in GET /admin/failed-ingest.
+func RetryFailedIngestsHandler(retry func(limit int) (*models.RetryStarted, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		limit, ok := intParam(w, r, "limit", defaultFailedIngestLimit, 1, maxFailedIngestLimit)
+		if !ok {
+			return
+		}
+
+		started, err := retry(limit)
+		if err != nil {
+			apperrors.WriteProblem(w, r, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(started)
+	}
+}
+
+// intParam reads an optional integer query parameter between min and max,
+// writing a problem response and returning false when it is invalid
+func intParam(w http.ResponseWriter, r *http.Request, name string, fallback, min, max int) (int, bool) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return fallback, true
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < min || n > max {
+		apperrors.WriteProblem(w, r, apperrors.InvalidArgument("%s must be between %d and %d", name, min, max))
+		return 0, false
+	}
+	return n, true
+}
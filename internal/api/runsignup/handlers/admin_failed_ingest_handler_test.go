@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/rbungay/racedatabase-api/internal/api/runsignup/models"
+)
+
+func TestFailedIngestsHandler(t *testing.T) {
+	var gotMinAttempts, gotLimit int
+	list := func(minAttempts, limit int) ([]models.FailedIngest, error) {
+		gotMinAttempts, gotLimit = minAttempts, limit
+		return []models.FailedIngest{{RaceID: 12345, Stage: models.IngestStageSave, Attempts: 8}}, nil
+	}
+
+	req := httptest.NewRequest("GET", "/admin/failed-ingest?min_attempts=8", nil)
+	rr := httptest.NewRecorder()
+	FailedIngestsHandler(list).ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusOK {
+		t.Fatalf("Handler returned wrong status code: got %v want %v", status, http.StatusOK)
+	}
+	if gotMinAttempts != 8 || gotLimit != defaultFailedIngestLimit {
+		t.Errorf("Unexpected query: min_attempts %d, limit %d", gotMinAttempts, gotLimit)
+	}
+
+	var response struct {
+		Failures []models.FailedIngest `json:"failures"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to parse JSON response: %v", err)
+	}
+	if len(response.Failures) != 1 || response.Failures[0].RaceID != 12345 {
+		t.Errorf("Unexpected failures: %+v", response.Failures)
+	}
+}
+
+func TestFailedIngestsHandler_InvalidParams(t *testing.T) {
+	list := func(minAttempts, limit int) ([]models.FailedIngest, error) {
+		t.Fatal("Failures should not be listed for invalid parameters")
+		return nil, nil
+	}
+
+	for _, target := range []string{"/admin/failed-ingest?min_attempts=0", "/admin/failed-ingest?limit=5000", "/admin/failed-ingest?limit=x"} {
+		req := httptest.NewRequest("GET", target, nil)
+		rr := httptest.NewRecorder()
+		FailedIngestsHandler(list).ServeHTTP(rr, req)
+
+		if status := rr.Code; status != http.StatusBadRequest {
+			t.Errorf("Handler returned wrong status code for %s: got %v want %v", target, status, http.StatusBadRequest)
+		}
+	}
+}
+
+func TestRetryFailedIngestsHandler(t *testing.T) {
+	retry := func(limit int) (*models.RetryStarted, error) {
+		return &models.RetryStarted{Retrying: limit}, nil
+	}
+
+	req := httptest.NewRequest("POST", "/admin/failed-ingest/retry?limit=10", nil)
+	rr := httptest.NewRecorder()
+	RetryFailedIngestsHandler(retry).ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusAccepted {
+		t.Fatalf("Handler returned wrong status code: got %v want %v", status, http.StatusAccepted)
+	}
+	var started models.RetryStarted
+	if err := json.Unmarshal(rr.Body.Bytes(), &started); err != nil {
+		t.Fatalf("Failed to parse JSON response: %v", err)
+	}
+	if started.Retrying != 10 {
+		t.Errorf("Unexpected retry: %+v", started)
+	}
+}
@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/rbungay/racedatabase-api/internal/api/runsignup/models"
+	"github.com/rbungay/racedatabase-api/internal/api/runsignup/services"
+	apperrors "github.com/rbungay/racedatabase-api/pkg/errors"
+)
+
+// syncRequest is the body of POST /admin/sync
+type syncRequest struct {
+	States     []string `json:"states"`
+	EventTypes []string `json:"event_types"`
+	Mode       string   `json:"mode"`
+}
+
+// StartSyncHandler serves POST /admin/sync, starting a sync job in the
+// background and pointing to its status
+func StartSyncHandler(start func(states, eventTypes []string, mode services.SyncMode) (*models.SyncJob, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req syncRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			apperrors.WriteProblem(w, r, apperrors.InvalidArgument("Request body must be a JSON object with states, event_types and mode."))
+			return
+		}
+		mode, err := services.ParseSyncMode(req.Mode)
+		if err != nil {
+			apperrors.WriteProblem(w, r, err)
+			return
+		}
+
+		job, err := start(req.States, req.EventTypes, mode)
+		if err != nil {
+			apperrors.WriteProblem(w, r, err)
+			return
+		}
+
+		w.Header().Set("Location", fmt.Sprintf("/admin/sync/%d", job.ID))
+		writeSyncJob(w, http.StatusAccepted, job)
+	}
+}
+
+// SyncJobHandler serves GET /admin/sync/{jobId} with the job's progress
+func SyncJobHandler(get func(int64) (*models.SyncJob, error)) http.HandlerFunc {
+	return syncJobAction(get, http.StatusOK)
+}
+
+// CancelSyncHandler serves DELETE /admin/sync/{jobId}. A running job stops
+// after its current page, so the response only acknowledges the request.
+func CancelSyncHandler(cancel func(int64) (*models.SyncJob, error)) http.HandlerFunc {
+	return syncJobAction(cancel, http.StatusAccepted)
+}
+
+func syncJobAction(action func(int64) (*models.SyncJob, error), status int) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		jobID, err := strconv.ParseInt(r.PathValue("jobId"), 10, 64)
+		if err != nil {
+			apperrors.WriteProblem(w, r, apperrors.InvalidArgument("Invalid sync job id format"))
+			return
+		}
+
+		job, err := action(jobID)
+		if err != nil {
+			apperrors.WriteProblem(w, r, err)
+			return
+		}
+		writeSyncJob(w, status, job)
+	}
+}
+
+func writeSyncJob(w http.ResponseWriter, status int, job *models.SyncJob) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(job)
+}
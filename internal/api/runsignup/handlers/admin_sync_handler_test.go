@@ -0,0 +1,103 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/rbungay/racedatabase-api/internal/api/runsignup/models"
+	"github.com/rbungay/racedatabase-api/internal/api/runsignup/services"
+	apperrors "github.com/rbungay/racedatabase-api/pkg/errors"
+)
+
+var mockGetSyncJob = func(id int64) (*models.SyncJob, error) {
+	if id != 7 {
+		return nil, apperrors.NotFound("Sync job %d not found.", id)
+	}
+	return &models.SyncJob{ID: 7, Status: models.SyncJobRunning, States: []string{"NJ"}, RacesFetched: 120, RacesSaved: 118, RacesFailed: 2}, nil
+}
+
+func TestStartSyncHandler_Success(t *testing.T) {
+	var gotStates, gotEventTypes []string
+	var gotMode services.SyncMode
+	start := func(states, eventTypes []string, mode services.SyncMode) (*models.SyncJob, error) {
+		gotStates, gotEventTypes, gotMode = states, eventTypes, mode
+		return &models.SyncJob{ID: 7, Status: models.SyncJobPending, Mode: string(mode), States: states}, nil
+	}
+
+	body := `{"states": ["NJ", "NY"], "event_types": ["running_race"], "mode": "full"}`
+	req := httptest.NewRequest("POST", "/admin/sync", strings.NewReader(body))
+	rr := httptest.NewRecorder()
+	StartSyncHandler(start).ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusAccepted {
+		t.Fatalf("Handler returned wrong status code: got %v want %v", status, http.StatusAccepted)
+	}
+	if location := rr.Header().Get("Location"); location != "/admin/sync/7" {
+		t.Errorf("Unexpected Location: %q", location)
+	}
+	if len(gotStates) != 2 || len(gotEventTypes) != 1 || gotMode != services.SyncFull {
+		t.Errorf("Unexpected sync request: %v %v %v", gotStates, gotEventTypes, gotMode)
+	}
+}
+
+func TestStartSyncHandler_InvalidBody(t *testing.T) {
+	start := func(states, eventTypes []string, mode services.SyncMode) (*models.SyncJob, error) {
+		t.Fatal("A sync should not start for an invalid request")
+		return nil, nil
+	}
+
+	for _, body := range []string{`not json`, `{"states": ["NJ"], "mode": "sometimes"}`} {
+		req := httptest.NewRequest("POST", "/admin/sync", strings.NewReader(body))
+		rr := httptest.NewRecorder()
+		StartSyncHandler(start).ServeHTTP(rr, req)
+
+		if status := rr.Code; status != http.StatusBadRequest {
+			t.Errorf("Handler returned wrong status code for %s: got %v want %v", body, status, http.StatusBadRequest)
+		}
+	}
+}
+
+func TestSyncJobHandler(t *testing.T) {
+	req := httptest.NewRequest("GET", "/admin/sync/7", nil)
+	req.SetPathValue("jobId", "7")
+	rr := httptest.NewRecorder()
+	SyncJobHandler(mockGetSyncJob).ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusOK {
+		t.Fatalf("Handler returned wrong status code: got %v want %v", status, http.StatusOK)
+	}
+	var job models.SyncJob
+	if err := json.Unmarshal(rr.Body.Bytes(), &job); err != nil {
+		t.Fatalf("Failed to parse JSON response: %v", err)
+	}
+	if job.RacesFetched != 120 || job.RacesSaved != 118 || job.RacesFailed != 2 {
+		t.Errorf("Unexpected progress: %+v", job)
+	}
+}
+
+func TestSyncJobHandler_NotFoundAndInvalidID(t *testing.T) {
+	for id, want := range map[string]int{"8": http.StatusNotFound, "abc": http.StatusBadRequest} {
+		req := httptest.NewRequest("GET", "/admin/sync/"+id, nil)
+		req.SetPathValue("jobId", id)
+		rr := httptest.NewRecorder()
+		SyncJobHandler(mockGetSyncJob).ServeHTTP(rr, req)
+
+		if status := rr.Code; status != want {
+			t.Errorf("Handler returned wrong status code for %s: got %v want %v", id, status, want)
+		}
+	}
+}
+
+func TestCancelSyncHandler(t *testing.T) {
+	req := httptest.NewRequest("DELETE", "/admin/sync/7", nil)
+	req.SetPathValue("jobId", "7")
+	rr := httptest.NewRecorder()
+	CancelSyncHandler(mockGetSyncJob).ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusAccepted {
+		t.Errorf("Handler returned wrong status code: got %v want %v", status, http.StatusAccepted)
+	}
+}
@@ -2,18 +2,43 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log"
 	"net/http"
+	"strconv"
+	"strings"
 
+	"github.com/rbungay/racedatabase-api/internal/api/runsignup/models"
 	"github.com/rbungay/racedatabase-api/internal/api/runsignup/services"
+	"github.com/rbungay/racedatabase-api/internal/api/runsignup/validation"
+	apperrors "github.com/rbungay/racedatabase-api/pkg/errors"
 )
 
 var FetchEventsFunc = services.FetchEvents
 
+// PartialResultsHeader is set on responses that are missing some results
+// because an upstream query failed; the body's warnings say which.
+const PartialResultsHeader = "X-Partial-Results"
 
+// RunSignupEventsHandler serves the deprecated events search, which still
+// answers with a bare array of events. Partial results are only flagged by
+// the X-Partial-Results header, and facets are not offered.
 func RunSignupEventsHandler(w http.ResponseWriter, r *http.Request) {
+	serveEvents(w, r, nil, true)
+}
+
+// EventsHandler serves the live events search. facets=true adds counts over
+// the races found, from eventFacets; without it facets are unavailable.
+func EventsHandler(eventFacets func(raceIDs []int) (*models.Facets, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		serveEvents(w, r, eventFacets, false)
+	}
+}
+
+func serveEvents(w http.ResponseWriter, r *http.Request, eventFacets func(raceIDs []int) (*models.Facets, error), legacy bool) {
 	if r.Method != http.MethodGet {
-		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		apperrors.WriteProblem(w, r, apperrors.MethodNotAllowed(r.Method))
 		return
 	}
 
@@ -29,21 +54,68 @@ func RunSignupEventsHandler(w http.ResponseWriter, r *http.Request) {
 	radius := r.URL.Query().Get("radius")
 
 	
-	if state == "" {
-		http.Error(w, "State parameter is required", http.StatusBadRequest)
+	if errs := validation.ValidateEventsQuery(r.URL.Query()); errs != nil {
+		apperrors.WriteProblem(w, r, apperrors.InvalidFields(errs))
+		return
+	}
+	state = strings.ToUpper(state)
+	// Validated above
+	withFacets, _ := strconv.ParseBool(r.URL.Query().Get("facets"))
+	withFacets = withFacets && !legacy
+	if withFacets && eventFacets == nil {
+		apperrors.WriteProblem(w, r, apperrors.Unavailable("Facets are not available on this server."))
 		return
 	}
 
 	
 	events, err := FetchEventsFunc(state, city, eventType, startDate, endDate, minDistance, maxDistance, zipcode, radius)
-	if err != nil {
-		http.Error(w, "Error fetching events: "+err.Error(), http.StatusInternalServerError)
+	response := models.EventsResponse{Events: events}
+	var partial *services.PartialError
+	if errors.As(err, &partial) {
+		// Keep the event types that succeeded and tell the client which did not
+		log.Printf("Partial results for state %s: %v", state, err)
+		response.Warnings = eventWarnings(partial)
+		w.Header().Set(PartialResultsHeader, "true")
+	} else if err != nil {
+		apperrors.WriteProblem(w, r, err)
 		return
 	}
+	if response.Events == nil {
+		response.Events = []models.Event{}
+	}
+	if withFacets {
+		raceIDs := make([]int, len(response.Events))
+		for i, event := range response.Events {
+			raceIDs[i] = event.ID
+		}
+		response.Facets, err = eventFacets(raceIDs)
+		if err != nil {
+			apperrors.WriteProblem(w, r, err)
+			return
+		}
+	}
 
 	
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(events)
+	if legacy {
+		json.NewEncoder(w).Encode(response.Events)
+	} else {
+		json.NewEncoder(w).Encode(response)
+	}
 
 	fmt.Printf("Fetched events for state: %s\n", state)
 }
+
+// eventWarnings describes each failed event type with the same client-safe
+// code and message a problem response would use
+func eventWarnings(partial *services.PartialError) []models.Warning {
+	warnings := make([]models.Warning, 0, len(partial.Failures))
+	for _, failure := range partial.Failures {
+		warnings = append(warnings, models.Warning{
+			EventType: failure.EventType,
+			Code:      apperrors.KindOf(failure.Err).String(),
+			Message:   apperrors.PublicMessage(failure.Err),
+		})
+	}
+	return warnings
+}
@@ -2,12 +2,16 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 
 	"github.com/rbungay/racedatabase-api/internal/api/runsignup/services"
 	"github.com/rbungay/racedatabase-api/internal/api/runsignup/models"
+	"github.com/rbungay/racedatabase-api/internal/api/runsignup/validation"
+	apperrors "github.com/rbungay/racedatabase-api/pkg/errors"
 
 )
 
@@ -63,3 +67,177 @@ func TestRunSignupEventsHandler_ValidRequest(t *testing.T) {
 	}
 	
 }
+
+func TestRunSignupEventsHandler_InvalidParameters(t *testing.T) {
+	FetchEventsFunc = func(state, city, eventType, startDate, endDate, minDistance, maxDistance, zipcode, radius string) ([]models.Event, error) {
+		t.Fatal("FetchEvents should not be called with invalid parameters")
+		return nil, nil
+	}
+	defer func() { FetchEventsFunc = services.FetchEvents }()
+
+	req, err := http.NewRequest("GET", "/runsignup/events?state=ZZ&start_date=2025-13-01&zipcode=123", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(RunSignupEventsHandler).ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusBadRequest {
+		t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusBadRequest)
+	}
+
+	var body struct {
+		Errors []validation.FieldError `json:"errors"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil {
+		t.Fatalf("Failed to parse JSON response: %v", err)
+	}
+
+	fields := map[string]bool{}
+	for _, fe := range body.Errors {
+		fields[fe.Field] = true
+	}
+	for _, field := range []string{"state", "start_date", "zipcode"} {
+		if !fields[field] {
+			t.Errorf("Expected an error for %s, got %+v", field, body.Errors)
+		}
+	}
+}
+
+func TestRunSignupEventsHandler_UpstreamError(t *testing.T) {
+	FetchEventsFunc = func(state, city, eventType, startDate, endDate, minDistance, maxDistance, zipcode, radius string) ([]models.Event, error) {
+		return nil, apperrors.UpstreamUnavailable(errors.New("API error: status 500 - response: upstream stack trace"))
+	}
+	defer func() { FetchEventsFunc = services.FetchEvents }()
+
+	req, err := http.NewRequest("GET", "/runsignup/events?state=NY", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(RunSignupEventsHandler).ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusBadGateway {
+		t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusBadGateway)
+	}
+	if strings.Contains(rr.Body.String(), "upstream stack trace") {
+		t.Errorf("Response leaked the upstream body: %s", rr.Body.String())
+	}
+}
+
+func TestEventsHandler_PartialResults(t *testing.T) {
+	FetchEventsFunc = func(state, city, eventType, startDate, endDate, minDistance, maxDistance, zipcode, radius string) ([]models.Event, error) {
+		events, _ := mockFetchEvents(state, city, eventType, startDate, endDate, minDistance, maxDistance, zipcode, radius)
+		return events, &services.PartialError{Failures: []services.EventTypeFailure{
+			{EventType: "triathlon", Err: apperrors.Timeout(errors.New("context deadline exceeded"))},
+		}}
+	}
+	defer func() { FetchEventsFunc = services.FetchEvents }()
+
+	req, err := http.NewRequest("GET", "/v1/events?state=NY", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rr := httptest.NewRecorder()
+	EventsHandler(nil).ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusOK {
+		t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusOK)
+	}
+	if rr.Header().Get(PartialResultsHeader) != "true" {
+		t.Errorf("Expected %s header on partial results", PartialResultsHeader)
+	}
+
+	var response models.EventsResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to parse JSON response: %v", err)
+	}
+	if len(response.Events) != 1 || response.Events[0].Name != "Test Race" {
+		t.Errorf("Expected the successful events to be returned, got %v", response.Events)
+	}
+	if len(response.Warnings) != 1 {
+		t.Fatalf("Expected 1 warning, got %v", response.Warnings)
+	}
+	warning := response.Warnings[0]
+	if warning.EventType != "triathlon" || warning.Code != "timeout" {
+		t.Errorf("Unexpected warning: %+v", warning)
+	}
+	if strings.Contains(warning.Message, "deadline") {
+		t.Errorf("Warning leaked the underlying error: %v", warning.Message)
+	}
+}
+
+func TestRunSignupEventsHandler_PartialResults(t *testing.T) {
+	FetchEventsFunc = func(state, city, eventType, startDate, endDate, minDistance, maxDistance, zipcode, radius string) ([]models.Event, error) {
+		events, _ := mockFetchEvents(state, city, eventType, startDate, endDate, minDistance, maxDistance, zipcode, radius)
+		return events, &services.PartialError{Failures: []services.EventTypeFailure{
+			{EventType: "triathlon", Err: apperrors.Timeout(errors.New("context deadline exceeded"))},
+		}}
+	}
+	defer func() { FetchEventsFunc = services.FetchEvents }()
+
+	req := httptest.NewRequest("GET", "/runsignup/events?state=NY&facets=true", nil)
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(RunSignupEventsHandler).ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusOK {
+		t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusOK)
+	}
+	if rr.Header().Get(PartialResultsHeader) != "true" {
+		t.Errorf("Expected %s header on partial results", PartialResultsHeader)
+	}
+
+	// The legacy route keeps its bare array of events
+	var events []models.Event
+	if err := json.Unmarshal(rr.Body.Bytes(), &events); err != nil {
+		t.Fatalf("Expected a JSON array of events: %v", err)
+	}
+	if len(events) != 1 || events[0].Name != "Test Race" {
+		t.Errorf("Expected the successful events to be returned, got %v", events)
+	}
+}
+
+func TestEventsHandler_Facets(t *testing.T) {
+	FetchEventsFunc = mockFetchEvents
+	defer func() { FetchEventsFunc = services.FetchEvents }()
+
+	var counted []int
+	eventFacets := func(raceIDs []int) (*models.Facets, error) {
+		counted = raceIDs
+		return &models.Facets{City: []models.FacetCount{{Value: "Newark", Count: 1}}}, nil
+	}
+
+	req := httptest.NewRequest("GET", "/v1/events?state=NJ&facets=true", nil)
+	rr := httptest.NewRecorder()
+	EventsHandler(eventFacets).ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v", status, http.StatusOK)
+	}
+	var response models.EventsResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to parse JSON response: %v", err)
+	}
+	if response.Facets == nil || len(response.Facets.City) != 1 {
+		t.Errorf("Expected facets, got %+v", response.Facets)
+	}
+	if len(counted) != 1 || counted[0] != 12345 {
+		t.Errorf("Counted facets over races %v, want [12345]", counted)
+	}
+}
+
+func TestEventsHandler_FacetsUnavailable(t *testing.T) {
+	FetchEventsFunc = mockFetchEvents
+	defer func() { FetchEventsFunc = services.FetchEvents }()
+
+	req := httptest.NewRequest("GET", "/v1/events?state=NJ&facets=true", nil)
+	rr := httptest.NewRecorder()
+	EventsHandler(nil).ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusServiceUnavailable {
+		t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusServiceUnavailable)
+	}
+}
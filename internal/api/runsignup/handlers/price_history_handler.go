@@ -0,0 +1,35 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/rbungay/racedatabase-api/internal/api/runsignup/models"
+	apperrors "github.com/rbungay/racedatabase-api/pkg/errors"
+)
+
+// RacePriceHistoryHandler serves GET /v1/races/{id}/price-history
+func RacePriceHistoryHandler(fetchPriceHistory func(int) (*models.RacePriceHistory, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			apperrors.WriteProblem(w, r, apperrors.MethodNotAllowed(r.Method))
+			return
+		}
+
+		raceID, err := strconv.Atoi(r.PathValue("id"))
+		if err != nil {
+			apperrors.WriteProblem(w, r, apperrors.InvalidArgument("Invalid race id format"))
+			return
+		}
+
+		history, err := fetchPriceHistory(raceID)
+		if err != nil {
+			apperrors.WriteProblem(w, r, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(history)
+	}
+}
@@ -0,0 +1,82 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/rbungay/racedatabase-api/internal/api/runsignup/models"
+)
+
+var mockFetchPriceHistory = func(raceID int) (*models.RacePriceHistory, error) {
+	return &models.RacePriceHistory{
+		RaceID: raceID,
+		Events: []models.EventPriceHistory{
+			{
+				EventID: 98765,
+				Name:    "5K Run",
+				Prices: []models.PriceChange{
+					{Opens: "2025-01-01T00:00:00Z", Closes: "2025-05-30T23:59:00Z", Fee: 25, ProcFee: 2.5, RecordedAt: "2025-01-02T00:00:00Z"},
+					{Opens: "2025-01-01T00:00:00Z", Closes: "2025-05-30T23:59:00Z", Fee: 30, ProcFee: 2.5, RecordedAt: "2025-03-01T00:00:00Z"},
+				},
+			},
+		},
+	}, nil
+}
+
+var mockFetchPriceHistoryError = func(raceID int) (*models.RacePriceHistory, error) {
+	return nil, errors.New("database unavailable")
+}
+
+func TestRacePriceHistoryHandler_Success(t *testing.T) {
+	req := httptest.NewRequest("GET", "/races/12345/price-history", nil)
+	req.SetPathValue("id", "12345")
+
+	rr := httptest.NewRecorder()
+	RacePriceHistoryHandler(mockFetchPriceHistory).ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusOK {
+		t.Fatalf("Handler returned wrong status code: got %v want %v", status, http.StatusOK)
+	}
+
+	var history models.RacePriceHistory
+	if err := json.Unmarshal(rr.Body.Bytes(), &history); err != nil {
+		t.Fatalf("Failed to parse JSON response: %v", err)
+	}
+
+	if history.RaceID != 12345 {
+		t.Errorf("Unexpected race ID: got %v, want %v", history.RaceID, 12345)
+	}
+	if len(history.Events) != 1 || len(history.Events[0].Prices) != 2 {
+		t.Fatalf("Unexpected timeline: %+v", history.Events)
+	}
+	if history.Events[0].Prices[1].Fee != 30 {
+		t.Errorf("Unexpected latest fee: got %v, want %v", history.Events[0].Prices[1].Fee, 30)
+	}
+}
+
+func TestRacePriceHistoryHandler_InvalidRaceID(t *testing.T) {
+	req := httptest.NewRequest("GET", "/races/abc/price-history", nil)
+	req.SetPathValue("id", "abc")
+
+	rr := httptest.NewRecorder()
+	RacePriceHistoryHandler(mockFetchPriceHistory).ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusBadRequest {
+		t.Errorf("Handler returned wrong status code: got %v want %v", status, http.StatusBadRequest)
+	}
+}
+
+func TestRacePriceHistoryHandler_FetchError(t *testing.T) {
+	req := httptest.NewRequest("GET", "/races/12345/price-history", nil)
+	req.SetPathValue("id", "12345")
+
+	rr := httptest.NewRecorder()
+	RacePriceHistoryHandler(mockFetchPriceHistoryError).ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusInternalServerError {
+		t.Errorf("Handler returned wrong status code: got %v want %v", status, http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,62 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/rbungay/racedatabase-api/internal/api/runsignup/models"
+)
+
+var mockFetchRaceChanges = func(raceID int) (*models.RaceChangeLog, error) {
+	return &models.RaceChangeLog{
+		RaceID: raceID,
+		Changes: []models.RaceChange{
+			{
+				ID:         1,
+				DetectedAt: "2025-03-01T00:00:00Z",
+				Diff: models.RaceDiff{
+					Fields:      []models.FieldChange{{Field: "name", Old: "Test Race", New: "Test Race 2025"}},
+					AddedEvents: []models.EventRef{{EventID: 3, Name: "Half Marathon"}},
+				},
+			},
+		},
+	}, nil
+}
+
+func TestRaceChangesHandler_Success(t *testing.T) {
+	req := httptest.NewRequest("GET", "/races/12345/changes", nil)
+	req.SetPathValue("id", "12345")
+
+	rr := httptest.NewRecorder()
+	RaceChangesHandler(mockFetchRaceChanges).ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusOK {
+		t.Fatalf("Handler returned wrong status code: got %v want %v", status, http.StatusOK)
+	}
+
+	var log models.RaceChangeLog
+	if err := json.Unmarshal(rr.Body.Bytes(), &log); err != nil {
+		t.Fatalf("Failed to parse JSON response: %v", err)
+	}
+
+	if log.RaceID != 12345 || len(log.Changes) != 1 {
+		t.Fatalf("Unexpected change log: %+v", log)
+	}
+	if log.Changes[0].Diff.Fields[0].New != "Test Race 2025" {
+		t.Errorf("Unexpected name change: got %v, want %v", log.Changes[0].Diff.Fields[0].New, "Test Race 2025")
+	}
+}
+
+func TestRaceChangesHandler_InvalidRaceID(t *testing.T) {
+	req := httptest.NewRequest("GET", "/races/abc/changes", nil)
+	req.SetPathValue("id", "abc")
+
+	rr := httptest.NewRecorder()
+	RaceChangesHandler(mockFetchRaceChanges).ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusBadRequest {
+		t.Errorf("Handler returned wrong status code: got %v want %v", status, http.StatusBadRequest)
+	}
+}
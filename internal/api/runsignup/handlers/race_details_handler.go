@@ -7,34 +7,86 @@ import (
 
 	"github.com/rbungay/racedatabase-api/internal/api/runsignup/models"
  
+	apperrors "github.com/rbungay/racedatabase-api/pkg/errors"
 )
 
+// RunSignupRaceDetailsHandler serves GET /v1/races/{id} and the legacy
+// GET /runsignup/race/?race_id= route
 func RunSignupRaceDetailsHandler(fetchRaceDetails func(int) (*models.RaceDetails, error)) http.HandlerFunc {
     return func(w http.ResponseWriter, r *http.Request) {
         if r.Method != http.MethodGet {
-            http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+            apperrors.WriteProblem(w, r, apperrors.MethodNotAllowed(r.Method))
             return
         }
 
-        raceIDStr := r.URL.Query().Get("race_id")
-        if raceIDStr == "" {
-            http.Error(w, "race_id parameter is required", http.StatusBadRequest)
+        raceID, err := raceIDParam(r)
+        if err != nil {
+            apperrors.WriteProblem(w, r, err)
+            return
+        }
+
+        raceDetails, err := fetchRaceDetails(raceID)
+        if err != nil {
+            apperrors.WriteProblem(w, r, err)
+            return
+        }
+
+        w.Header().Set("Content-Type", "application/json")
+        json.NewEncoder(w).Encode(raceDetails)
+    }
+}
+
+// RaceEventHandler serves GET /v1/races/{id}/events/{eventId}, a single
+// event of a race
+func RaceEventHandler(fetchRaceDetails func(int) (*models.RaceDetails, error)) http.HandlerFunc {
+    return func(w http.ResponseWriter, r *http.Request) {
+        if r.Method != http.MethodGet {
+            apperrors.WriteProblem(w, r, apperrors.MethodNotAllowed(r.Method))
             return
         }
 
-        raceID, err := strconv.Atoi(raceIDStr)
+        raceID, err := raceIDParam(r)
         if err != nil {
-            http.Error(w, "Invalid race_id format", http.StatusBadRequest)
+            apperrors.WriteProblem(w, r, err)
+            return
+        }
+        eventID, err := strconv.Atoi(r.PathValue("eventId"))
+        if err != nil {
+            apperrors.WriteProblem(w, r, apperrors.InvalidArgument("Invalid event id format"))
             return
         }
 
         raceDetails, err := fetchRaceDetails(raceID)
         if err != nil {
-            http.Error(w, "Error fetching race details: "+err.Error(), http.StatusInternalServerError)
+            apperrors.WriteProblem(w, r, err)
             return
         }
 
-        w.Header().Set("Content-Type", "application/json")
-        json.NewEncoder(w).Encode(raceDetails)
+        for _, event := range raceDetails.Events {
+            if event.EventID == eventID {
+                w.Header().Set("Content-Type", "application/json")
+                json.NewEncoder(w).Encode(event)
+                return
+            }
+        }
+        apperrors.WriteProblem(w, r, apperrors.NotFound("Event %d not found in race %d.", eventID, raceID))
+    }
+}
+
+// raceIDParam reads the race id from the {id} path segment, falling back to
+// the race_id query parameter of the legacy route
+func raceIDParam(r *http.Request) (int, error) {
+    raceIDStr := r.PathValue("id")
+    if raceIDStr == "" {
+        raceIDStr = r.URL.Query().Get("race_id")
+    }
+    if raceIDStr == "" {
+        return 0, apperrors.InvalidArgument("race_id parameter is required")
+    }
+
+    raceID, err := strconv.Atoi(raceIDStr)
+    if err != nil {
+        return 0, apperrors.InvalidArgument("Invalid race_id format")
     }
+    return raceID, nil
 }
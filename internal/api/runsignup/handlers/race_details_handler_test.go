@@ -8,6 +8,7 @@ import (
 	"testing"
 
 	"github.com/rbungay/racedatabase-api/internal/api/runsignup/models"
+	apperrors "github.com/rbungay/racedatabase-api/pkg/errors"
 )
 
 // Mock FetchRaceDetails function
@@ -42,6 +43,11 @@ var mockFetchRaceDetails = func(raceID int) (*models.RaceDetails, error) {
 	}, nil
 }
 
+// Mock function for a race RunSignup does not know
+var mockFetchRaceDetailsNotFound = func(raceID int) (*models.RaceDetails, error) {
+	return nil, apperrors.Wrap(apperrors.KindNotFound, errors.New("race not found"), "Race 12345 not found.")
+}
+
 // Mock function for error case
 var mockFetchRaceDetailsError = func(raceID int) (*models.RaceDetails, error) {
 	return nil, errors.New("failed to fetch race details")
@@ -90,10 +96,7 @@ func TestRunSignupRaceDetailsHandler_MissingRaceID(t *testing.T) {
 		t.Errorf("Handler returned wrong status code: got %v want %v", status, http.StatusBadRequest)
 	}
 
-	expected := "race_id parameter is required\n"
-	if rr.Body.String() != expected {
-		t.Errorf("Unexpected response body: got %v want %v", rr.Body.String(), expected)
-	}
+	assertProblem(t, rr, "race_id parameter is required")
 }
 
 func TestRunSignupRaceDetailsHandler_InvalidRaceID(t *testing.T) {
@@ -111,10 +114,7 @@ func TestRunSignupRaceDetailsHandler_InvalidRaceID(t *testing.T) {
 		t.Errorf("Handler returned wrong status code: got %v want %v", status, http.StatusBadRequest)
 	}
 
-	expected := "Invalid race_id format\n"
-	if rr.Body.String() != expected {
-		t.Errorf("Unexpected response body: got %v want %v", rr.Body.String(), expected)
-	}
+	assertProblem(t, rr, "Invalid race_id format")
 }
 
 func TestRunSignupRaceDetailsHandler_FetchError(t *testing.T) {
@@ -132,8 +132,87 @@ func TestRunSignupRaceDetailsHandler_FetchError(t *testing.T) {
 		t.Errorf("Handler returned wrong status code: got %v want %v", status, http.StatusInternalServerError)
 	}
 
-	expected := "Error fetching race details: failed to fetch race details\n"
-	if rr.Body.String() != expected {
-		t.Errorf("Unexpected response body: got %v want %v", rr.Body.String(), expected)
+	// Internal error details are not exposed to clients
+	assertProblem(t, rr, "An unexpected error occurred.")
+}
+
+func TestRunSignupRaceDetailsHandler_NotFound(t *testing.T) {
+	req, err := http.NewRequest("GET", "/runsignup/race?race_id=12345", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rr := httptest.NewRecorder()
+	handler := RunSignupRaceDetailsHandler(mockFetchRaceDetailsNotFound)
+
+	handler.ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusNotFound {
+		t.Errorf("Handler returned wrong status code: got %v want %v", status, http.StatusNotFound)
+	}
+	assertProblem(t, rr, "Race 12345 not found.")
+}
+
+// assertProblem checks that rr holds a problem+json body with detail
+func assertProblem(t *testing.T, rr *httptest.ResponseRecorder, detail string) {
+	t.Helper()
+
+	if ct := rr.Header().Get("Content-Type"); ct != apperrors.ProblemContentType {
+		t.Errorf("Unexpected content type: got %v want %v", ct, apperrors.ProblemContentType)
+	}
+
+	var problem apperrors.Problem
+	if err := json.Unmarshal(rr.Body.Bytes(), &problem); err != nil {
+		t.Fatalf("Failed to parse problem response: %v", err)
+	}
+	if problem.Status != rr.Code {
+		t.Errorf("Problem status %v does not match response status %v", problem.Status, rr.Code)
+	}
+	if problem.Detail != detail {
+		t.Errorf("Unexpected problem detail: got %v want %v", problem.Detail, detail)
+	}
+}
+
+func TestRunSignupRaceDetailsHandler_PathID(t *testing.T) {
+	req, err := http.NewRequest("GET", "/v1/races/12345", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.SetPathValue("id", "12345")
+
+	rr := httptest.NewRecorder()
+	handler := RunSignupRaceDetailsHandler(mockFetchRaceDetails)
+
+	handler.ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusOK {
+		t.Errorf("Handler returned wrong status code: got %v want %v", status, http.StatusOK)
+	}
+
+	var raceDetails models.RaceDetails
+	if err := json.Unmarshal(rr.Body.Bytes(), &raceDetails); err != nil {
+		t.Fatalf("Failed to parse JSON response: %v", err)
+	}
+	if raceDetails.ID != 12345 {
+		t.Errorf("Unexpected race ID: got %v want %v", raceDetails.ID, 12345)
+	}
+}
+
+func TestRaceEventHandler_EventNotFound(t *testing.T) {
+	req, err := http.NewRequest("GET", "/v1/races/12345/events/1", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.SetPathValue("id", "12345")
+	req.SetPathValue("eventId", "1")
+
+	rr := httptest.NewRecorder()
+	handler := RaceEventHandler(mockFetchRaceDetails)
+
+	handler.ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusNotFound {
+		t.Errorf("Handler returned wrong status code: got %v want %v", status, http.StatusNotFound)
 	}
+	assertProblem(t, rr, "Event 1 not found in race 12345.")
 }
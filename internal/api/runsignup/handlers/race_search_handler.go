@@ -0,0 +1,149 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/rbungay/racedatabase-api/internal/api/runsignup/models"
+	apperrors "github.com/rbungay/racedatabase-api/pkg/errors"
+	"github.com/rbungay/racedatabase-api/pkg/geo"
+)
+
+const (
+	defaultRaceSearchLimit = 50
+	maxRaceSearchLimit     = 200
+	defaultRadiusMiles     = 25
+	maxRadiusMiles         = 500
+)
+
+// RaceSearchHandler serves GET /v1/races, a search over races stored by ingestion.
+// Races removed upstream are excluded unless include_removed=true. q runs a
+// ranked full-text search over race names, event names and city, and passing
+// lat/lng (or zipcode) with an optional radius_miles searches by proximity.
+// facets=true adds counts over the whole filtered result set.
+func RaceSearchHandler(
+	searchRaces func(models.RaceQuery) ([]models.Race, error),
+	raceFacets func(models.RaceQuery) (*models.Facets, error),
+) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			apperrors.WriteProblem(w, r, apperrors.MethodNotAllowed(r.Method))
+			return
+		}
+
+		query := models.RaceQuery{
+			Text:       strings.TrimSpace(r.URL.Query().Get("q")),
+			State:      strings.ToUpper(r.URL.Query().Get("state")),
+			City:       r.URL.Query().Get("city"),
+			PostalCode: r.URL.Query().Get("postal_code"),
+			Limit:      defaultRaceSearchLimit,
+		}
+
+		if v := r.URL.Query().Get("include_removed"); v != "" {
+			includeRemoved, err := strconv.ParseBool(v)
+			if err != nil {
+				apperrors.WriteProblem(w, r, apperrors.InvalidArgument("Invalid include_removed format"))
+				return
+			}
+			query.IncludeRemoved = includeRemoved
+		}
+
+		if v := r.URL.Query().Get("limit"); v != "" {
+			limit, err := strconv.Atoi(v)
+			if err != nil || limit < 1 || limit > maxRaceSearchLimit {
+				apperrors.WriteProblem(w, r, apperrors.InvalidArgument("limit must be between 1 and 200"))
+				return
+			}
+			query.Limit = limit
+		}
+
+		if v := r.URL.Query().Get("offset"); v != "" {
+			offset, err := strconv.Atoi(v)
+			if err != nil || offset < 0 {
+				apperrors.WriteProblem(w, r, apperrors.InvalidArgument("Invalid offset format"))
+				return
+			}
+			query.Offset = offset
+		}
+
+		near, radius, err := parseProximity(r)
+		if err != nil {
+			apperrors.WriteProblem(w, r, err)
+			return
+		}
+		query.Near = near
+		query.RadiusMiles = radius
+
+		withFacets := false
+		if v := r.URL.Query().Get("facets"); v != "" {
+			var err error
+			withFacets, err = strconv.ParseBool(v)
+			if err != nil {
+				apperrors.WriteProblem(w, r, apperrors.InvalidArgument("Invalid facets format"))
+				return
+			}
+		}
+
+		races, err := searchRaces(query)
+		if err != nil {
+			apperrors.WriteProblem(w, r, err)
+			return
+		}
+		response := models.RaceSearchResponse{Races: races}
+
+		if withFacets {
+			response.Facets, err = raceFacets(query)
+			if err != nil {
+				apperrors.WriteProblem(w, r, err)
+				return
+			}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+	}
+}
+
+// parseProximity reads the search center from lat/lng or zipcode and the
+// radius_miles around it
+func parseProximity(r *http.Request) (*geo.Point, float64, error) {
+	lat := r.URL.Query().Get("lat")
+	lng := r.URL.Query().Get("lng")
+	zipcode := r.URL.Query().Get("zipcode")
+	radiusStr := r.URL.Query().Get("radius_miles")
+
+	var center *geo.Point
+	switch {
+	case lat != "" || lng != "":
+		latitude, latErr := strconv.ParseFloat(lat, 64)
+		longitude, lngErr := strconv.ParseFloat(lng, 64)
+		point := geo.Point{Lat: latitude, Lng: longitude}
+		if latErr != nil || lngErr != nil || !point.Valid() {
+			return nil, 0, apperrors.InvalidArgument("lat and lng must both be valid coordinates")
+		}
+		center = &point
+	case zipcode != "":
+		point, ok := geo.LookupZIP(zipcode)
+		if !ok {
+			return nil, 0, apperrors.InvalidArgument("Unknown zipcode: %s", zipcode)
+		}
+		center = &point
+	case radiusStr != "":
+		return nil, 0, apperrors.InvalidArgument("radius_miles requires lat and lng or zipcode")
+	default:
+		return nil, 0, nil
+	}
+
+	radius := float64(defaultRadiusMiles)
+	if radiusStr != "" {
+		var err error
+		radius, err = strconv.ParseFloat(radiusStr, 64)
+		if err != nil || radius <= 0 || radius > maxRadiusMiles {
+			return nil, 0, apperrors.InvalidArgument("radius_miles must be between 0 and 500")
+		}
+	}
+
+	return center, radius, nil
+}
@@ -0,0 +1,224 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/rbungay/racedatabase-api/internal/api/runsignup/models"
+)
+
+var noFacets = func(query models.RaceQuery) (*models.Facets, error) {
+	return nil, nil
+}
+
+func TestRaceSearchHandler_DefaultsExcludeRemoved(t *testing.T) {
+	var got models.RaceQuery
+	search := func(query models.RaceQuery) ([]models.Race, error) {
+		got = query
+		return []models.Race{{ID: 12345, Name: "Test Race", Location: models.Location{State: "NJ"}}}, nil
+	}
+
+	req := httptest.NewRequest("GET", "/races?state=nj&city=Newark", nil)
+	rr := httptest.NewRecorder()
+	RaceSearchHandler(search, noFacets).ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusOK {
+		t.Fatalf("Handler returned wrong status code: got %v want %v", status, http.StatusOK)
+	}
+	if got.IncludeRemoved || got.State != "NJ" || got.City != "Newark" || got.Limit != defaultRaceSearchLimit {
+		t.Errorf("Unexpected query: %+v", got)
+	}
+
+	var response models.RaceSearchResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to parse JSON response: %v", err)
+	}
+	if len(response.Races) != 1 || response.Races[0].ID != 12345 {
+		t.Errorf("Unexpected races: %+v", response.Races)
+	}
+}
+
+func TestRaceSearchHandler_IncludeRemoved(t *testing.T) {
+	var got models.RaceQuery
+	search := func(query models.RaceQuery) ([]models.Race, error) {
+		got = query
+		return []models.Race{}, nil
+	}
+
+	req := httptest.NewRequest("GET", "/races?include_removed=true&limit=10&offset=20", nil)
+	rr := httptest.NewRecorder()
+	RaceSearchHandler(search, noFacets).ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusOK {
+		t.Fatalf("Handler returned wrong status code: got %v want %v", status, http.StatusOK)
+	}
+	if !got.IncludeRemoved || got.Limit != 10 || got.Offset != 20 {
+		t.Errorf("Unexpected query: %+v", got)
+	}
+}
+
+func TestRaceSearchHandler_InvalidLimit(t *testing.T) {
+	search := func(query models.RaceQuery) ([]models.Race, error) {
+		t.Fatal("search should not be called")
+		return nil, nil
+	}
+
+	req := httptest.NewRequest("GET", "/races?limit=1000", nil)
+	rr := httptest.NewRecorder()
+	RaceSearchHandler(search, noFacets).ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusBadRequest {
+		t.Errorf("Handler returned wrong status code: got %v want %v", status, http.StatusBadRequest)
+	}
+}
+
+func TestRaceSearchHandler_Proximity(t *testing.T) {
+	var got models.RaceQuery
+	search := func(query models.RaceQuery) ([]models.Race, error) {
+		got = query
+		distance := 1.5
+		return []models.Race{{ID: 12345, Name: "Test Race", DistanceMiles: &distance}}, nil
+	}
+
+	req := httptest.NewRequest("GET", "/races?lat=40.7178&lng=-74.0466&radius_miles=10", nil)
+	rr := httptest.NewRecorder()
+	RaceSearchHandler(search, noFacets).ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusOK {
+		t.Fatalf("Handler returned wrong status code: got %v want %v", status, http.StatusOK)
+	}
+	if got.Near == nil || got.Near.Lat != 40.7178 || got.Near.Lng != -74.0466 || got.RadiusMiles != 10 {
+		t.Errorf("Unexpected proximity query: near %+v, radius %v", got.Near, got.RadiusMiles)
+	}
+
+	var response models.RaceSearchResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to parse JSON response: %v", err)
+	}
+	if response.Races[0].DistanceMiles == nil || *response.Races[0].DistanceMiles != 1.5 {
+		t.Errorf("Expected distance_miles in response, got %+v", response.Races[0])
+	}
+}
+
+func TestRaceSearchHandler_ZipcodeCenter(t *testing.T) {
+	var got models.RaceQuery
+	search := func(query models.RaceQuery) ([]models.Race, error) {
+		got = query
+		return []models.Race{}, nil
+	}
+
+	req := httptest.NewRequest("GET", "/races?zipcode=07302", nil)
+	rr := httptest.NewRecorder()
+	RaceSearchHandler(search, noFacets).ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusOK {
+		t.Fatalf("Handler returned wrong status code: got %v want %v", status, http.StatusOK)
+	}
+	if got.Near == nil || got.RadiusMiles != defaultRadiusMiles {
+		t.Errorf("Expected zipcode centroid with default radius, got near %+v, radius %v", got.Near, got.RadiusMiles)
+	}
+}
+
+func TestRaceSearchHandler_InvalidProximity(t *testing.T) {
+	search := func(query models.RaceQuery) ([]models.Race, error) {
+		t.Fatal("search should not be called")
+		return nil, nil
+	}
+
+	for _, target := range []string{
+		"/races?lat=40.7",
+		"/races?lat=95&lng=-74",
+		"/races?radius_miles=10",
+		"/races?lat=40.7&lng=-74&radius_miles=-1",
+		"/races?zipcode=00000",
+	} {
+		rr := httptest.NewRecorder()
+		RaceSearchHandler(search, noFacets).ServeHTTP(rr, httptest.NewRequest("GET", target, nil))
+
+		if status := rr.Code; status != http.StatusBadRequest {
+			t.Errorf("%s: handler returned wrong status code: got %v want %v", target, status, http.StatusBadRequest)
+		}
+	}
+}
+
+func TestRaceSearchHandler_TextQuery(t *testing.T) {
+	var got models.RaceQuery
+	search := func(query models.RaceQuery) ([]models.Race, error) {
+		got = query
+		rank := 0.9
+		return []models.Race{{
+			ID:         12345,
+			Name:       "Hoboken Turkey Trot",
+			Rank:       &rank,
+			Highlights: map[string]string{"name": "Hoboken <mark>Turkey</mark> <mark>Trot</mark>"},
+		}}, nil
+	}
+
+	req := httptest.NewRequest("GET", "/races?q=+turkey+trot+", nil)
+	rr := httptest.NewRecorder()
+	RaceSearchHandler(search, noFacets).ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusOK {
+		t.Fatalf("Handler returned wrong status code: got %v want %v", status, http.StatusOK)
+	}
+	if got.Text != "turkey trot" {
+		t.Errorf("Unexpected text query: got %q, want %q", got.Text, "turkey trot")
+	}
+
+	var response models.RaceSearchResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to parse JSON response: %v", err)
+	}
+	if response.Races[0].Highlights["name"] == "" || response.Races[0].Rank == nil {
+		t.Errorf("Expected rank and highlights in response, got %+v", response.Races[0])
+	}
+}
+
+func TestRaceSearchHandler_Facets(t *testing.T) {
+	search := func(query models.RaceQuery) ([]models.Race, error) {
+		return []models.Race{{ID: 12345, Name: "Test Race"}}, nil
+	}
+	var got models.RaceQuery
+	facets := func(query models.RaceQuery) (*models.Facets, error) {
+		got = query
+		return &models.Facets{Category: []models.FacetCount{{Value: "Runs", Count: 120}, {Value: "Walks", Count: 35}}}, nil
+	}
+
+	req := httptest.NewRequest("GET", "/races?state=NJ&facets=true&limit=1", nil)
+	rr := httptest.NewRecorder()
+	RaceSearchHandler(search, facets).ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusOK {
+		t.Fatalf("Handler returned wrong status code: got %v want %v", status, http.StatusOK)
+	}
+	if got.State != "NJ" {
+		t.Errorf("Expected facets over the same filters, got %+v", got)
+	}
+
+	var response models.RaceSearchResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to parse JSON response: %v", err)
+	}
+	if response.Facets == nil || response.Facets.Category[0].Count != 120 {
+		t.Errorf("Unexpected facets: %+v", response.Facets)
+	}
+}
+
+func TestRaceSearchHandler_FacetsOmittedByDefault(t *testing.T) {
+	search := func(query models.RaceQuery) ([]models.Race, error) {
+		return []models.Race{}, nil
+	}
+	facets := func(query models.RaceQuery) (*models.Facets, error) {
+		t.Fatal("facets should not be computed")
+		return nil, nil
+	}
+
+	rr := httptest.NewRecorder()
+	RaceSearchHandler(search, facets).ServeHTTP(rr, httptest.NewRequest("GET", "/races", nil))
+
+	if status := rr.Code; status != http.StatusOK {
+		t.Fatalf("Handler returned wrong status code: got %v want %v", status, http.StatusOK)
+	}
+}
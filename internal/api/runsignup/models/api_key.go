@@ -0,0 +1,41 @@
+package models
+
+import "time"
+
+// APIKey is a key issued to one of the API's consumers. The key itself is
+// only known when it is issued; it is stored as a hash.
+type APIKey struct {
+	ID   int64  `json:"id"`
+	Name string `json:"name"`
+
+	// Prefix is the start of the key, to tell keys apart
+	Prefix string `json:"prefix"`
+
+	// RateLimit is requests per minute and DailyQuota requests per UTC day.
+	// Zero means unlimited.
+	RateLimit  int `json:"rate_limit"`
+	DailyQuota int `json:"daily_quota"`
+
+	CreatedAt time.Time  `json:"created_at"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+
+	// RequestsToday counts the key's requests since midnight UTC
+	RequestsToday int64 `json:"requests_today"`
+
+	// Usage is the key's requests per day, most recent first. It is only
+	// filled in for a single key.
+	Usage []APIKeyUsage `json:"usage,omitempty"`
+}
+
+// APIKeyUsage is the number of requests made with a key on a UTC day
+type APIKeyUsage struct {
+	Day        string    `json:"day"`
+	Requests   int64     `json:"requests"`
+	LastUsedAt time.Time `json:"last_used_at"`
+}
+
+// IssuedAPIKey is a newly issued key, the only time Key is shown
+type IssuedAPIKey struct {
+	APIKey
+	Key string `json:"key"`
+}
@@ -9,5 +9,23 @@ type Event struct {
 	ExternalURL string `json:"external_race_url"` 
 	LogoURL     string `json:"logo_url"`          
 	Category    constants.EventCategory `json:"category"`
+	NextDate    string `json:"next_date"`
+	Location
 }
 
+// EventsResponse is the body of the live events search. Warnings lists the
+// event types whose RunSignup queries failed, in which case Events holds
+// only the results of the queries that succeeded. Facets is only set when
+// asked for.
+type EventsResponse struct {
+	Events   []Event   `json:"events"`
+	Warnings []Warning `json:"warnings,omitempty"`
+	Facets   *Facets   `json:"facets,omitempty"`
+}
+
+// Warning describes a part of a response that could not be fetched.
+type Warning struct {
+	EventType string `json:"event_type"`
+	Code      string `json:"code"`
+	Message   string `json:"message"`
+}
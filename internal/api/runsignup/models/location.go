@@ -0,0 +1,12 @@
+package models
+
+// Location is where a race takes place. Latitude and Longitude are only set
+// when the location has been geocoded.
+type Location struct {
+	Address    string   `json:"address"`
+	City       string   `json:"city"`
+	State      string   `json:"state"`
+	PostalCode string   `json:"postal_code"`
+	Latitude   *float64 `json:"latitude,omitempty"`
+	Longitude  *float64 `json:"longitude,omitempty"`
+}
@@ -0,0 +1,23 @@
+package models
+
+// RacePriceHistory is the fee timeline of every event in a race.
+type RacePriceHistory struct {
+	RaceID int                 `json:"race_id"`
+	Events []EventPriceHistory `json:"events"`
+}
+
+// EventPriceHistory is the fee timeline of a single event.
+type EventPriceHistory struct {
+	EventID int           `json:"event_id"`
+	Name    string        `json:"name"`
+	Prices  []PriceChange `json:"prices"`
+}
+
+// PriceChange is a fee observed for a registration period at RecordedAt.
+type PriceChange struct {
+	Opens      string  `json:"registration_opens"`
+	Closes     string  `json:"registration_closes"`
+	Fee        float64 `json:"race_fee"`
+	ProcFee    float64 `json:"processing_fee"`
+	RecordedAt string  `json:"recorded_at"`
+}
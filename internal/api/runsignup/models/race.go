@@ -0,0 +1,68 @@
+package models
+
+import "github.com/rbungay/racedatabase-api/pkg/geo"
+
+// Race is a race as stored in our database.
+type Race struct {
+	ID          int    `json:"race_id"`
+	Name        string `json:"name"`
+	URL         string `json:"url"`
+	ExternalURL string `json:"external_race_url"`
+	LogoURL     string `json:"logo_url"`
+	Timezone    string `json:"timezone"`
+	Category    string `json:"category"`
+	NextDate    string `json:"next_date"`
+	Location
+	LastSeenAt      string `json:"last_seen_at,omitempty"`
+	PossiblyRemoved bool   `json:"possibly_removed"`
+	RemovedAt       string `json:"removed_at,omitempty"`
+
+	// DistanceMiles is set by proximity searches to the distance from the
+	// search center.
+	DistanceMiles *float64 `json:"distance_miles,omitempty"`
+
+	// Rank and Highlights are set by text searches. Highlights maps the
+	// matched fields (name, events, city) to snippets with <mark> tags.
+	Rank       *float64          `json:"rank,omitempty"`
+	Highlights map[string]string `json:"highlights,omitempty"`
+}
+
+// RaceQuery filters a search over stored races. Text is a free-text query
+// over race names, event names and city.
+type RaceQuery struct {
+	Text           string
+	State          string
+	City           string
+	PostalCode     string
+	IncludeRemoved bool
+	Limit          int
+	Offset         int
+
+	// Near restricts results to races within RadiusMiles of a point and
+	// sorts them nearest first.
+	Near        *geo.Point
+	RadiusMiles float64
+}
+
+// RaceSearchResponse is the body returned by the stored race search.
+type RaceSearchResponse struct {
+	Races  []Race  `json:"races"`
+	Facets *Facets `json:"facets,omitempty"`
+}
+
+// Facets counts the races in a filtered result set by attribute. A race is
+// counted once per distinct value among its events.
+type Facets struct {
+	Category  []FacetCount `json:"category"`
+	EventType []FacetCount `json:"event_type"`
+	Distance  []FacetCount `json:"distance"`
+	Month     []FacetCount `json:"month"`
+	Price     []FacetCount `json:"price"`
+	City      []FacetCount `json:"city"`
+}
+
+// FacetCount is the number of races having a facet value.
+type FacetCount struct {
+	Value string `json:"value"`
+	Count int    `json:"count"`
+}
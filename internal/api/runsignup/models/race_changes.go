@@ -0,0 +1,58 @@
+package models
+
+// RaceChangeLog is the audit trail of detected changes for a race.
+type RaceChangeLog struct {
+	RaceID  int          `json:"race_id"`
+	Changes []RaceChange `json:"changes"`
+}
+
+// RaceChange is a diff recorded when a race was re-ingested.
+type RaceChange struct {
+	ID         int64    `json:"id"`
+	DetectedAt string   `json:"detected_at"`
+	Diff       RaceDiff `json:"diff"`
+}
+
+// RaceDiff describes how a race differs from its previously stored version.
+type RaceDiff struct {
+	Fields        []FieldChange `json:"fields,omitempty"`
+	AddedEvents   []EventRef    `json:"added_events,omitempty"`
+	RemovedEvents []EventRef    `json:"removed_events,omitempty"`
+	ChangedEvents []EventChange `json:"changed_events,omitempty"`
+}
+
+// Empty reports whether the diff contains no changes.
+func (d RaceDiff) Empty() bool {
+	return len(d.Fields) == 0 && len(d.AddedEvents) == 0 &&
+		len(d.RemovedEvents) == 0 && len(d.ChangedEvents) == 0
+}
+
+// FieldChange is a single field whose value changed.
+type FieldChange struct {
+	Field string `json:"field"`
+	Old   string `json:"old"`
+	New   string `json:"new"`
+}
+
+// EventRef identifies an event that was added or removed.
+type EventRef struct {
+	EventID int    `json:"event_id"`
+	Name    string `json:"name"`
+}
+
+// EventChange lists the changes to an event present in both versions.
+type EventChange struct {
+	EventID int           `json:"event_id"`
+	Name    string        `json:"name"`
+	Fields  []FieldChange `json:"fields,omitempty"`
+	Fees    []FeeChange   `json:"fees,omitempty"`
+}
+
+// FeeChange is a registration period whose fee changed. A nil fee means the
+// period did not exist in that version.
+type FeeChange struct {
+	Opens  string   `json:"registration_opens"`
+	Closes string   `json:"registration_closes"`
+	OldFee *float64 `json:"old_fee"`
+	NewFee *float64 `json:"new_fee"`
+}
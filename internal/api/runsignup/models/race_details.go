@@ -8,6 +8,9 @@ type RaceDetails struct {
 	ExternalURL string         `json:"external_race_url"`
 	LogoURL    string          `json:"logo_url"`
 	Timezone   string          `json:"timezone"`
+	Category   string          `json:"category"`
+	NextDate   string          `json:"next_date"`
+	Location
 	Events     []EventDetails  `json:"events"`
 }
 
@@ -0,0 +1,94 @@
+package models
+
+import "time"
+
+// SyncWatermark is how far a state's stored races are known to be up to date
+type SyncWatermark struct {
+	State string `json:"state"`
+
+	// SyncedThrough is when the last successful sync of the state started.
+	// Races modified after it may not be stored yet.
+	SyncedThrough time.Time `json:"synced_through"`
+
+	// LastFullSync is when the last successful full sync started, or the
+	// zero time if none has completed
+	LastFullSync time.Time `json:"last_full_sync_at"`
+}
+
+// Sync job statuses. Running, interrupted and failed jobs can be resumed;
+// cancelled ones cannot.
+const (
+	SyncJobPending     = "pending"
+	SyncJobRunning     = "running"
+	SyncJobInterrupted = "interrupted"
+	SyncJobSucceeded   = "succeeded"
+	SyncJobFailed      = "failed"
+	SyncJobCancelled   = "cancelled"
+)
+
+// SyncJob is a sync of one or more states and its progress
+type SyncJob struct {
+	ID         int64    `json:"id"`
+	Status     string   `json:"status"`
+	Mode       string   `json:"mode"`
+	States     []string `json:"states"`
+	EventTypes []string `json:"event_types"`
+
+	RacesFetched int `json:"races_fetched"`
+	RacesSaved   int `json:"races_saved"`
+	RacesFailed  int `json:"races_failed"`
+
+	Error      string           `json:"error,omitempty"`
+	CreatedAt  time.Time        `json:"created_at"`
+	StartedAt  *time.Time       `json:"started_at,omitempty"`
+	FinishedAt *time.Time       `json:"finished_at,omitempty"`
+	Progress   []SyncCheckpoint `json:"progress,omitempty"`
+
+	// UpdatedAt moves with every checkpoint while the job runs
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// SyncCheckpoint is how far a job has got through one event type of a state
+type SyncCheckpoint struct {
+	State     string `json:"state"`
+	EventType string `json:"event_type"`
+
+	// ModifiedSince is nil when the state is fully synced
+	ModifiedSince *time.Time `json:"modified_since,omitempty"`
+
+	NextPage int  `json:"next_page"`
+	Done     bool `json:"done"`
+}
+
+// Stages at which storing a race can fail
+const (
+	IngestStageFetch = "fetch"
+	IngestStageSave  = "save"
+)
+
+// FailedIngest is a race that could not be stored, awaiting a retry
+type FailedIngest struct {
+	RaceID        int       `json:"race_id"`
+	Stage         string    `json:"stage"`
+	Error         string    `json:"error"`
+	Attempts      int       `json:"attempts"`
+	FirstFailedAt time.Time `json:"first_failed_at"`
+	LastFailedAt  time.Time `json:"last_failed_at"`
+	NextRetryAt   time.Time `json:"next_retry_at"`
+
+	// Race is the search result the race was found in
+	Race Event `json:"race"`
+}
+
+// RetryStarted is the number of failed races a background retry is
+// retrying
+type RetryStarted struct {
+	Retrying int `json:"retrying"`
+}
+
+// RetryResult counts the outcome of retrying failed races
+type RetryResult struct {
+	Retried   int `json:"retried"`
+	Recovered int `json:"recovered"`
+	Failed    int `json:"failed"`
+}
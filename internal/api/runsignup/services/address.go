@@ -0,0 +1,61 @@
+package services
+
+import (
+	"strings"
+
+	"github.com/rbungay/racedatabase-api/internal/api/runsignup/models"
+	"github.com/rbungay/racedatabase-api/pkg/geo"
+)
+
+// raceAddress is the address object RunSignup returns with a race
+type raceAddress struct {
+	Street  string `json:"street"`
+	Street2 string `json:"street2"`
+	City    string `json:"city"`
+	State   string `json:"state"`
+	Zipcode string `json:"zipcode"`
+}
+
+// location converts a RunSignup address into a models.Location
+func (a raceAddress) location() models.Location {
+	address := strings.TrimSpace(a.Street)
+	if street2 := strings.TrimSpace(a.Street2); street2 != "" {
+		address = strings.TrimSpace(address + ", " + street2)
+	}
+
+	return models.Location{
+		Address:    address,
+		City:       strings.TrimSpace(a.City),
+		State:      strings.ToUpper(strings.TrimSpace(a.State)),
+		PostalCode: strings.TrimSpace(a.Zipcode),
+	}
+}
+
+// fillFromSummary copies the search summary fields of a race into its
+// details wherever the details lack them
+func fillFromSummary(details *models.RaceDetails, summary models.Event) {
+	if details.Category == "" {
+		details.Category = string(summary.Category)
+	}
+	if details.NextDate == "" {
+		details.NextDate = summary.NextDate
+	}
+	if details.Address == "" && details.City == "" && details.PostalCode == "" {
+		details.Location = summary.Location
+	}
+	if details.State == "" {
+		details.State = summary.State
+	}
+}
+
+// geocode sets a race's coordinates to its ZIP code centroid when RunSignup
+// did not provide them
+func geocode(details *models.RaceDetails) {
+	if details.Latitude != nil && details.Longitude != nil {
+		return
+	}
+	if centroid, ok := geo.LookupZIP(details.PostalCode); ok {
+		details.Latitude = &centroid.Lat
+		details.Longitude = &centroid.Lng
+	}
+}
@@ -0,0 +1,347 @@
+package services
+
+import (
+	"container/list"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rbungay/racedatabase-api/internal/api/runsignup/models"
+	apperrors "github.com/rbungay/racedatabase-api/pkg/errors"
+)
+
+// The limits of keys issued without their own
+const (
+	DefaultAPIKeyRateLimit  = 60
+	DefaultAPIKeyDailyQuota = 10000
+)
+
+const (
+	// apiKeyPrefix starts every key, so leaked keys are easy to scan for
+	apiKeyPrefix = "rdb_"
+
+	// apiKeyCacheTTL is how long a key looked up in the database is trusted.
+	// Keys revoked by another process stop working after at most this long.
+	apiKeyCacheTTL = time.Minute
+
+	// maxCachedAPIKeys bounds the cache of keys found in the database
+	maxCachedAPIKeys = 10000
+
+	// maxCachedUnknownAPIKeys bounds the separate cache of keys that were not
+	// found, so requests with made-up keys cannot push out real ones
+	maxCachedUnknownAPIKeys = 1000
+
+	// apiKeyUsageDays is how many days of usage a single key is shown with
+	apiKeyUsageDays = 30
+
+	maxAPIKeyNameLength = 100
+)
+
+// apiKeyStore is the part of storage API keys and their usage are kept in
+type apiKeyStore interface {
+	CreateAPIKey(key *models.APIKey, hash []byte) error
+	APIKeyByHash(hash []byte) (*models.APIKey, error)
+	APIKey(id int64, days int) (*models.APIKey, error)
+	APIKeys() ([]models.APIKey, error)
+	CountAPIKeyUse(id int64) (int64, error)
+	RevokeAPIKey(id int64) (time.Time, error)
+}
+
+// keyCache remembers lookups by key hash, evicting the least recently used
+// entry once it holds max. It is guarded by APIKeyManager.mu.
+type keyCache struct {
+	max   int
+	order *list.List // front is most recently used
+	items map[[sha256.Size]byte]*list.Element
+}
+
+type cachedAPIKey struct {
+	hash    [sha256.Size]byte
+	key     *models.APIKey
+	expires time.Time
+}
+
+func newKeyCache(max int) *keyCache {
+	return &keyCache{max: max, order: list.New(), items: make(map[[sha256.Size]byte]*list.Element)}
+}
+
+// get returns the cached lookup of hash if it has not expired
+func (c *keyCache) get(hash [sha256.Size]byte, now time.Time) (*models.APIKey, bool) {
+	elem, ok := c.items[hash]
+	if !ok {
+		return nil, false
+	}
+	cached := elem.Value.(*cachedAPIKey)
+	if !now.Before(cached.expires) {
+		c.remove(elem)
+		return nil, false
+	}
+	c.order.MoveToFront(elem)
+	return cached.key, true
+}
+
+func (c *keyCache) put(hash [sha256.Size]byte, key *models.APIKey, expires time.Time) {
+	if elem, ok := c.items[hash]; ok {
+		cached := elem.Value.(*cachedAPIKey)
+		cached.key, cached.expires = key, expires
+		c.order.MoveToFront(elem)
+		return
+	}
+	c.items[hash] = c.order.PushFront(&cachedAPIKey{hash: hash, key: key, expires: expires})
+	if c.order.Len() > c.max {
+		c.remove(c.order.Back())
+	}
+}
+
+func (c *keyCache) delete(hash [sha256.Size]byte) {
+	if elem, ok := c.items[hash]; ok {
+		c.remove(elem)
+	}
+}
+
+// deleteID drops every cached lookup that found the key with id
+func (c *keyCache) deleteID(id int64) {
+	for _, elem := range c.items {
+		if cached := elem.Value.(*cachedAPIKey); cached.key != nil && cached.key.ID == id {
+			c.remove(elem)
+		}
+	}
+}
+
+func (c *keyCache) remove(elem *list.Element) {
+	c.order.Remove(elem)
+	delete(c.items, elem.Value.(*cachedAPIKey).hash)
+}
+
+// APIKeyManager issues and revokes API keys and checks the keys requests
+// are made with against their rate limits and daily quotas.
+//
+// Rate limits are enforced by each process on its own, while daily quotas
+// are counted in the database and shared by every process.
+type APIKeyManager struct {
+	store apiKeyStore
+	now   func() time.Time
+
+	mu        sync.Mutex
+	known     *keyCache
+	unknown   *keyCache
+	buckets   map[int64]*tokenBucket
+	exhausted map[int64]time.Time
+}
+
+// NewAPIKeyManager creates a manager keeping keys in store
+func NewAPIKeyManager(store apiKeyStore) *APIKeyManager {
+	return &APIKeyManager{
+		store:     store,
+		now:       time.Now,
+		known:     newKeyCache(maxCachedAPIKeys),
+		unknown:   newKeyCache(maxCachedUnknownAPIKeys),
+		buckets:   make(map[int64]*tokenBucket),
+		exhausted: make(map[int64]time.Time),
+	}
+}
+
+// Authenticate checks that key is a valid key within its rate limit and
+// daily quota, and counts the request against it
+func (m *APIKeyManager) Authenticate(key string) error {
+	if key == "" {
+		return apperrors.Unauthenticated("An API key is required.")
+	}
+	apiKey, err := m.lookup(sha256.Sum256([]byte(key)))
+	if err != nil {
+		return err
+	}
+	if apiKey == nil || apiKey.RevokedAt != nil {
+		return apperrors.Unauthenticated("Invalid API key.")
+	}
+
+	now := m.now()
+	m.mu.Lock()
+	// Once a key's quota is used up there is no need to count its requests
+	// until the next day
+	if until, ok := m.exhausted[apiKey.ID]; ok {
+		if now.Before(until) {
+			m.mu.Unlock()
+			return apperrors.QuotaExceeded(until.Sub(now))
+		}
+		delete(m.exhausted, apiKey.ID)
+	}
+	if apiKey.RateLimit > 0 {
+		bucket, ok := m.buckets[apiKey.ID]
+		if !ok {
+			bucket = newTokenBucket(apiKey.RateLimit, now)
+			m.buckets[apiKey.ID] = bucket
+		}
+		if wait := bucket.take(apiKey.RateLimit, now); wait > 0 {
+			m.mu.Unlock()
+			return apperrors.RateLimited(nil, wait)
+		}
+	}
+	m.mu.Unlock()
+
+	requests, err := m.store.CountAPIKeyUse(apiKey.ID)
+	if err != nil {
+		return fmt.Errorf("failed to count API key use: %w", err)
+	}
+	if apiKey.DailyQuota > 0 && requests > int64(apiKey.DailyQuota) {
+		until := nextUTCDay(now)
+		m.mu.Lock()
+		m.exhausted[apiKey.ID] = until
+		m.mu.Unlock()
+		return apperrors.QuotaExceeded(until.Sub(now))
+	}
+	return nil
+}
+
+// lookup returns the key with hash, or nil if there is none, from the
+// cache when it is fresh
+func (m *APIKeyManager) lookup(hash [sha256.Size]byte) (*models.APIKey, error) {
+	now := m.now()
+	m.mu.Lock()
+	key, ok := m.known.get(hash, now)
+	if !ok {
+		key, ok = m.unknown.get(hash, now)
+	}
+	m.mu.Unlock()
+	if ok {
+		return key, nil
+	}
+
+	key, err := m.store.APIKeyByHash(hash[:])
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up API key: %w", err)
+	}
+
+	m.mu.Lock()
+	if key == nil {
+		m.unknown.put(hash, nil, now.Add(apiKeyCacheTTL))
+	} else {
+		m.unknown.delete(hash)
+		m.known.put(hash, key, now.Add(apiKeyCacheTTL))
+	}
+	m.mu.Unlock()
+	return key, nil
+}
+
+// IssueAPIKey creates a key for the consumer name allowed rateLimit
+// requests a minute and dailyQuota a day, zero meaning unlimited. The key
+// is only ever returned here.
+func (m *APIKeyManager) IssueAPIKey(name string, rateLimit, dailyQuota int) (*models.IssuedAPIKey, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, apperrors.InvalidArgument("A name is required.")
+	}
+	if len(name) > maxAPIKeyNameLength {
+		return nil, apperrors.InvalidArgument("Name must be at most %d characters.", maxAPIKeyNameLength)
+	}
+	if rateLimit < 0 || dailyQuota < 0 {
+		return nil, apperrors.InvalidArgument("rate_limit and daily_quota must not be negative.")
+	}
+
+	key, err := generateAPIKey()
+	if err != nil {
+		return nil, err
+	}
+	issued := &models.IssuedAPIKey{
+		APIKey: models.APIKey{
+			Name:       name,
+			Prefix:     key[:len(apiKeyPrefix)+6],
+			RateLimit:  rateLimit,
+			DailyQuota: dailyQuota,
+		},
+		Key: key,
+	}
+	hash := sha256.Sum256([]byte(key))
+	if err := m.store.CreateAPIKey(&issued.APIKey, hash[:]); err != nil {
+		return nil, fmt.Errorf("failed to create API key: %w", err)
+	}
+	return issued, nil
+}
+
+// APIKeys lists every key with its requests today
+func (m *APIKeyManager) APIKeys() ([]models.APIKey, error) {
+	return m.store.APIKeys()
+}
+
+// APIKey returns a key with its recent daily usage
+func (m *APIKeyManager) APIKey(id int64) (*models.APIKey, error) {
+	key, err := m.store.APIKey(id, apiKeyUsageDays)
+	if err != nil {
+		return nil, err
+	}
+	if key == nil {
+		return nil, apperrors.NotFound("API key %d not found.", id)
+	}
+	return key, nil
+}
+
+// RevokeAPIKey stops a key from working. It is rejected by this process
+// at once, and by others once their cached copy expires.
+func (m *APIKeyManager) RevokeAPIKey(id int64) (*models.APIKey, error) {
+	key, err := m.APIKey(id)
+	if err != nil {
+		return nil, err
+	}
+	if key.RevokedAt != nil {
+		return nil, apperrors.Conflict("API key %d has already been revoked.", id)
+	}
+
+	revoked, err := m.store.RevokeAPIKey(id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to revoke API key: %w", err)
+	}
+	key.RevokedAt = &revoked
+
+	m.mu.Lock()
+	m.known.deleteID(id)
+	delete(m.buckets, id)
+	delete(m.exhausted, id)
+	m.mu.Unlock()
+	return key, nil
+}
+
+// generateAPIKey returns a new random key. Keys carry 256 bits of entropy,
+// so a plain SHA-256 hash is enough to store them safely.
+func generateAPIKey() (string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", fmt.Errorf("failed to generate API key: %w", err)
+	}
+	return apiKeyPrefix + base64.RawURLEncoding.EncodeToString(secret), nil
+}
+
+// nextUTCDay returns the midnight UTC after t, when daily quotas reset
+func nextUTCDay(t time.Time) time.Time {
+	year, month, day := t.UTC().Date()
+	return time.Date(year, month, day+1, 0, 0, 0, 0, time.UTC)
+}
+
+// tokenBucket allows a burst of up to a minute's requests, refilling at
+// the per-minute rate
+type tokenBucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+func newTokenBucket(perMinute int, now time.Time) *tokenBucket {
+	return &tokenBucket{tokens: float64(perMinute), updated: now}
+}
+
+// take uses up a token, or returns how long until one is available
+func (b *tokenBucket) take(perMinute int, now time.Time) time.Duration {
+	perSecond := float64(perMinute) / 60
+	b.tokens = math.Min(float64(perMinute), b.tokens+now.Sub(b.updated).Seconds()*perSecond)
+	b.updated = now
+	if b.tokens >= 1 {
+		b.tokens--
+		return 0
+	}
+	// Retry-After is in whole seconds, so round up to not invite a retry
+	// that is still too early
+	return time.Duration(math.Ceil((1-b.tokens)/perSecond)) * time.Second
+}
@@ -0,0 +1,251 @@
+package services
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rbungay/racedatabase-api/internal/api/runsignup/models"
+	apperrors "github.com/rbungay/racedatabase-api/pkg/errors"
+)
+
+// fakeAPIKeyStore keeps keys and today's usage in memory and counts lookups
+type fakeAPIKeyStore struct {
+	mu      sync.Mutex
+	keys    []*models.APIKey
+	hashes  [][]byte
+	lookups int
+}
+
+func (f *fakeAPIKeyStore) CreateAPIKey(key *models.APIKey, hash []byte) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	key.ID = int64(len(f.keys) + 1)
+	key.CreatedAt = time.Now()
+	stored := *key
+	f.keys = append(f.keys, &stored)
+	f.hashes = append(f.hashes, hash)
+	return nil
+}
+
+func (f *fakeAPIKeyStore) APIKeyByHash(hash []byte) (*models.APIKey, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.lookups++
+	for i, h := range f.hashes {
+		if bytes.Equal(h, hash) {
+			key := *f.keys[i]
+			return &key, nil
+		}
+	}
+	return nil, nil
+}
+
+func (f *fakeAPIKeyStore) APIKey(id int64, days int) (*models.APIKey, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if id < 1 || int(id) > len(f.keys) {
+		return nil, nil
+	}
+	key := *f.keys[id-1]
+	return &key, nil
+}
+
+func (f *fakeAPIKeyStore) APIKeys() ([]models.APIKey, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var keys []models.APIKey
+	for _, key := range f.keys {
+		keys = append(keys, *key)
+	}
+	return keys, nil
+}
+
+func (f *fakeAPIKeyStore) CountAPIKeyUse(id int64) (int64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.keys[id-1].RequestsToday++
+	return f.keys[id-1].RequestsToday, nil
+}
+
+func (f *fakeAPIKeyStore) RevokeAPIKey(id int64) (time.Time, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	now := time.Now()
+	f.keys[id-1].RevokedAt = &now
+	return now, nil
+}
+
+// newTestAPIKeyManager returns a manager whose clock only moves when the
+// returned function is called
+func newTestAPIKeyManager(store *fakeAPIKeyStore) (*APIKeyManager, func(time.Duration)) {
+	manager := NewAPIKeyManager(store)
+	now := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+	manager.now = func() time.Time { return now }
+	return manager, func(d time.Duration) { now = now.Add(d) }
+}
+
+func TestAPIKeyManager_IssueAndAuthenticate(t *testing.T) {
+	store := &fakeAPIKeyStore{}
+	manager, _ := newTestAPIKeyManager(store)
+
+	issued, err := manager.IssueAPIKey("  Race Calendar  ", 0, 0)
+	if err != nil {
+		t.Fatalf("Failed to issue key: %v", err)
+	}
+	if issued.Name != "Race Calendar" || !strings.HasPrefix(issued.Key, issued.Prefix) || !strings.HasPrefix(issued.Key, "rdb_") {
+		t.Errorf("Unexpected issued key: %+v", issued)
+	}
+	if bytes.Contains(store.hashes[0], []byte(issued.Key)) {
+		t.Errorf("Key was stored unhashed")
+	}
+
+	if err := manager.Authenticate(issued.Key); err != nil {
+		t.Errorf("Authenticate(issued key) = %v, want nil", err)
+	}
+	for _, key := range []string{"", "rdb_unknown", issued.Key + "x"} {
+		if err := manager.Authenticate(key); apperrors.KindOf(err) != apperrors.KindUnauthenticated {
+			t.Errorf("Authenticate(%q) = %v, want unauthenticated", key, err)
+		}
+	}
+}
+
+func TestAPIKeyManager_IssueValidates(t *testing.T) {
+	manager, _ := newTestAPIKeyManager(&fakeAPIKeyStore{})
+	for _, tc := range []struct {
+		name                  string
+		rateLimit, dailyQuota int
+	}{
+		{" ", 60, 100},
+		{strings.Repeat("a", maxAPIKeyNameLength+1), 60, 100},
+		{"client", -1, 100},
+		{"client", 60, -1},
+	} {
+		if _, err := manager.IssueAPIKey(tc.name, tc.rateLimit, tc.dailyQuota); apperrors.KindOf(err) != apperrors.KindInvalidArgument {
+			t.Errorf("IssueAPIKey(%q, %d, %d) = %v, want invalid argument", tc.name, tc.rateLimit, tc.dailyQuota, err)
+		}
+	}
+}
+
+func TestAPIKeyManager_CachesLookups(t *testing.T) {
+	store := &fakeAPIKeyStore{}
+	manager, advance := newTestAPIKeyManager(store)
+	issued, _ := manager.IssueAPIKey("client", 0, 0)
+
+	for i := 0; i < 3; i++ {
+		manager.Authenticate(issued.Key)
+		manager.Authenticate("rdb_unknown")
+	}
+	if store.lookups != 2 {
+		t.Errorf("Looked up keys %d times, want 2", store.lookups)
+	}
+
+	advance(apiKeyCacheTTL)
+	manager.Authenticate(issued.Key)
+	if store.lookups != 3 {
+		t.Errorf("Looked up keys %d times after the cache expired, want 3", store.lookups)
+	}
+}
+
+func TestAPIKeyManager_UnknownKeysDoNotEvictKnownOnes(t *testing.T) {
+	store := &fakeAPIKeyStore{}
+	manager, _ := newTestAPIKeyManager(store)
+	issued, _ := manager.IssueAPIKey("client", 0, 0)
+	manager.Authenticate(issued.Key)
+
+	for i := 0; i < maxCachedUnknownAPIKeys+1; i++ {
+		manager.Authenticate(fmt.Sprintf("rdb_unknown%d", i))
+	}
+	if store.lookups != maxCachedUnknownAPIKeys+2 {
+		t.Fatalf("Looked up keys %d times, want %d", store.lookups, maxCachedUnknownAPIKeys+2)
+	}
+
+	// The oldest unknown key was evicted on its own; the real key and the
+	// other unknown keys are still cached
+	manager.Authenticate(issued.Key)
+	manager.Authenticate("rdb_unknown1")
+	if store.lookups != maxCachedUnknownAPIKeys+2 {
+		t.Errorf("Looked up cached keys again, %d lookups", store.lookups)
+	}
+	manager.Authenticate("rdb_unknown0")
+	if store.lookups != maxCachedUnknownAPIKeys+3 {
+		t.Errorf("Expected the evicted unknown key to be looked up again, %d lookups", store.lookups)
+	}
+}
+
+func TestAPIKeyManager_RateLimit(t *testing.T) {
+	manager, advance := newTestAPIKeyManager(&fakeAPIKeyStore{})
+	issued, _ := manager.IssueAPIKey("client", 2, 0)
+
+	for i := 0; i < 2; i++ {
+		if err := manager.Authenticate(issued.Key); err != nil {
+			t.Fatalf("Request %d was rejected: %v", i+1, err)
+		}
+	}
+	err := manager.Authenticate(issued.Key)
+	var appErr *apperrors.Error
+	if !errors.As(err, &appErr) || appErr.Kind != apperrors.KindRateLimited || appErr.RetryAfter != 30*time.Second {
+		t.Fatalf("Third request in a minute = %v, want rate limited for 30s", err)
+	}
+
+	advance(30 * time.Second)
+	if err := manager.Authenticate(issued.Key); err != nil {
+		t.Errorf("Request after the bucket refilled was rejected: %v", err)
+	}
+}
+
+func TestAPIKeyManager_DailyQuota(t *testing.T) {
+	store := &fakeAPIKeyStore{}
+	manager, advance := newTestAPIKeyManager(store)
+	issued, _ := manager.IssueAPIKey("client", 0, 2)
+
+	for i := 0; i < 2; i++ {
+		if err := manager.Authenticate(issued.Key); err != nil {
+			t.Fatalf("Request %d was rejected: %v", i+1, err)
+		}
+	}
+	for i := 0; i < 3; i++ {
+		err := manager.Authenticate(issued.Key)
+		var appErr *apperrors.Error
+		if !errors.As(err, &appErr) || appErr.Kind != apperrors.KindRateLimited || appErr.RetryAfter != 12*time.Hour {
+			t.Fatalf("Request over quota = %v, want rate limited until midnight", err)
+		}
+	}
+	if store.keys[0].RequestsToday != 3 {
+		t.Errorf("Counted %d requests, want requests over quota counted once", store.keys[0].RequestsToday)
+	}
+
+	// The store starts a new day's count at midnight
+	advance(12 * time.Hour)
+	store.keys[0].RequestsToday = 0
+	if err := manager.Authenticate(issued.Key); err != nil {
+		t.Errorf("Request on the next day was rejected: %v", err)
+	}
+}
+
+func TestAPIKeyManager_Revoke(t *testing.T) {
+	manager, _ := newTestAPIKeyManager(&fakeAPIKeyStore{})
+	issued, _ := manager.IssueAPIKey("client", 0, 0)
+	if err := manager.Authenticate(issued.Key); err != nil {
+		t.Fatalf("Authenticate(issued key) = %v, want nil", err)
+	}
+
+	revoked, err := manager.RevokeAPIKey(issued.ID)
+	if err != nil || revoked.RevokedAt == nil {
+		t.Fatalf("RevokeAPIKey = %+v, %v", revoked, err)
+	}
+	if err := manager.Authenticate(issued.Key); apperrors.KindOf(err) != apperrors.KindUnauthenticated {
+		t.Errorf("Authenticate(revoked key) = %v, want unauthenticated", err)
+	}
+
+	if _, err := manager.RevokeAPIKey(issued.ID); apperrors.KindOf(err) != apperrors.KindConflict {
+		t.Errorf("Revoking twice = %v, want conflict", err)
+	}
+	if _, err := manager.RevokeAPIKey(99); apperrors.KindOf(err) != apperrors.KindNotFound {
+		t.Errorf("Revoking an unknown key = %v, want not found", err)
+	}
+}
@@ -0,0 +1,96 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/rbungay/racedatabase-api/config"
+	"github.com/rbungay/racedatabase-api/internal/api/runsignup/models"
+)
+
+const (
+	// A failed race is retried after retryBaseDelay, doubling with every
+	// attempt up to retryMaxDelay
+	retryBaseDelay = 5 * time.Minute
+	retryMaxDelay  = 24 * time.Hour
+
+	defaultMaxIngestAttempts = 8
+)
+
+// ingestStore is the part of storage that stores races and queues the ones
+// that fail for a retry
+type ingestStore interface {
+	SaveRace(race *models.RaceDetails) error
+	SaveRaces(races []*models.RaceDetails) error
+	RecordFailedIngest(race models.Event, stage string, ingestErr error, baseDelay, maxDelay time.Duration) error
+	ResolveFailedIngests(raceIDs []int) error
+}
+
+// retryStore adds the retry queue itself
+type retryStore interface {
+	ingestStore
+	DueFailedIngests(maxAttempts, limit int) ([]models.FailedIngest, error)
+}
+
+// maxIngestAttempts is how many times a race is tried before it is left for
+// someone to look at, configured by FAILED_INGEST_MAX_ATTEMPTS
+func maxIngestAttempts() int {
+	attempts, err := strconv.Atoi(config.GetEnv("FAILED_INGEST_MAX_ATTEMPTS", ""))
+	if err != nil || attempts < 1 {
+		return defaultMaxIngestAttempts
+	}
+	return attempts
+}
+
+func queueFailedIngest(store ingestStore, event models.Event, stage string, ingestErr error) {
+	if err := store.RecordFailedIngest(event, stage, ingestErr, retryBaseDelay, retryMaxDelay); err != nil {
+		fmt.Printf("Failed to queue race %d for a retry: %v\n", event.ID, err)
+	}
+}
+
+// RetryFailedIngests retries up to limit queued races whose backoff has
+// elapsed
+func RetryFailedIngests(ctx context.Context, limit int) (*models.RetryResult, error) {
+	store, err := syncStorage()
+	if err != nil {
+		return nil, err
+	}
+	return retryFailedIngests(ctx, store, limit)
+}
+
+func retryFailedIngests(ctx context.Context, store retryStore, limit int) (*models.RetryResult, error) {
+	due, err := dueFailedIngests(store, limit)
+	if err != nil {
+		return nil, err
+	}
+	return retryIngests(ctx, store, due)
+}
+
+// dueFailedIngests lists up to limit queued races whose backoff has elapsed
+func dueFailedIngests(store retryStore, limit int) ([]models.FailedIngest, error) {
+	due, err := store.DueFailedIngests(maxIngestAttempts(), limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list races due for a retry: %w", err)
+	}
+	return due, nil
+}
+
+// retryIngests tries to store each queued race again, stopping early when
+// ctx is cancelled
+func retryIngests(ctx context.Context, store retryStore, due []models.FailedIngest) (*models.RetryResult, error) {
+	result := &models.RetryResult{}
+	for _, failure := range due {
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+		result.Retried++
+		if err := storeRace(store, failure.Race); err != nil {
+			result.Failed++
+		} else {
+			result.Recovered++
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,147 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/rbungay/racedatabase-api/config"
+	"github.com/rbungay/racedatabase-api/internal/api/runsignup/models"
+)
+
+func TestStoreRace_QueuesFailuresForRetry(t *testing.T) {
+	healthy := false
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !healthy {
+			http.Error(w, "API Error", http.StatusInternalServerError)
+			return
+		}
+		mockRaceDetailsAPI(w, r)
+	}))
+	defer mockServer.Close()
+
+	originalAPIURL := config.GetEnv("RUNSIGNUP_API_URL", "")
+	os.Setenv("RUNSIGNUP_API_URL", mockServer.URL)
+	defer os.Setenv("RUNSIGNUP_API_URL", originalAPIURL)
+
+	store := newFakeSyncStore()
+	event := models.Event{ID: 12345, Name: "Test Race"}
+
+	for i := 0; i < 2; i++ {
+		if err := storeRace(store, event); err == nil {
+			t.Fatalf("Expected the failing upstream to fail the race")
+		}
+	}
+	failure, ok := store.failed[12345]
+	if !ok || failure.Stage != models.IngestStageFetch || failure.Attempts != 2 {
+		t.Fatalf("Expected the race to be queued after two attempts, got %+v", failure)
+	}
+
+	healthy = true
+	result, err := retryFailedIngests(context.Background(), store, 10)
+	if err != nil {
+		t.Fatalf("retryFailedIngests failed: %v", err)
+	}
+	if result.Retried != 1 || result.Recovered != 1 || result.Failed != 0 {
+		t.Errorf("Unexpected retry result: %+v", result)
+	}
+	if len(store.failed) != 0 || store.saves != 1 {
+		t.Errorf("Expected the recovered race to be saved and dequeued: %d queued, %d saves", len(store.failed), store.saves)
+	}
+}
+
+func TestStoreRace_QueuedErrorHasNoCredentials(t *testing.T) {
+	for name, value := range map[string]string{
+		"RUNSIGNUP_API_URL":    "http://127.0.0.1:1",
+		"RUNSIGNUP_API_KEY":    "KEY123",
+		"RUNSIGNUP_API_SECRET": "SECRET456",
+	} {
+		original := config.GetEnv(name, "")
+		os.Setenv(name, value)
+		defer os.Setenv(name, original)
+	}
+
+	store := newFakeSyncStore()
+	err := storeRace(store, models.Event{ID: 12345})
+	if err == nil {
+		t.Fatalf("Expected the unreachable upstream to fail the race")
+	}
+
+	failure := store.failed[12345]
+	if failure.Error == "" {
+		t.Fatalf("Expected the race to be queued with its error")
+	}
+	for _, text := range []string{failure.Error, err.Error()} {
+		if strings.Contains(text, "KEY123") || strings.Contains(text, "SECRET456") || strings.Contains(text, "api_secret") {
+			t.Errorf("Credentials leaked into %q", text)
+		}
+	}
+}
+
+func TestStoreRace_RemovedRacesAreNotQueued(t *testing.T) {
+	mockServer := httptest.NewServer(http.HandlerFunc(mockRemovedRaceAPI))
+	defer mockServer.Close()
+
+	originalAPIURL := config.GetEnv("RUNSIGNUP_API_URL", "")
+	os.Setenv("RUNSIGNUP_API_URL", mockServer.URL)
+	defer os.Setenv("RUNSIGNUP_API_URL", originalAPIURL)
+
+	store := newFakeSyncStore()
+	store.failed[222] = models.FailedIngest{RaceID: 222, Attempts: 3}
+
+	err := storeRace(store, models.Event{ID: 222})
+	if err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Fatalf("Expected a not found error, got %v", err)
+	}
+	if _, queued := store.failed[222]; queued {
+		t.Errorf("Expected a race removed upstream to leave the retry queue")
+	}
+}
+
+// failingBatchStore fails every batch save and the first single save
+type failingBatchStore struct {
+	*fakeSyncStore
+	singleSaves int
+}
+
+func (f *failingBatchStore) SaveRaces(races []*models.RaceDetails) error {
+	return errors.New("batch failed")
+}
+
+func (f *failingBatchStore) SaveRace(race *models.RaceDetails) error {
+	f.singleSaves++
+	if f.singleSaves == 1 {
+		return errors.New("save failed")
+	}
+	return f.fakeSyncStore.SaveRace(race)
+}
+
+func TestStoreRaces_FallsBackToSingleSaves(t *testing.T) {
+	mockServer := httptest.NewServer(http.HandlerFunc(mockRaceDetailsAPI))
+	defer mockServer.Close()
+
+	originalAPIURL := config.GetEnv("RUNSIGNUP_API_URL", "")
+	os.Setenv("RUNSIGNUP_API_URL", mockServer.URL)
+	defer os.Setenv("RUNSIGNUP_API_URL", originalAPIURL)
+
+	store := &failingBatchStore{fakeSyncStore: newFakeSyncStore()}
+	saved, failed, err := storeRaces(context.Background(), store, []models.Event{{ID: 1}, {ID: 2}})
+	if err != nil {
+		t.Fatalf("storeRaces failed: %v", err)
+	}
+
+	if saved != 1 || failed != 1 {
+		t.Errorf("Expected 1 race saved and 1 failed, got %d and %d", saved, failed)
+	}
+	failure, queued := store.failed[1]
+	if !queued || failure.Stage != models.IngestStageSave {
+		t.Errorf("Expected only the race that failed to save to be queued, got %+v", store.failed)
+	}
+	if _, queued := store.failed[2]; queued {
+		t.Errorf("Expected the saved race to stay out of the retry queue")
+	}
+}
@@ -1,47 +1,60 @@
 package services
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"io/ioutil"
-	"net/http"
 	"net/url"
+	"strconv"
 	"sync"
-	"time"
 
 	"github.com/rbungay/racedatabase-api/config"
 	"github.com/rbungay/racedatabase-api/internal/api/runsignup/constants"
 	"github.com/rbungay/racedatabase-api/internal/api/runsignup/models"
+	apperrors "github.com/rbungay/racedatabase-api/pkg/errors"
 	"github.com/rbungay/racedatabase-api/internal/api/runsignup/storage"
 )
 
 func FetchEvents(state, city, eventType, startDate, endDate, minDistance, maxDistance, zipcode, radius string) ([]models.Event,error){
+	if eventType != "" && !constants.ValidEventTypes[eventType] {
+		return nil, apperrors.InvalidArgument("Invalid event_type: %s.", eventType)
+	}
+
+	eventTypes := make([]string, 0, len(constants.ValidEventTypes))
+	for eventType := range constants.ValidEventTypes {
+		eventTypes = append(eventTypes, eventType)
+	}
+
+	// Without a database events are only fetched
+	return fetchEvents(sharedStorage, eventTypes, state, city, startDate, endDate, minDistance, maxDistance, zipcode, radius, "")
+}
+
+// fetchEvents queries RunSignup for each event type concurrently and, when
+// supabaseStorage is set, stores the details of every race returned. A
+// non-empty modifiedSince only returns races changed since that time.
+//
+// It only reads the first page of each event type, so it never looks for
+// races removed upstream; sync jobs do that after a complete pass.
+func fetchEvents(supabaseStorage *storage.SupabaseStorage, eventTypes []string, state, city, startDate, endDate, minDistance, maxDistance, zipcode, radius, modifiedSince string) ([]models.Event, error) {
 	var wg sync.WaitGroup
 	var mu sync.Mutex
 	var allEvents []models.Event
-	var errorList []error
+	var failures []EventTypeFailure
 
-	// Initialize Supabase storage
-	dbURL := config.GetEnv("SUPABASE_DB_URL", "")
-	supabaseStorage, err := storage.NewSupabaseStorage(dbURL)
-	if err != nil {
-		return nil, fmt.Errorf("failed to initialize Supabase storage: %w", err)
-	}
+	// A race listed under several event types is only stored once per sync
+	stored := make(map[int]bool)
 
-	if eventType != "" && !constants.ValidEventTypes[eventType] {
-		return nil, fmt.Errorf("invalid event_type: %s. Must be one of: %v", eventType, constants.ValidEventTypes)
-	}
-
-	for eventType := range constants.ValidEventTypes{
+	for _, eventType := range eventTypes {
 		wg.Add(1)
 		go func(eventType string){
 			defer wg.Done()
 			fmt.Println("Fetching event type:", eventType)
 
-			events, err := fetchEventsFromAPI(state,city,eventType, startDate, endDate, minDistance, maxDistance, zipcode, radius)
+			events, err := fetchEventsFromAPI(state,city,eventType, startDate, endDate, minDistance, maxDistance, zipcode, radius, modifiedSince)
 			if err != nil{
 				mu.Lock()
-				errorList = append(errorList, fmt.Errorf("%s: %v", eventType, err))
+				failures = append(failures, EventTypeFailure{EventType: eventType, Err: err})
 				mu.Unlock()
 				return
 			}
@@ -50,51 +63,151 @@ func FetchEvents(state, city, eventType, startDate, endDate, minDistance, maxDis
 			allEvents = append(allEvents,events...)
 			mu.Unlock()
 
-			// Store each event's details in Supabase
-			for _, event := range events {
-				raceDetails, err := FetchRaceDetails(event.ID)
-				if err != nil {
-					fmt.Printf("Failed to fetch details for race %d: %v\n", event.ID, err)
-					continue
-				}
+			if supabaseStorage == nil {
+				return
+			}
 
-				if err := supabaseStorage.SaveRace(raceDetails); err != nil {
-					fmt.Printf("Failed to store race %d in Supabase: %v\n", event.ID, err)
-				} else {
-					fmt.Printf("Successfully stored race %d in Supabase\n", event.ID)
+			// Store the details of each race not stored yet in Supabase
+			fresh := make([]models.Event, 0, len(events))
+			mu.Lock()
+			for _, event := range events {
+				if !stored[event.ID] {
+					stored[event.ID] = true
+					fresh = append(fresh, event)
 				}
 			}
+			mu.Unlock()
+			storeRaces(context.Background(), supabaseStorage, fresh)
 		}(eventType)
 	}
 
 	wg.Wait()
 
-	if len(errorList)>0 {
-		return allEvents, fmt.Errorf("some event types failed to fetch: %v", errorList)
+	if len(failures) > 0 {
+		partial := newPartialError(failures)
+		if len(failures) == len(eventTypes) {
+			// Nothing succeeded, so report the failure itself
+			return nil, partial.Unwrap()
+		}
+		return allEvents, partial
 	}
 
 	return allEvents, nil
 }
 
-func fetchEventsFromAPI(state, city, eventType, startDate, endDate, minDistance, maxDistance, zipcode, radius string) ([]models.Event, error) {
-	apiURL := config.GetEnv("RUNSIGNUP_API_URL", "")
-	apiKey := config.GetEnv("RUNSIGNUP_API_KEY", "")
-	apiSecret := config.GetEnv("RUNSIGNUP_API_SECRET", "")
+// storeRace fetches the details of a race returned by a search and saves
+// them. Failures are logged, returned and queued for a retry, except for
+// races RunSignup no longer has, which are dropped from the queue.
+func storeRace(store ingestStore, event models.Event) error {
+	raceDetails, err := fetchRaceToStore(store, event)
+	if err != nil {
+		return err
+	}
+	return saveRace(store, event, raceDetails)
+}
 
+// storeRaces fetches the details of several races returned by a search and
+// saves them in one batch. If the batch fails each race is saved on its own,
+// so only the races at fault are queued for a retry. It returns how many
+// races were saved and how many failed. Cancelling ctx stops fetching, saves
+// the races fetched so far and returns ctx's error.
+func storeRaces(ctx context.Context, store ingestStore, events []models.Event) (int, int, error) {
+	var (
+		stopped error
+		failed  int
+	)
+	fetched := make([]models.Event, 0, len(events))
+	batch := make([]*models.RaceDetails, 0, len(events))
+	for _, event := range events {
+		if stopped = ctx.Err(); stopped != nil {
+			break
+		}
+		raceDetails, err := fetchRaceToStore(store, event)
+		if err != nil {
+			failed++
+			continue
+		}
+		fetched = append(fetched, event)
+		batch = append(batch, raceDetails)
+	}
+	if len(batch) == 0 {
+		return 0, failed, stopped
+	}
+
+	if err := store.SaveRaces(batch); err != nil {
+		fmt.Printf("Failed to store a batch of %d races in Supabase, storing them one at a time: %v\n", len(batch), err)
+		saved := 0
+		for i, raceDetails := range batch {
+			if err := saveRace(store, fetched[i], raceDetails); err != nil {
+				failed++
+			} else {
+				saved++
+			}
+		}
+		return saved, failed, stopped
+	}
+	fmt.Printf("Successfully stored %d races in Supabase\n", len(batch))
+
+	raceIDs := make([]int, len(fetched))
+	for i, event := range fetched {
+		raceIDs[i] = event.ID
+		invalidateRace(event.ID)
+	}
+	if err := store.ResolveFailedIngests(raceIDs); err != nil {
+		fmt.Printf("Failed to clear %d races from the retry queue: %v\n", len(raceIDs), err)
+	}
+	return len(batch), failed, stopped
+}
+
+// fetchRaceToStore fetches the details of a race about to be stored,
+// queueing it for a retry if that fails
+func fetchRaceToStore(store ingestStore, event models.Event) (*models.RaceDetails, error) {
+	raceDetails, err := FetchRaceDetails(event.ID)
+	if err != nil {
+		fmt.Printf("Failed to fetch details for race %d: %v\n", event.ID, err)
+		if errors.Is(err, ErrRaceNotFound) {
+			// Nothing left to retry
+			store.ResolveFailedIngests([]int{event.ID})
+		} else {
+			queueFailedIngest(store, event, models.IngestStageFetch, err)
+		}
+		return nil, err
+	}
+	fillFromSummary(raceDetails, event)
+	geocode(raceDetails)
+	return raceDetails, nil
+}
+
+// saveRace saves the details of one race, queueing it for a retry if that
+// fails
+func saveRace(store ingestStore, event models.Event, raceDetails *models.RaceDetails) error {
+	if err := store.SaveRace(raceDetails); err != nil {
+		fmt.Printf("Failed to store race %d in Supabase: %v\n", event.ID, err)
+		queueFailedIngest(store, event, models.IngestStageSave, err)
+		return err
+	}
+	fmt.Printf("Successfully stored race %d in Supabase\n", event.ID)
+	invalidateRace(event.ID)
+
+	if err := store.ResolveFailedIngests([]int{event.ID}); err != nil {
+		fmt.Printf("Failed to clear race %d from the retry queue: %v\n", event.ID, err)
+	}
+	return nil
+}
+
+func fetchEventsFromAPI(state, city, eventType, startDate, endDate, minDistance, maxDistance, zipcode, radius, modifiedSince string) ([]models.Event, error) {
 	params := url.Values{}
-	params.Set("api_key", apiKey)               
-	params.Set("api_secret", apiSecret)         
 	params.Set("format", "json")                
       
 	if state != "" {
 		params.Set("state", state)
 	} else {
-		return nil, fmt.Errorf("state paramater is required")
+		return nil, apperrors.InvalidArgument("state parameter is required")
 	}
 
 	if eventType != "" {
 		if _, isValid := constants.ValidEventTypes[eventType]; ! isValid {
-			return nil, fmt.Errorf("invalid event_type: %s. Must be one of: %v", eventType, constants.ValidEventTypes)
+			return nil, apperrors.InvalidArgument("Invalid event_type: %s.", eventType)
 		} 
 		params.Set("event_type", eventType)
 	}
@@ -121,35 +234,44 @@ func fetchEventsFromAPI(state, city, eventType, startDate, endDate, minDistance,
 	if radius != "" {
 		params.Set("radius", radius) 
 	}
-
-	fullURL := fmt.Sprintf("%s/races?%s", apiURL, params.Encode())
-	fmt.Println("Making API request to:", fullURL) 
-
-	client := &http.Client{Timeout: 10 * time.Second}
-	req, err := http.NewRequest("GET", fullURL, nil)
-	if err != nil {
-		return nil, fmt.Errorf("failed git to create request: %w", err)
+	if modifiedSince != "" {
+		params.Set("modified_since", modifiedSince)
 	}
-	
-	resp, err := client.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("request failed: %w", err)
-	}
-	defer resp.Body.Close()
 
-	body, err := ioutil.ReadAll(resp.Body)
+	body, err := runSignupGet("/races", params)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read response: %w", err)
-	}
-	
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("API error: status %d - response: %s", resp.StatusCode, string(body))
+		return nil, err
 	}
-	
+
 	if config.GetEnv("ENV", "development") == "development" {
 		// fmt.Println("Raw API Response:", string(body))
 	}
-	
+	return parseEvents(body, eventType)
+}
+
+// fetchRacePage fetches one page of a state's races of an event type, for
+// syncs that checkpoint page by page. Pages are numbered from 1.
+func fetchRacePage(state, eventType, modifiedSince string, page, pageSize int) ([]models.Event, error) {
+	params := url.Values{}
+	params.Set("format", "json")
+	params.Set("state", state)
+	params.Set("event_type", eventType)
+	params.Set("page", strconv.Itoa(page))
+	params.Set("results_per_page", strconv.Itoa(pageSize))
+	if modifiedSince != "" {
+		params.Set("modified_since", modifiedSince)
+	}
+
+	body, err := runSignupGet("/races", params)
+	if err != nil {
+		return nil, err
+	}
+	return parseEvents(body, eventType)
+}
+
+// parseEvents reads the races of a RunSignup /races response, categorised
+// by their event type or, when RunSignup omits it, the requested one
+func parseEvents(body []byte, eventType string) ([]models.Event, error) {
 	var data struct {
 		Races []struct {
 			Race struct {
@@ -159,13 +281,14 @@ func fetchEventsFromAPI(state, city, eventType, startDate, endDate, minDistance,
 				ExternalURL string `json:"external_race_url"`
 				EventType string `json:"event_type"`
 				LogoURL string `json:"logo_url"`
+				NextDate string `json:"next_date"`
+				Address raceAddress `json:"address"`
 			} `json:"race"`
 		} `json:"races"`
 	}
 
-	err = json.Unmarshal(body, &data)
-	if err != nil {
-		return nil, fmt.Errorf("failed to parse JSON: %w", err)
+	if err := json.Unmarshal(body, &data); err != nil {
+		return nil, apperrors.UpstreamUnavailable(fmt.Errorf("failed to parse JSON: %w", err))
 	}
 	
 	var events []models.Event
@@ -188,6 +311,8 @@ func fetchEventsFromAPI(state, city, eventType, startDate, endDate, minDistance,
 			ExternalURL: race.Race.ExternalURL,
 			LogoURL:     race.Race.LogoURL,
 			Category:    category,
+			NextDate:    race.Race.NextDate,
+			Location:    race.Race.Address.location(),
 		})
 	}
 
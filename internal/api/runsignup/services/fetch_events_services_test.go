@@ -1,12 +1,14 @@
 package services
 
 import (
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"testing"
 	"os"
 
 	"github.com/rbungay/racedatabase-api/config"
+	"github.com/rbungay/racedatabase-api/internal/api/runsignup/models"
 )
 
 func mockRunSignupAPI(w http.ResponseWriter, r *http.Request) {
@@ -19,7 +21,16 @@ func mockRunSignupAPI(w http.ResponseWriter, r *http.Request) {
 				"url": "https://example.com",
 				"external_race_url": "https://external-example.com",
 				"logo_url": "https://example.com/logo.png",
-				"event_type": "running_race"
+				"event_type": "running_race",
+				"next_date": "06/01/2025",
+				"address": {
+					"street": "1 Main St",
+					"street2": "Suite 2",
+					"city": "New York",
+					"state": "ny",
+					"zipcode": "10001",
+					"country_code": "US"
+				}
 			}
 		}]
 	}`
@@ -60,6 +71,46 @@ func TestFetchEvents_Success(t *testing.T) {
 	if events[0].LogoURL != expectedLogoURL {
 		t.Errorf("Unexpected logo URL: got %v, want %v", events[0].LogoURL, expectedLogoURL)
 	}
+
+	expectedLocation := models.Location{Address: "1 Main St, Suite 2", City: "New York", State: "NY", PostalCode: "10001"}
+	if events[0].Location != expectedLocation {
+		t.Errorf("Unexpected location: got %+v, want %+v", events[0].Location, expectedLocation)
+	}
+	if events[0].NextDate != "06/01/2025" {
+		t.Errorf("Unexpected next date: got %v, want %v", events[0].NextDate, "06/01/2025")
+	}
+}
+
+func TestFillFromSummary(t *testing.T) {
+	details := &models.RaceDetails{ID: 12345, Location: models.Location{State: "NY"}}
+	summary := models.Event{
+		ID:       12345,
+		Category: "Runs",
+		NextDate: "06/01/2025",
+		Location: models.Location{City: "New York", State: "NY", PostalCode: "10001"},
+	}
+
+	fillFromSummary(details, summary)
+
+	if details.Category != "Runs" || details.NextDate != "06/01/2025" {
+		t.Errorf("Unexpected summary fields: category %q, next date %q", details.Category, details.NextDate)
+	}
+	if details.City != "New York" || details.PostalCode != "10001" {
+		t.Errorf("Unexpected location: %+v", details.Location)
+	}
+}
+
+func TestGeocode(t *testing.T) {
+	details := &models.RaceDetails{Location: models.Location{PostalCode: "10001"}}
+
+	geocode(details)
+
+	if details.Latitude == nil || details.Longitude == nil {
+		t.Fatalf("Expected coordinates for 10001, got none")
+	}
+	if *details.Latitude < 40 || *details.Latitude > 41 || *details.Longitude > -73 || *details.Longitude < -75 {
+		t.Errorf("Unexpected coordinates for 10001: %v, %v", *details.Latitude, *details.Longitude)
+	}
 }
 
 func TestFetchEvents_Failure(t *testing.T) {
@@ -78,6 +129,12 @@ func TestFetchEvents_Failure(t *testing.T) {
 	if len(events) != 0 {
 		t.Errorf("Expected no events on failure, but got %d", len(events))
 	}
+
+	// When every event type fails there are no partial results to return
+	var partial *PartialError
+	if errors.As(err, &partial) {
+		t.Errorf("Expected a plain error when every event type fails, got %v", err)
+	}
 }
 
 func TestFetchEvents_InvalidEventType(t *testing.T) {
@@ -122,8 +179,12 @@ func TestFetchEvents_PartialFailure(t *testing.T) {
 
 	events, err := FetchEvents("CA", "", "", "", "", "", "", "", "")
 
-	if err == nil {
-		t.Fatalf("Expected an error due to partial failure but got none")
+	var partial *PartialError
+	if !errors.As(err, &partial) {
+		t.Fatalf("Expected a PartialError due to partial failure but got %v", err)
+	}
+	if len(partial.Failures) != 1 || partial.Failures[0].EventType != "running_race" {
+		t.Errorf("Unexpected failed event types: %+v", partial.Failures)
 	}
 	if len(events) == 0 {
 		t.Errorf("Expected some successful events, got none")
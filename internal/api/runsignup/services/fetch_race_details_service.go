@@ -2,44 +2,35 @@ package services
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
-	"io/ioutil"
-	"net/http"
-	"time"
+	"net/url"
 
-	"github.com/rbungay/racedatabase-api/config"
 	"github.com/rbungay/racedatabase-api/internal/api/runsignup/constants"
 	"github.com/rbungay/racedatabase-api/internal/api/runsignup/models"
+	apperrors "github.com/rbungay/racedatabase-api/pkg/errors"
 )
 
-func FetchRaceDetails(raceID int) (*models.RaceDetails, error) {
-	apiURL := config.GetEnv("RUNSIGNUP_API_URL", "")
-	apiKey := config.GetEnv("RUNSIGNUP_API_KEY", "")
-	apiSecret := config.GetEnv("RUNSIGNUP_API_SECRET", "")
-
-	fullURL := fmt.Sprintf("%s/race/%d?api_key=%s&api_secret=%s&format=json", apiURL, raceID, apiKey, apiSecret)
+// ErrRaceNotFound is returned when RunSignup has no race with the requested ID
+var ErrRaceNotFound = errors.New("race not found")
 
-	fmt.Println("Fetching race details from:", fullURL)
+func raceDetailsEndpoint(raceID int) string {
+	return fmt.Sprintf("/race/%d", raceID)
+}
 
-	client := &http.Client{Timeout: 10 * time.Second}
-	req, err := http.NewRequest("GET", fullURL, nil)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
-	}
+func raceDetailsParams() url.Values {
+	params := url.Values{}
+	params.Set("format", "json")
+	return params
+}
 
-	resp, err := client.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("request failed: %w", err)
+func FetchRaceDetails(raceID int) (*models.RaceDetails, error) {
+	body, err := runSignupGet(raceDetailsEndpoint(raceID), raceDetailsParams())
+	if errors.Is(err, errUpstreamNotFound) {
+		return nil, apperrors.Wrap(apperrors.KindNotFound, ErrRaceNotFound, fmt.Sprintf("Race %d not found.", raceID))
 	}
-	defer resp.Body.Close()
-
-	body, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read response: %w", err)
-	}
-
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("API error: status %d - response: %s", resp.StatusCode, string(body))
+		return nil, err
 	}
 
 	var data struct {
@@ -50,6 +41,8 @@ func FetchRaceDetails(raceID int) (*models.RaceDetails, error) {
 			ExternalURL string `json:"external_race_url"`
 			LogoURL    string `json:"logo_url"`
 			Timezone   string `json:"timezone"`
+			NextDate   string `json:"next_date"`
+			Address    raceAddress `json:"address"`
 			Events     []struct {
 				EventID       int    `json:"event_id"`
 				Name          string `json:"name"`
@@ -70,13 +63,18 @@ func FetchRaceDetails(raceID int) (*models.RaceDetails, error) {
 
 	err = json.Unmarshal(body, &data)
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse JSON: %w", err)
+		invalidateRace(raceID)
+		return nil, apperrors.UpstreamUnavailable(fmt.Errorf("failed to parse JSON: %w", err))
 	}
 
 	fmt.Printf("📌 Parsed Race Details: %+v\n", data)
 
+	// RunSignup reports missing races with an error object, so a body
+	// without a race is a bad response rather than proof the race is gone.
+	// Like a body that does not parse, it is not kept in the cache.
 	if data.Race.ID == 0 {
-		return nil, fmt.Errorf("no race data found")
+		invalidateRace(raceID)
+		return nil, apperrors.UpstreamUnavailable(fmt.Errorf("response for race %d has no race", raceID))
 	}
 
 	raceDetails := &models.RaceDetails{
@@ -86,6 +84,8 @@ func FetchRaceDetails(raceID int) (*models.RaceDetails, error) {
 		ExternalURL: data.Race.ExternalURL,
 		LogoURL:    data.Race.LogoURL,
 		Timezone:   data.Race.Timezone,
+		NextDate:   data.Race.NextDate,
+		Location:   data.Race.Address.location(),
 		Events:     []models.EventDetails{},
 	}
 
@@ -1,12 +1,15 @@
 package services
 
 import (
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"testing"
 	"os"
+	"sync/atomic"
 
 	"github.com/rbungay/racedatabase-api/config"
+	apperrors "github.com/rbungay/racedatabase-api/pkg/errors"
 )
 
 func mockRaceDetailsAPI(w http.ResponseWriter, r *http.Request) {
@@ -78,3 +81,48 @@ func TestFetchRaceDetails_Success(t *testing.T) {
 		t.Errorf("Unexpected timezone: got %v, want %v", raceDetails.Timezone, expectedTimezone)
 	}
 }
+
+func TestFetchRaceDetails_NotFound(t *testing.T) {
+	mockServer := httptest.NewServer(http.HandlerFunc(mockRemovedRaceAPI))
+	defer mockServer.Close()
+
+	originalAPIURL := config.GetEnv("RUNSIGNUP_API_URL", "")
+	os.Setenv("RUNSIGNUP_API_URL", mockServer.URL)
+	defer os.Setenv("RUNSIGNUP_API_URL", originalAPIURL)
+
+	_, err := FetchRaceDetails(222)
+	if !errors.Is(err, ErrRaceNotFound) {
+		t.Errorf("Expected ErrRaceNotFound, got %v", err)
+	}
+}
+
+func TestFetchRaceDetails_ErrorBodies(t *testing.T) {
+	for _, body := range []string{
+		`{"error": {"error_code": 6, "error_msg": "Invalid API key."}}`,
+		`{"error": "Too many requests."}`,
+		`{}`,
+	} {
+		var calls atomic.Int32
+		mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			calls.Add(1)
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(body))
+		}))
+
+		originalAPIURL := config.GetEnv("RUNSIGNUP_API_URL", "")
+		os.Setenv("RUNSIGNUP_API_URL", mockServer.URL)
+
+		for i := 0; i < 2; i++ {
+			_, err := FetchRaceDetails(333)
+			if errors.Is(err, ErrRaceNotFound) || apperrors.KindOf(err) != apperrors.KindUpstreamUnavailable {
+				t.Errorf("Body %s: got %v, want upstream unavailable", body, err)
+			}
+		}
+		if calls.Load() != 2 {
+			t.Errorf("Body %s: RunSignup was called %d times, want the error not cached", body, calls.Load())
+		}
+
+		os.Setenv("RUNSIGNUP_API_URL", originalAPIURL)
+		mockServer.Close()
+	}
+}
@@ -0,0 +1,42 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// EventTypeFailure is a RunSignup query for one event type that failed
+type EventTypeFailure struct {
+	EventType string
+	Err       error
+}
+
+// PartialError is returned by FetchEvents alongside the events that were
+// fetched when some, but not all, event types failed
+type PartialError struct {
+	Failures []EventTypeFailure
+}
+
+func (e *PartialError) Error() string {
+	types := make([]string, 0, len(e.Failures))
+	for _, failure := range e.Failures {
+		types = append(types, failure.EventType)
+	}
+	return fmt.Sprintf("some event types failed to fetch (%s): %v", strings.Join(types, ", "), e.Unwrap())
+}
+
+func (e *PartialError) Unwrap() error {
+	errs := make([]error, 0, len(e.Failures))
+	for _, failure := range e.Failures {
+		errs = append(errs, failure.Err)
+	}
+	return errors.Join(errs...)
+}
+
+// newPartialError orders failures by event type so warnings are stable
+func newPartialError(failures []EventTypeFailure) *PartialError {
+	sort.Slice(failures, func(i, j int) bool { return failures[i].EventType < failures[j].EventType })
+	return &PartialError{Failures: failures}
+}
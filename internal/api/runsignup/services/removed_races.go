@@ -0,0 +1,55 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/rbungay/racedatabase-api/config"
+)
+
+// raceTracker is the part of storage used to detect races removed upstream
+type raceTracker interface {
+	MarkRacesSeen(state string, raceIDs []int) error
+	FlagUnseenRaces(state string, seenIDs []int, threshold int) ([]int, error)
+	SoftDeleteRace(raceID int) error
+}
+
+// removalThreshold is the number of consecutive syncs a race may be missing
+// from before it is flagged, configured by REMOVAL_MISSED_SYNCS
+func removalThreshold() int {
+	threshold, err := strconv.Atoi(config.GetEnv("REMOVAL_MISSED_SYNCS", "3"))
+	if err != nil || threshold < 1 {
+		return 3
+	}
+	return threshold
+}
+
+// detectRemovedRaces records which races a full sync of state returned, flags
+// the ones that have gone missing and soft-deletes those RunSignup confirms
+// no longer exist
+func detectRemovedRaces(tracker raceTracker, state string, seenIDs []int) error {
+	if err := tracker.MarkRacesSeen(state, seenIDs); err != nil {
+		return fmt.Errorf("failed to mark races seen: %w", err)
+	}
+
+	flagged, err := tracker.FlagUnseenRaces(state, seenIDs, removalThreshold())
+	if err != nil {
+		return fmt.Errorf("failed to flag unseen races: %w", err)
+	}
+
+	for _, raceID := range flagged {
+		// A cached success from before the race disappeared would hide the removal
+		invalidateRace(raceID)
+		_, err := FetchRaceDetails(raceID)
+		if !errors.Is(err, ErrRaceNotFound) {
+			continue
+		}
+		if err := tracker.SoftDeleteRace(raceID); err != nil {
+			return fmt.Errorf("failed to soft-delete race %d: %w", raceID, err)
+		}
+		fmt.Printf("Race %d no longer exists on RunSignup, marked as removed\n", raceID)
+	}
+
+	return nil
+}
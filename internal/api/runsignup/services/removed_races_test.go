@@ -0,0 +1,112 @@
+package services
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/rbungay/racedatabase-api/config"
+)
+
+type fakeRaceTracker struct {
+	seen    []int
+	flagged []int
+	deleted []int
+}
+
+func (f *fakeRaceTracker) MarkRacesSeen(state string, raceIDs []int) error {
+	f.seen = raceIDs
+	return nil
+}
+
+func (f *fakeRaceTracker) FlagUnseenRaces(state string, seenIDs []int, threshold int) ([]int, error) {
+	return f.flagged, nil
+}
+
+func (f *fakeRaceTracker) SoftDeleteRace(raceID int) error {
+	f.deleted = append(f.deleted, raceID)
+	return nil
+}
+
+// mockRemovedRaceAPI knows race 111 and reports every other race as missing
+func mockRemovedRaceAPI(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if strings.HasPrefix(r.URL.Path, "/race/111") {
+		w.Write([]byte(`{"race": {"race_id": 111, "name": "Still Here 5K"}}`))
+		return
+	}
+	w.Write([]byte(`{"error": {"error_code": 201, "error_msg": "Race not found."}}`))
+}
+
+func TestDetectRemovedRaces(t *testing.T) {
+	mockServer := httptest.NewServer(http.HandlerFunc(mockRemovedRaceAPI))
+	defer mockServer.Close()
+
+	originalAPIURL := config.GetEnv("RUNSIGNUP_API_URL", "")
+	os.Setenv("RUNSIGNUP_API_URL", mockServer.URL)
+	defer os.Setenv("RUNSIGNUP_API_URL", originalAPIURL)
+
+	tracker := &fakeRaceTracker{flagged: []int{111, 222}}
+	if err := detectRemovedRaces(tracker, "NJ", []int{333}); err != nil {
+		t.Fatalf("detectRemovedRaces failed: %v", err)
+	}
+
+	if len(tracker.seen) != 1 || tracker.seen[0] != 333 {
+		t.Errorf("Unexpected seen races: got %v, want %v", tracker.seen, []int{333})
+	}
+	if len(tracker.deleted) != 1 || tracker.deleted[0] != 222 {
+		t.Errorf("Unexpected deleted races: got %v, want %v", tracker.deleted, []int{222})
+	}
+}
+
+func TestDetectRemovedRaces_KeepsRacesOnUpstreamErrors(t *testing.T) {
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"error": {"error_code": 6, "error_msg": "Invalid API key."}}`))
+	}))
+	defer mockServer.Close()
+
+	originalAPIURL := config.GetEnv("RUNSIGNUP_API_URL", "")
+	os.Setenv("RUNSIGNUP_API_URL", mockServer.URL)
+	defer os.Setenv("RUNSIGNUP_API_URL", originalAPIURL)
+
+	tracker := &fakeRaceTracker{flagged: []int{111, 222}}
+	if err := detectRemovedRaces(tracker, "NJ", []int{333}); err != nil {
+		t.Fatalf("detectRemovedRaces failed: %v", err)
+	}
+	if len(tracker.deleted) != 0 {
+		t.Errorf("Races were deleted on an upstream error: %v", tracker.deleted)
+	}
+}
+
+func TestDetectRemovedRaces_IgnoresCachedSuccess(t *testing.T) {
+	removed := false
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !removed {
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"race": {"race_id": 222, "name": "Gone Soon 10K"}}`))
+			return
+		}
+		mockRemovedRaceAPI(w, r)
+	}))
+	defer mockServer.Close()
+
+	originalAPIURL := config.GetEnv("RUNSIGNUP_API_URL", "")
+	os.Setenv("RUNSIGNUP_API_URL", mockServer.URL)
+	defer os.Setenv("RUNSIGNUP_API_URL", originalAPIURL)
+
+	if _, err := FetchRaceDetails(222); err != nil {
+		t.Fatalf("FetchRaceDetails failed: %v", err)
+	}
+	removed = true
+
+	tracker := &fakeRaceTracker{flagged: []int{222}}
+	if err := detectRemovedRaces(tracker, "NJ", []int{333}); err != nil {
+		t.Fatalf("detectRemovedRaces failed: %v", err)
+	}
+	if len(tracker.deleted) != 1 || tracker.deleted[0] != 222 {
+		t.Errorf("Unexpected deleted races: got %v, want %v", tracker.deleted, []int{222})
+	}
+}
@@ -0,0 +1,181 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/rbungay/racedatabase-api/config"
+	"github.com/rbungay/racedatabase-api/internal/api/runsignup/storage"
+	"github.com/rbungay/racedatabase-api/pkg/cache"
+)
+
+const (
+	defaultCacheTTL  = 5 * time.Minute
+	defaultCacheSize = 1000
+
+	// redisKeyPrefix namespaces this API's keys in a shared Redis
+	redisKeyPrefix = "racedb:"
+)
+
+// errUpstreamNotFound marks a 404, or an error object saying the resource
+// does not exist, from RunSignup so callers can report it as a missing
+// resource rather than an outage
+var errUpstreamNotFound = errors.New("RunSignup returned 404")
+
+var (
+	// upstreamCache holds raw RunSignup response bodies for
+	// RUNSIGNUP_CACHE_TTL, and is disabled when the TTL is 0. It is shared
+	// with the other replicas through REDIS_URL when that is set, and
+	// otherwise kept in process, bounded to RUNSIGNUP_CACHE_SIZE entries.
+	upstreamCache        *cache.Cache
+	upstreamCacheEnabled bool
+
+	// searchCache caches stored race searches in the same backend, and is
+	// nil when caching is disabled
+	searchCache *storage.SearchCache
+
+	cachesOnce sync.Once
+)
+
+// initCaches builds the caches on first use rather than at package init, so
+// they are configured from the environment main loads from .env
+func initCaches() {
+	cachesOnce.Do(func() {
+		upstreamCache, upstreamCacheEnabled = newUpstreamCache()
+		searchCache = newSearchCache()
+	})
+}
+
+func newUpstreamCache() (*cache.Cache, bool) {
+	ttl := upstreamCacheTTL()
+	c := cache.New(cacheBackend(), ttl)
+	c.Publish("runsignup_cache")
+	return c, ttl > 0
+}
+
+func newSearchCache() *storage.SearchCache {
+	if !upstreamCacheEnabled {
+		return nil
+	}
+	return storage.NewSearchCache(cache.New(upstreamCache.Backend(), upstreamCacheTTL()))
+}
+
+func upstreamCacheTTL() time.Duration {
+	if v := config.GetEnv("RUNSIGNUP_CACHE_TTL", ""); v != "" {
+		if parsed, err := time.ParseDuration(v); err == nil && parsed >= 0 {
+			return parsed
+		}
+		fmt.Printf("Invalid RUNSIGNUP_CACHE_TTL %q, using %v\n", v, defaultCacheTTL)
+	}
+	return defaultCacheTTL
+}
+
+// cacheBackend connects to REDIS_URL, falling back to an in-process cache
+func cacheBackend() cache.Backend {
+	if redisURL := config.GetEnv("REDIS_URL", ""); redisURL != "" {
+		backend, err := cache.NewRedis(redisURL, redisKeyPrefix)
+		if err == nil {
+			return backend
+		}
+		fmt.Printf("Invalid REDIS_URL, caching in process instead: %v\n", err)
+	}
+
+	size := defaultCacheSize
+	if v := config.GetEnv("RUNSIGNUP_CACHE_SIZE", ""); v != "" {
+		if parsed, err := strconv.Atoi(v); err == nil && parsed > 0 {
+			size = parsed
+		} else {
+			fmt.Printf("Invalid RUNSIGNUP_CACHE_SIZE %q, using %d\n", v, defaultCacheSize)
+		}
+	}
+	return cache.NewMemory(size)
+}
+
+// SearchCache returns the cache for stored race searches, to be set on the
+// storage the API serves searches from
+func SearchCache() *storage.SearchCache {
+	initCaches()
+	return searchCache
+}
+
+// invalidateRace drops the cached RunSignup details of a race once
+// ingestion has saved a new version of it. Saving invalidates cached
+// searches itself.
+func invalidateRace(raceID int) {
+	initCaches()
+	upstreamCache.Delete(cacheKey(raceDetailsEndpoint(raceID), raceDetailsParams()))
+}
+
+// UpstreamCacheStats reports hits, misses and shared calls of the RunSignup
+// response cache
+func UpstreamCacheStats() cache.Snapshot {
+	initCaches()
+	return upstreamCache.Stats()
+}
+
+// runSignupGet fetches a RunSignup endpoint such as "/races" and returns the
+// body of a 200 response without an error object. Successful bodies are
+// cached under the base URL,
+// endpoint and parameters, without the API credentials, and concurrent
+// identical requests share one upstream call.
+func runSignupGet(endpoint string, params url.Values) ([]byte, error) {
+	apiURL := config.GetEnv("RUNSIGNUP_API_URL", "")
+	key := cacheKey(endpoint, params)
+
+	params.Set("api_key", config.GetEnv("RUNSIGNUP_API_KEY", ""))
+	params.Set("api_secret", config.GetEnv("RUNSIGNUP_API_SECRET", ""))
+	fullURL := apiURL + endpoint + "?" + params.Encode()
+
+	fetch := func() ([]byte, error) {
+		fmt.Println("Making API request to:", apiURL+endpoint)
+		return doRunSignupGet(fullURL)
+	}
+	initCaches()
+	if !upstreamCacheEnabled {
+		return fetch()
+	}
+	return upstreamCache.Fetch(key, fetch)
+}
+
+// cacheKey identifies a RunSignup request by base URL, endpoint and
+// parameters. Encode sorts by key, so equivalent parameter sets share a key.
+func cacheKey(endpoint string, params url.Values) string {
+	return "runsignup:" + config.GetEnv("RUNSIGNUP_API_URL", "") + endpoint + "?" + params.Encode()
+}
+
+func doRunSignupGet(fullURL string) ([]byte, error) {
+	client := &http.Client{Timeout: 10 * time.Second}
+	req, err := http.NewRequest("GET", fullURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", redactRequestURL(err))
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, requestError(err)
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, requestError(fmt.Errorf("failed to read response: %w", err))
+	}
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, fmt.Errorf("%w: %w", errUpstreamNotFound, statusError(resp, body))
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, statusError(resp, body)
+	}
+	// Returned as an error so the body is never cached
+	if err := bodyError(body); err != nil {
+		return nil, err
+	}
+	return body, nil
+}
@@ -0,0 +1,108 @@
+package services
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/rbungay/racedatabase-api/config"
+)
+
+func TestFetchRaceDetails_CachesUpstreamResponses(t *testing.T) {
+	var requests atomic.Int32
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		mockRaceDetailsAPI(w, r)
+	}))
+	defer mockServer.Close()
+
+	originalAPIURL := config.GetEnv("RUNSIGNUP_API_URL", "")
+	os.Setenv("RUNSIGNUP_API_URL", mockServer.URL)
+	defer os.Setenv("RUNSIGNUP_API_URL", originalAPIURL)
+
+	before := UpstreamCacheStats()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := FetchRaceDetails(12345); err != nil {
+				t.Errorf("FetchRaceDetails failed: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	// Credentials are not part of the cache key
+	originalKey := config.GetEnv("RUNSIGNUP_API_KEY", "")
+	os.Setenv("RUNSIGNUP_API_KEY", "rotated")
+	defer os.Setenv("RUNSIGNUP_API_KEY", originalKey)
+
+	details, err := FetchRaceDetails(12345)
+	if err != nil {
+		t.Fatalf("FetchRaceDetails failed: %v", err)
+	}
+	if details.Name != "Test Race" {
+		t.Errorf("Unexpected race name from cache: %v", details.Name)
+	}
+
+	if n := requests.Load(); n != 1 {
+		t.Errorf("Expected one upstream request, got %d", n)
+	}
+	if after := UpstreamCacheStats(); after.Hits <= before.Hits {
+		t.Errorf("Expected cache hits to be recorded: before %+v, after %+v", before, after)
+	}
+}
+
+func TestFetchRaceDetails_DoesNotCacheFailures(t *testing.T) {
+	var requests atomic.Int32
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer mockServer.Close()
+
+	originalAPIURL := config.GetEnv("RUNSIGNUP_API_URL", "")
+	os.Setenv("RUNSIGNUP_API_URL", mockServer.URL)
+	defer os.Setenv("RUNSIGNUP_API_URL", originalAPIURL)
+
+	for i := 0; i < 2; i++ {
+		if _, err := FetchRaceDetails(12345); err == nil {
+			t.Errorf("Expected an error from a failing upstream")
+		}
+	}
+	if n := requests.Load(); n != 2 {
+		t.Errorf("Failures should not be cached: got %d upstream requests", n)
+	}
+}
+
+func TestInvalidateRace_RefetchesDetails(t *testing.T) {
+	var requests atomic.Int32
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		mockRaceDetailsAPI(w, r)
+	}))
+	defer mockServer.Close()
+
+	originalAPIURL := config.GetEnv("RUNSIGNUP_API_URL", "")
+	os.Setenv("RUNSIGNUP_API_URL", mockServer.URL)
+	defer os.Setenv("RUNSIGNUP_API_URL", originalAPIURL)
+
+	for i := 0; i < 2; i++ {
+		if _, err := FetchRaceDetails(12345); err != nil {
+			t.Fatalf("FetchRaceDetails failed: %v", err)
+		}
+	}
+	invalidateRace(12345)
+	if _, err := FetchRaceDetails(12345); err != nil {
+		t.Fatalf("FetchRaceDetails failed: %v", err)
+	}
+
+	if n := requests.Load(); n != 2 {
+		t.Errorf("Expected a second upstream request after invalidation, got %d", n)
+	}
+}
@@ -0,0 +1,343 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rbungay/racedatabase-api/config"
+	"github.com/rbungay/racedatabase-api/internal/api/runsignup/constants"
+	"github.com/rbungay/racedatabase-api/internal/api/runsignup/models"
+	"github.com/rbungay/racedatabase-api/internal/api/runsignup/storage"
+	apperrors "github.com/rbungay/racedatabase-api/pkg/errors"
+)
+
+// SyncMode chooses between re-fetching every race of a state and fetching
+// only the races modified since its last sync
+type SyncMode string
+
+const (
+	// SyncAuto syncs incrementally unless the state has never been synced
+	// or its last full sync is older than SYNC_FULL_EVERY
+	SyncAuto SyncMode = "auto"
+	// SyncFull re-fetches every race
+	SyncFull SyncMode = "full"
+	// SyncIncremental fetches only modified races, falling back to a full
+	// sync for a state that has never been synced
+	SyncIncremental SyncMode = "incremental"
+)
+
+const (
+	defaultFullSyncEvery = 7 * 24 * time.Hour
+
+	// watermarkOverlap re-requests a margin before the high-water mark to
+	// allow for clock skew between us and RunSignup
+	watermarkOverlap = 10 * time.Minute
+
+	// staleSyncJobAfter is how long a running job can go without saving a
+	// checkpoint before it is taken to have died with its process
+	staleSyncJobAfter = 30 * time.Minute
+)
+
+// syncPageSize is how many races are requested, and checkpointed, at a time
+var syncPageSize = 100
+
+// ParseSyncMode reads a sync mode, defaulting to SyncAuto when empty
+func ParseSyncMode(mode string) (SyncMode, error) {
+	switch SyncMode(mode) {
+	case "":
+		return SyncAuto, nil
+	case SyncAuto, SyncFull, SyncIncremental:
+		return SyncMode(mode), nil
+	}
+	return "", apperrors.InvalidArgument("Invalid sync mode: %s.", mode)
+}
+
+// syncStore is the part of storage sync jobs use
+type syncStore interface {
+	ingestStore
+	raceTracker
+	SyncWatermark(state string) (*models.SyncWatermark, error)
+	AdvanceSyncWatermark(state string, syncedThrough time.Time, full bool) error
+	CreateSyncJob(job *models.SyncJob) error
+	StartSyncJob(id int64) (time.Time, error)
+	SyncCheckpoints(jobID int64) ([]models.SyncCheckpoint, error)
+	SaveSyncCheckpoint(jobID int64, cp models.SyncCheckpoint, fetched, saved, failed int) error
+	FinishSyncJob(id int64, status string, jobErr error) error
+}
+
+// sharedStorage is the database every service stores races in, set once at
+// startup by UseStorage. It is nil when no database is configured.
+var sharedStorage *storage.SupabaseStorage
+
+// UseStorage makes the services store races in s and serve cached searches
+// from it. It must be called before the services are used.
+func UseStorage(s *storage.SupabaseStorage) {
+	s.SetSearchCache(SearchCache())
+	sharedStorage = s
+}
+
+// syncStorage returns the database sync jobs are recorded in
+func syncStorage() (*storage.SupabaseStorage, error) {
+	if sharedStorage == nil {
+		return nil, fmt.Errorf("no database is configured, set SUPABASE_DB_URL")
+	}
+	return sharedStorage, nil
+}
+
+// SyncState runs a sync job for one state and returns how many races it
+// saved
+func SyncState(ctx context.Context, state string, eventTypes []string, mode SyncMode) (int, error) {
+	job, err := RunSync(ctx, []string{state}, eventTypes, mode)
+	if job == nil {
+		return 0, err
+	}
+	return job.RacesSaved, err
+}
+
+// RunSync records a sync job for the given states and event types and runs
+// it. The job is returned with its final progress even when it fails.
+func RunSync(ctx context.Context, states, eventTypes []string, mode SyncMode) (*models.SyncJob, error) {
+	if len(states) == 0 {
+		return nil, apperrors.InvalidArgument("At least one state is required.")
+	}
+	for _, eventType := range eventTypes {
+		if !constants.ValidEventTypes[eventType] {
+			return nil, apperrors.InvalidArgument("Invalid event_type: %s.", eventType)
+		}
+	}
+
+	store, err := syncStorage()
+	if err != nil {
+		return nil, err
+	}
+
+	job := &models.SyncJob{Mode: string(mode), EventTypes: eventTypes}
+	for _, state := range states {
+		job.States = append(job.States, strings.ToUpper(state))
+	}
+	if err := store.CreateSyncJob(job); err != nil {
+		return nil, fmt.Errorf("failed to create sync job: %w", err)
+	}
+	return job, runSyncJob(ctx, store, job)
+}
+
+// ResumeSync continues the most recent sync job that failed, was
+// interrupted or was left running by a process that died, skipping the
+// pages it had finished. A running job is only resumed once it has saved
+// no checkpoint for staleSyncJobAfter.
+// It returns a nil job when there is nothing to resume.
+func ResumeSync(ctx context.Context) (*models.SyncJob, error) {
+	store, err := syncStorage()
+	if err != nil {
+		return nil, err
+	}
+	job, err := store.ResumableSyncJob(staleSyncJobAfter)
+	if err != nil || job == nil {
+		return nil, err
+	}
+	fmt.Printf("Resuming sync job %d of %s\n", job.ID, strings.Join(job.States, ", "))
+	return job, runSyncJob(ctx, store, job)
+}
+
+// runSyncJob syncs each state of a job in turn, checkpointing after every
+// page. Cancelling ctx leaves the job interrupted so it can be resumed,
+// unless the cause is ErrSyncCancelled.
+func runSyncJob(ctx context.Context, store syncStore, job *models.SyncJob) error {
+	started, err := store.StartSyncJob(job.ID)
+	if err != nil {
+		return fmt.Errorf("failed to start sync job %d: %w", job.ID, err)
+	}
+	job.Status = models.SyncJobRunning
+
+	checkpoints, err := store.SyncCheckpoints(job.ID)
+	if err != nil {
+		return fmt.Errorf("failed to load checkpoints of sync job %d: %w", job.ID, err)
+	}
+	job.Progress = checkpoints
+
+	var failures []error
+	for _, state := range job.States {
+		if ctx.Err() != nil {
+			break
+		}
+		if err := syncJobState(ctx, store, job, state, started); err != nil {
+			failures = append(failures, fmt.Errorf("%s: %w", state, err))
+		}
+	}
+
+	status, jobErr := models.SyncJobSucceeded, errors.Join(failures...)
+	if errors.Is(context.Cause(ctx), ErrSyncCancelled) {
+		status, jobErr = models.SyncJobCancelled, ErrSyncCancelled
+	} else if ctx.Err() != nil {
+		status, jobErr = models.SyncJobInterrupted, ctx.Err()
+	} else if jobErr != nil {
+		status = models.SyncJobFailed
+	}
+	job.Status = status
+	if err := store.FinishSyncJob(job.ID, status, jobErr); err != nil {
+		fmt.Printf("Failed to record the outcome of sync job %d: %v\n", job.ID, err)
+	}
+	return jobErr
+}
+
+// syncJobState syncs every event type of one state concurrently, each page
+// by page from its checkpoint
+func syncJobState(ctx context.Context, store syncStore, job *models.SyncJob, state string, started time.Time) error {
+	checkpoints := make(map[string]models.SyncCheckpoint)
+	for _, cp := range job.Progress {
+		if cp.State == state {
+			checkpoints[cp.EventType] = cp
+		}
+	}
+
+	// The first run of a state decides how it is synced, and a resumed run
+	// keeps to that
+	var modifiedSince *time.Time
+	resumed := len(checkpoints) > 0
+	if resumed {
+		for _, cp := range checkpoints {
+			modifiedSince = cp.ModifiedSince
+		}
+	} else {
+		watermark, err := store.SyncWatermark(state)
+		if err != nil {
+			return fmt.Errorf("failed to read the sync watermark: %w", err)
+		}
+		if since, full := planSync(watermark, SyncMode(job.Mode), started, fullSyncEvery()); !full {
+			modifiedSince = &since
+		}
+	}
+	full := modifiedSince == nil
+	since := ""
+	if full {
+		fmt.Printf("Full sync of %s\n", state)
+	} else {
+		since = strconv.FormatInt(modifiedSince.Unix(), 10)
+		fmt.Printf("Incremental sync of %s since %s\n", state, modifiedSince.Format(time.RFC3339))
+	}
+
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		seenIDs  []int
+		failures []EventTypeFailure
+	)
+	// A race listed under several event types is only stored once per run
+	stored := make(map[int]bool)
+
+	for _, eventType := range job.EventTypes {
+		cp, ok := checkpoints[eventType]
+		if !ok {
+			cp = models.SyncCheckpoint{State: state, EventType: eventType, ModifiedSince: modifiedSince, NextPage: 1}
+		}
+		if cp.Done {
+			continue
+		}
+
+		wg.Add(1)
+		go func(cp models.SyncCheckpoint) {
+			defer wg.Done()
+			fail := func(err error) {
+				mu.Lock()
+				failures = append(failures, EventTypeFailure{EventType: cp.EventType, Err: err})
+				mu.Unlock()
+			}
+
+			for !cp.Done {
+				if err := ctx.Err(); err != nil {
+					fail(err)
+					return
+				}
+
+				events, err := fetchRacePage(state, cp.EventType, since, cp.NextPage, syncPageSize)
+				if err != nil {
+					fail(err)
+					return
+				}
+
+				fresh := make([]models.Event, 0, len(events))
+				mu.Lock()
+				for _, event := range events {
+					seenIDs = append(seenIDs, event.ID)
+					if !stored[event.ID] {
+						stored[event.ID] = true
+						fresh = append(fresh, event)
+					}
+				}
+				mu.Unlock()
+				saved, failed, err := storeRaces(ctx, store, fresh)
+				if err != nil {
+					// The page is synced again when the job resumes
+					fail(err)
+					return
+				}
+
+				cp.NextPage++
+				cp.Done = len(events) < syncPageSize
+				if err := store.SaveSyncCheckpoint(job.ID, cp, len(events), saved, failed); err != nil {
+					fail(fmt.Errorf("failed to save checkpoint: %w", err))
+					return
+				}
+
+				mu.Lock()
+				job.RacesFetched += len(events)
+				job.RacesSaved += saved
+				job.RacesFailed += failed
+				mu.Unlock()
+			}
+		}(cp)
+	}
+	wg.Wait()
+
+	if len(failures) > 0 {
+		// Leave the mark so the next sync retries what was missed
+		return newPartialError(failures)
+	}
+
+	// The watermark covers every event type in the state, so a job limited
+	// to some of them must not move it past races of the others
+	allEventTypes := len(job.EventTypes) == len(constants.ValidEventTypes)
+	if allEventTypes {
+		if err := store.AdvanceSyncWatermark(state, started, full); err != nil {
+			return fmt.Errorf("failed to advance the sync watermark: %w", err)
+		}
+	}
+
+	// Only a complete, unfiltered pass tells us which races are gone upstream.
+	// A resumed pass did not see the races on pages finished before.
+	if full && allEventTypes {
+		if resumed {
+			fmt.Printf("Skipping removed race detection in %s after resuming\n", state)
+		} else if err := detectRemovedRaces(store, state, seenIDs); err != nil {
+			fmt.Printf("Failed to detect removed races in %s: %v\n", state, err)
+		}
+	}
+	return nil
+}
+
+// planSync decides whether a sync starting at now is full and, if not, from
+// when modified races are requested
+func planSync(watermark *models.SyncWatermark, mode SyncMode, now time.Time, fullEvery time.Duration) (time.Time, bool) {
+	if watermark == nil || mode == SyncFull {
+		return time.Time{}, true
+	}
+	if mode == SyncAuto && now.Sub(watermark.LastFullSync) >= fullEvery {
+		return time.Time{}, true
+	}
+	return watermark.SyncedThrough.Add(-watermarkOverlap), false
+}
+
+// fullSyncEvery is how often SyncAuto falls back to a full sync, configured
+// by SYNC_FULL_EVERY
+func fullSyncEvery() time.Duration {
+	every, err := time.ParseDuration(config.GetEnv("SYNC_FULL_EVERY", ""))
+	if err != nil || every <= 0 {
+		return defaultFullSyncEvery
+	}
+	return every
+}
@@ -0,0 +1,190 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rbungay/racedatabase-api/internal/api/runsignup/constants"
+	"github.com/rbungay/racedatabase-api/internal/api/runsignup/models"
+	apperrors "github.com/rbungay/racedatabase-api/pkg/errors"
+)
+
+// ErrSyncCancelled is the cause of a sync job cancelled on request
+var ErrSyncCancelled = errors.New("sync job cancelled")
+
+// managedSyncStore is the part of storage the sync manager uses
+type managedSyncStore interface {
+	syncStore
+	retryStore
+	SyncJob(id int64) (*models.SyncJob, error)
+	FailedIngests(minAttempts, limit int) ([]models.FailedIngest, error)
+}
+
+// SyncManager starts sync jobs in the background and cancels them on
+// request. Jobs can only be cancelled by the process running them.
+type SyncManager struct {
+	ctx   context.Context
+	store managedSyncStore
+
+	mu       sync.Mutex
+	running  map[int64]context.CancelCauseFunc
+	retrying bool
+	wg       sync.WaitGroup
+}
+
+// NewSyncManager creates a manager recording jobs in store. Cancelling ctx
+// interrupts every running job.
+func NewSyncManager(ctx context.Context, store managedSyncStore) *SyncManager {
+	return &SyncManager{ctx: ctx, store: store, running: make(map[int64]context.CancelCauseFunc)}
+}
+
+// StartSync records a job for the given states and event types, all of
+// them when eventTypes is empty, and runs it in the background
+func (m *SyncManager) StartSync(states, eventTypes []string, mode SyncMode) (*models.SyncJob, error) {
+	if m.ctx.Err() != nil {
+		return nil, apperrors.Unavailable("The server is shutting down, please try again later.")
+	}
+	if len(states) == 0 {
+		return nil, apperrors.InvalidArgument("At least one state is required.")
+	}
+	if len(eventTypes) == 0 {
+		for eventType := range constants.ValidEventTypes {
+			eventTypes = append(eventTypes, eventType)
+		}
+	}
+	for _, eventType := range eventTypes {
+		if !constants.ValidEventTypes[eventType] {
+			return nil, apperrors.InvalidArgument("Invalid event_type: %s.", eventType)
+		}
+	}
+
+	job := &models.SyncJob{Mode: string(mode), EventTypes: eventTypes}
+	for _, state := range states {
+		job.States = append(job.States, strings.ToUpper(state))
+	}
+	if err := m.store.CreateSyncJob(job); err != nil {
+		return nil, fmt.Errorf("failed to create sync job: %w", err)
+	}
+	created := *job
+
+	ctx, cancel := context.WithCancelCause(m.ctx)
+	m.mu.Lock()
+	m.running[job.ID] = cancel
+	m.mu.Unlock()
+
+	m.wg.Add(1)
+	go func() {
+		defer m.wg.Done()
+		defer func() {
+			m.mu.Lock()
+			delete(m.running, job.ID)
+			m.mu.Unlock()
+			cancel(nil)
+		}()
+
+		if err := runSyncJob(ctx, m.store, job); err != nil {
+			fmt.Printf("Sync job %d %s: %v\n", job.ID, job.Status, err)
+		}
+	}()
+	return &created, nil
+}
+
+// SyncJob returns a job and its progress
+func (m *SyncManager) SyncJob(id int64) (*models.SyncJob, error) {
+	job, err := m.store.SyncJob(id)
+	if err != nil {
+		return nil, err
+	}
+	if job == nil {
+		return nil, apperrors.NotFound("Sync job %d not found.", id)
+	}
+	return job, nil
+}
+
+// CancelSync stops a job running in this process after its current page.
+// A job left unfinished by a process that died is marked cancelled so it
+// is not resumed. A job another process is still running can only be
+// cancelled there, since that process would overwrite its status.
+func (m *SyncManager) CancelSync(id int64) (*models.SyncJob, error) {
+	job, err := m.SyncJob(id)
+	if err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	cancel, running := m.running[id]
+	m.mu.Unlock()
+	if running {
+		cancel(ErrSyncCancelled)
+		return job, nil
+	}
+
+	switch job.Status {
+	case models.SyncJobSucceeded, models.SyncJobFailed, models.SyncJobCancelled:
+		return nil, apperrors.Conflict("Sync job %d has already %s.", id, job.Status)
+	case models.SyncJobPending, models.SyncJobRunning:
+		if time.Since(job.UpdatedAt) < staleSyncJobAfter {
+			return nil, apperrors.Conflict("Sync job %d is running in another process and can only be cancelled there.", id)
+		}
+	}
+	if err := m.store.FinishSyncJob(id, models.SyncJobCancelled, ErrSyncCancelled); err != nil {
+		return nil, err
+	}
+	job.Status = models.SyncJobCancelled
+	return job, nil
+}
+
+// FailedIngests lists up to limit races still queued after minAttempts
+// attempts
+func (m *SyncManager) FailedIngests(minAttempts, limit int) ([]models.FailedIngest, error) {
+	return m.store.FailedIngests(minAttempts, limit)
+}
+
+// StartRetry retries up to limit queued races whose backoff has elapsed in
+// the background and returns how many it is retrying. Only one retry runs
+// at a time.
+func (m *SyncManager) StartRetry(limit int) (*models.RetryStarted, error) {
+	if m.ctx.Err() != nil {
+		return nil, apperrors.Unavailable("The server is shutting down, please try again later.")
+	}
+	m.mu.Lock()
+	if m.retrying {
+		m.mu.Unlock()
+		return nil, apperrors.Conflict("Failed races are already being retried.")
+	}
+	m.retrying = true
+	m.mu.Unlock()
+	done := func() {
+		m.mu.Lock()
+		m.retrying = false
+		m.mu.Unlock()
+	}
+
+	due, err := dueFailedIngests(m.store, limit)
+	if err != nil {
+		done()
+		return nil, err
+	}
+
+	m.wg.Add(1)
+	go func() {
+		defer m.wg.Done()
+		defer done()
+
+		result, err := retryIngests(m.ctx, m.store, due)
+		fmt.Printf("Retried %d failed races: %d recovered, %d failed again\n", result.Retried, result.Recovered, result.Failed)
+		if err != nil {
+			fmt.Printf("Retry of failed races stopped: %v\n", err)
+		}
+	}()
+	return &models.RetryStarted{Retrying: len(due)}, nil
+}
+
+// Wait blocks until every job and retry started by the manager has returned
+func (m *SyncManager) Wait() {
+	m.wg.Wait()
+}
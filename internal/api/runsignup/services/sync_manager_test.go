@@ -0,0 +1,180 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rbungay/racedatabase-api/config"
+	"github.com/rbungay/racedatabase-api/internal/api/runsignup/models"
+	apperrors "github.com/rbungay/racedatabase-api/pkg/errors"
+)
+
+// managedFakeSyncStore adds job lookups to fakeSyncStore. Its job was last
+// updated at updated.
+type managedFakeSyncStore struct {
+	*fakeSyncStore
+	updated time.Time
+}
+
+func (f managedFakeSyncStore) SyncJob(id int64) (*models.SyncJob, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if id != 1 {
+		return nil, nil
+	}
+	status := f.status
+	if status == "" {
+		status = models.SyncJobRunning
+	}
+	return &models.SyncJob{ID: id, Status: status, UpdatedAt: f.updated}, nil
+}
+
+// mockEndlessRacesAPI returns a full page of races for every page
+func mockEndlessRacesAPI(w http.ResponseWriter, r *http.Request) {
+	if strings.HasPrefix(r.URL.Path, "/race/") {
+		mockRaceDetailsAPI(w, r)
+		return
+	}
+	time.Sleep(time.Millisecond)
+	w.Header().Set("Content-Type", "application/json")
+	w.Write([]byte(`{"races": [{"race": {"race_id": 1}}, {"race": {"race_id": 2}}]}`))
+}
+
+func TestSyncManager_CancelRunningJob(t *testing.T) {
+	mockServer := httptest.NewServer(http.HandlerFunc(mockEndlessRacesAPI))
+	defer mockServer.Close()
+
+	originalAPIURL := config.GetEnv("RUNSIGNUP_API_URL", "")
+	os.Setenv("RUNSIGNUP_API_URL", mockServer.URL)
+	defer os.Setenv("RUNSIGNUP_API_URL", originalAPIURL)
+
+	originalPageSize := syncPageSize
+	syncPageSize = 2
+	defer func() { syncPageSize = originalPageSize }()
+
+	store := managedFakeSyncStore{fakeSyncStore: newFakeSyncStore()}
+	manager := NewSyncManager(context.Background(), store)
+
+	job, err := manager.StartSync([]string{"nj"}, []string{"running_race"}, SyncFull)
+	if err != nil {
+		t.Fatalf("StartSync failed: %v", err)
+	}
+	if job.ID != 1 || job.Status != models.SyncJobPending || job.States[0] != "NJ" {
+		t.Errorf("Unexpected job: %+v", job)
+	}
+
+	time.Sleep(20 * time.Millisecond)
+	if _, err := manager.CancelSync(job.ID); err != nil {
+		t.Fatalf("CancelSync failed: %v", err)
+	}
+	manager.Wait()
+
+	if store.status != models.SyncJobCancelled {
+		t.Errorf("Expected the job to be cancelled, got %s", store.status)
+	}
+	if _, err := manager.CancelSync(job.ID); apperrors.KindOf(err) != apperrors.KindConflict {
+		t.Errorf("Expected cancelling a finished job to conflict, got %v", err)
+	}
+}
+
+func TestSyncManager_CancelJobRunningElsewhere(t *testing.T) {
+	store := managedFakeSyncStore{fakeSyncStore: newFakeSyncStore(), updated: time.Now()}
+	manager := NewSyncManager(context.Background(), store)
+
+	if _, err := manager.CancelSync(1); apperrors.KindOf(err) != apperrors.KindConflict {
+		t.Errorf("Expected cancelling a job another process is running to conflict, got %v", err)
+	}
+	if store.status != "" {
+		t.Errorf("Expected the job to be left alone, got %s", store.status)
+	}
+
+	// A job that stopped checkpointing was left behind by a process that died
+	store.updated = time.Now().Add(-staleSyncJobAfter)
+	manager = NewSyncManager(context.Background(), store)
+	job, err := manager.CancelSync(1)
+	if err != nil || job.Status != models.SyncJobCancelled || store.status != models.SyncJobCancelled {
+		t.Errorf("Expected a stale job to be cancelled, got %+v, %v", job, err)
+	}
+}
+
+func TestSyncManager_UnknownJob(t *testing.T) {
+	manager := NewSyncManager(context.Background(), managedFakeSyncStore{fakeSyncStore: newFakeSyncStore()})
+
+	if _, err := manager.SyncJob(2); apperrors.KindOf(err) != apperrors.KindNotFound {
+		t.Errorf("Expected a missing job to be not found, got %v", err)
+	}
+	if _, err := manager.StartSync(nil, nil, SyncAuto); apperrors.KindOf(err) != apperrors.KindInvalidArgument {
+		t.Errorf("Expected a sync without states to be rejected, got %v", err)
+	}
+}
+
+func TestSyncManager_ShutdownInterruptsJobs(t *testing.T) {
+	mockServer := httptest.NewServer(http.HandlerFunc(mockEndlessRacesAPI))
+	defer mockServer.Close()
+
+	originalAPIURL := config.GetEnv("RUNSIGNUP_API_URL", "")
+	os.Setenv("RUNSIGNUP_API_URL", mockServer.URL)
+	defer os.Setenv("RUNSIGNUP_API_URL", originalAPIURL)
+
+	originalPageSize := syncPageSize
+	syncPageSize = 2
+	defer func() { syncPageSize = originalPageSize }()
+
+	ctx, shutdown := context.WithCancel(context.Background())
+	store := managedFakeSyncStore{fakeSyncStore: newFakeSyncStore()}
+	manager := NewSyncManager(ctx, store)
+	if _, err := manager.StartSync([]string{"NJ"}, []string{"running_race"}, SyncFull); err != nil {
+		t.Fatalf("StartSync failed: %v", err)
+	}
+
+	time.Sleep(20 * time.Millisecond)
+	shutdown()
+	manager.Wait()
+
+	if store.status != models.SyncJobInterrupted {
+		t.Errorf("Expected a shut down job to be resumable, got %s", store.status)
+	}
+	if _, err := manager.StartSync([]string{"NJ"}, nil, SyncAuto); apperrors.KindOf(err) != apperrors.KindUnavailable {
+		t.Errorf("Expected new syncs to be refused after shutdown, got %v", err)
+	}
+}
+
+func TestSyncManager_RetryRunsInBackground(t *testing.T) {
+	release := make(chan struct{})
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		mockRaceDetailsAPI(w, r)
+	}))
+	defer mockServer.Close()
+
+	originalAPIURL := config.GetEnv("RUNSIGNUP_API_URL", "")
+	os.Setenv("RUNSIGNUP_API_URL", mockServer.URL)
+	defer os.Setenv("RUNSIGNUP_API_URL", originalAPIURL)
+
+	store := managedFakeSyncStore{fakeSyncStore: newFakeSyncStore()}
+	queueFailedIngest(store, models.Event{ID: 12345, Name: "Test Race"}, models.IngestStageFetch, errors.New("timeout"))
+	manager := NewSyncManager(context.Background(), store)
+
+	started, err := manager.StartRetry(10)
+	if err != nil || started.Retrying != 1 {
+		t.Fatalf("StartRetry = %+v, %v; want 1 race retrying", started, err)
+	}
+	if _, err := manager.StartRetry(10); apperrors.KindOf(err) != apperrors.KindConflict {
+		t.Errorf("Expected a second retry to conflict while the first runs, got %v", err)
+	}
+
+	close(release)
+	manager.Wait()
+	if len(store.failed) != 0 || store.saves != 1 {
+		t.Errorf("Expected the race to be recovered: %d queued, %d saves", len(store.failed), store.saves)
+	}
+	if _, err := manager.StartRetry(10); err != nil {
+		t.Errorf("Expected a retry once the first finished, got %v", err)
+	}
+}
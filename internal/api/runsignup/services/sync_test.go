@@ -0,0 +1,309 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rbungay/racedatabase-api/config"
+	"github.com/rbungay/racedatabase-api/internal/api/runsignup/models"
+)
+
+func TestPlanSync(t *testing.T) {
+	now := time.Date(2025, 6, 8, 12, 0, 0, 0, time.UTC)
+	recent := &models.SyncWatermark{
+		State:         "NJ",
+		SyncedThrough: now.Add(-6 * time.Hour),
+		LastFullSync:  now.Add(-48 * time.Hour),
+	}
+	stale := &models.SyncWatermark{
+		State:         "NJ",
+		SyncedThrough: now.Add(-6 * time.Hour),
+		LastFullSync:  now.Add(-8 * 24 * time.Hour),
+	}
+	neverFull := &models.SyncWatermark{State: "NJ", SyncedThrough: now.Add(-6 * time.Hour)}
+
+	tests := []struct {
+		name      string
+		watermark *models.SyncWatermark
+		mode      SyncMode
+		wantFull  bool
+	}{
+		{"never synced", nil, SyncAuto, true},
+		{"never synced incremental", nil, SyncIncremental, true},
+		{"recent full sync", recent, SyncAuto, false},
+		{"full sync due", stale, SyncAuto, true},
+		{"no full sync yet", neverFull, SyncAuto, true},
+		{"forced full", recent, SyncFull, true},
+		{"forced incremental", stale, SyncIncremental, false},
+	}
+	for _, tt := range tests {
+		since, full := planSync(tt.watermark, tt.mode, now, defaultFullSyncEvery)
+		if full != tt.wantFull {
+			t.Errorf("%s: got full %v, want %v", tt.name, full, tt.wantFull)
+			continue
+		}
+		if !full {
+			if want := tt.watermark.SyncedThrough.Add(-watermarkOverlap); !since.Equal(want) {
+				t.Errorf("%s: got modified since %v, want %v", tt.name, since, want)
+			}
+		}
+	}
+}
+
+func TestFetchEventsFromAPI_ModifiedSince(t *testing.T) {
+	var modifiedSince string
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		modifiedSince = r.URL.Query().Get("modified_since")
+		mockRunSignupAPI(w, r)
+	}))
+	defer mockServer.Close()
+
+	originalAPIURL := config.GetEnv("RUNSIGNUP_API_URL", "")
+	os.Setenv("RUNSIGNUP_API_URL", mockServer.URL)
+	defer os.Setenv("RUNSIGNUP_API_URL", originalAPIURL)
+
+	if _, err := fetchEventsFromAPI("NJ", "", "running_race", "", "", "", "", "", "", "1749384000"); err != nil {
+		t.Fatalf("fetchEventsFromAPI failed: %v", err)
+	}
+	if modifiedSince != "1749384000" {
+		t.Errorf("Expected modified_since to be sent, got %q", modifiedSince)
+	}
+}
+
+// fakeSyncStore keeps sync jobs, checkpoints and watermarks in memory
+type fakeSyncStore struct {
+	fakeRaceTracker
+
+	mu          sync.Mutex
+	saves       int
+	failed      map[int]models.FailedIngest
+	checkpoints map[string]models.SyncCheckpoint
+	watermarks  map[string]bool
+	status      string
+	jobErr      error
+	started     time.Time
+}
+
+func newFakeSyncStore() *fakeSyncStore {
+	return &fakeSyncStore{
+		failed:      make(map[int]models.FailedIngest),
+		checkpoints: make(map[string]models.SyncCheckpoint),
+		watermarks:  make(map[string]bool),
+	}
+}
+
+func (f *fakeSyncStore) SaveRace(race *models.RaceDetails) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.saves++
+	return nil
+}
+
+func (f *fakeSyncStore) SaveRaces(races []*models.RaceDetails) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.saves += len(races)
+	return nil
+}
+
+func (f *fakeSyncStore) RecordFailedIngest(race models.Event, stage string, ingestErr error, baseDelay, maxDelay time.Duration) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	failure := f.failed[race.ID]
+	failure.RaceID, failure.Race, failure.Stage, failure.Error = race.ID, race, stage, ingestErr.Error()
+	failure.Attempts++
+	f.failed[race.ID] = failure
+	return nil
+}
+
+func (f *fakeSyncStore) ResolveFailedIngests(raceIDs []int) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, id := range raceIDs {
+		delete(f.failed, id)
+	}
+	return nil
+}
+
+// DueFailedIngests treats every queued race as due
+func (f *fakeSyncStore) DueFailedIngests(maxAttempts, limit int) ([]models.FailedIngest, error) {
+	return f.FailedIngests(0, limit)
+}
+
+func (f *fakeSyncStore) FailedIngests(minAttempts, limit int) ([]models.FailedIngest, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var failures []models.FailedIngest
+	for _, failure := range f.failed {
+		if failure.Attempts >= minAttempts && len(failures) < limit {
+			failures = append(failures, failure)
+		}
+	}
+	return failures, nil
+}
+
+func (f *fakeSyncStore) SyncWatermark(state string) (*models.SyncWatermark, error) {
+	return nil, nil
+}
+
+func (f *fakeSyncStore) AdvanceSyncWatermark(state string, syncedThrough time.Time, full bool) error {
+	f.watermarks[state] = full
+	return nil
+}
+
+func (f *fakeSyncStore) CreateSyncJob(job *models.SyncJob) error {
+	job.ID, job.Status = 1, models.SyncJobPending
+	return nil
+}
+
+func (f *fakeSyncStore) StartSyncJob(id int64) (time.Time, error) {
+	if f.started.IsZero() {
+		f.started = time.Now()
+	}
+	return f.started, nil
+}
+
+func (f *fakeSyncStore) SyncCheckpoints(jobID int64) ([]models.SyncCheckpoint, error) {
+	var checkpoints []models.SyncCheckpoint
+	for _, cp := range f.checkpoints {
+		checkpoints = append(checkpoints, cp)
+	}
+	return checkpoints, nil
+}
+
+func (f *fakeSyncStore) SaveSyncCheckpoint(jobID int64, cp models.SyncCheckpoint, fetched, saved, failed int) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.checkpoints[cp.State+"/"+cp.EventType] = cp
+	return nil
+}
+
+func (f *fakeSyncStore) FinishSyncJob(id int64, status string, jobErr error) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.status, f.jobErr = status, jobErr
+	return nil
+}
+
+// mockPagedRacesAPI serves two races on page 1 and one on page 2 of a page
+// size of 2, failing page 2 while failPage is set
+func mockPagedRacesAPI(failPage *bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/race/") {
+			mockRaceDetailsAPI(w, r)
+			return
+		}
+
+		page := r.URL.Query().Get("page")
+		if page == "2" && *failPage {
+			http.Error(w, "API Error", http.StatusInternalServerError)
+			return
+		}
+		ids := map[string][]int{"1": {1, 2}, "2": {3}}[page]
+
+		races := make([]string, 0, len(ids))
+		for _, id := range ids {
+			races = append(races, fmt.Sprintf(`{"race": {"race_id": %d, "name": "Race %d"}}`, id, id))
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"races": [%s]}`, strings.Join(races, ","))
+	}
+}
+
+func TestRunSyncJob_ResumesFromCheckpoint(t *testing.T) {
+	failPage := true
+	mockServer := httptest.NewServer(mockPagedRacesAPI(&failPage))
+	defer mockServer.Close()
+
+	originalAPIURL := config.GetEnv("RUNSIGNUP_API_URL", "")
+	os.Setenv("RUNSIGNUP_API_URL", mockServer.URL)
+	defer os.Setenv("RUNSIGNUP_API_URL", originalAPIURL)
+
+	originalPageSize := syncPageSize
+	syncPageSize = 2
+	defer func() { syncPageSize = originalPageSize }()
+
+	store := newFakeSyncStore()
+	job := &models.SyncJob{ID: 1, Mode: string(SyncFull), States: []string{"NJ"}, EventTypes: []string{"running_race"}}
+
+	if err := runSyncJob(context.Background(), store, job); err == nil {
+		t.Fatalf("Expected the failing page to fail the job")
+	}
+	if store.status != models.SyncJobFailed || job.RacesSaved != 2 {
+		t.Errorf("Unexpected first run: status %s, %d saved", store.status, job.RacesSaved)
+	}
+	if cp := store.checkpoints["NJ/running_race"]; cp.NextPage != 2 || cp.Done {
+		t.Errorf("Expected a checkpoint at page 2, got %+v", cp)
+	}
+
+	failPage = false
+	if err := runSyncJob(context.Background(), store, job); err != nil {
+		t.Fatalf("Resumed job failed: %v", err)
+	}
+	if store.status != models.SyncJobSucceeded {
+		t.Errorf("Expected the resumed job to succeed, got %s", store.status)
+	}
+	if store.saves != 3 {
+		t.Errorf("Expected finished pages to be skipped on resume: got %d saves, want 3", store.saves)
+	}
+	if cp := store.checkpoints["NJ/running_race"]; !cp.Done {
+		t.Errorf("Expected the checkpoint to be done, got %+v", cp)
+	}
+	if _, ok := store.watermarks["NJ"]; ok {
+		t.Errorf("Expected a job for one event type to leave the watermark alone")
+	}
+}
+
+func TestRunSyncJob_FailedJobErrorHasNoCredentials(t *testing.T) {
+	for name, value := range map[string]string{
+		"RUNSIGNUP_API_URL":    "http://127.0.0.1:1",
+		"RUNSIGNUP_API_KEY":    "KEY123",
+		"RUNSIGNUP_API_SECRET": "SECRET456",
+	} {
+		original := config.GetEnv(name, "")
+		os.Setenv(name, value)
+		defer os.Setenv(name, original)
+	}
+
+	store := newFakeSyncStore()
+	job := &models.SyncJob{ID: 1, Mode: string(SyncFull), States: []string{"NJ"}, EventTypes: []string{"running_race"}}
+	if err := runSyncJob(context.Background(), store, job); err == nil {
+		t.Fatalf("Expected the unreachable upstream to fail the job")
+	}
+	if store.status != models.SyncJobFailed || store.jobErr == nil {
+		t.Fatalf("Expected a failed job with its error, got %s: %v", store.status, store.jobErr)
+	}
+	if text := store.jobErr.Error(); strings.Contains(text, "KEY123") || strings.Contains(text, "SECRET456") {
+		t.Errorf("Credentials leaked into the job error %q", text)
+	}
+}
+
+func TestRunSyncJob_CancelledJobIsInterrupted(t *testing.T) {
+	store := newFakeSyncStore()
+	job := &models.SyncJob{ID: 1, Mode: string(SyncFull), States: []string{"NJ"}, EventTypes: []string{"running_race"}}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := runSyncJob(ctx, store, job); err == nil {
+		t.Errorf("Expected the cancellation to be returned")
+	}
+	if store.status != models.SyncJobInterrupted {
+		t.Errorf("Expected an interrupted job, got %s", store.status)
+	}
+}
+
+func TestParseSyncMode(t *testing.T) {
+	if mode, err := ParseSyncMode(""); err != nil || mode != SyncAuto {
+		t.Errorf("Expected the default mode to be auto, got %v, %v", mode, err)
+	}
+	if _, err := ParseSyncMode("sometimes"); err == nil {
+		t.Errorf("Expected an invalid mode to be rejected")
+	}
+}
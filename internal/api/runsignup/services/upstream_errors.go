@@ -0,0 +1,86 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	apperrors "github.com/rbungay/racedatabase-api/pkg/errors"
+)
+
+// requestError classifies a failed RunSignup request as a timeout or an
+// unavailable upstream
+func requestError(err error) error {
+	err = redactRequestURL(err)
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return apperrors.Timeout(fmt.Errorf("request failed: %w", err))
+	}
+	return apperrors.UpstreamUnavailable(fmt.Errorf("request failed: %w", err))
+}
+
+// redactRequestURL drops the query string, which carries the API key and
+// secret, from the URL a failed request reports, so the error can be logged
+// and stored
+func redactRequestURL(err error) error {
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		urlErr.URL, _, _ = strings.Cut(urlErr.URL, "?")
+	}
+	return err
+}
+
+// statusError classifies a non-200 RunSignup response. The body is kept in
+// the error for logs but never shown to API clients.
+func statusError(resp *http.Response, body []byte) error {
+	err := fmt.Errorf("API error: status %d - response: %s", resp.StatusCode, string(body))
+	if resp.StatusCode == http.StatusTooManyRequests {
+		retryAfter := time.Duration(0)
+		if seconds, convErr := strconv.Atoi(resp.Header.Get("Retry-After")); convErr == nil {
+			retryAfter = time.Duration(seconds) * time.Second
+		}
+		return apperrors.RateLimited(err, retryAfter)
+	}
+	return apperrors.UpstreamUnavailable(err)
+}
+
+// runSignupRaceNotFound is the error_code RunSignup reports a missing race
+// with
+const runSignupRaceNotFound = 201
+
+// bodyError classifies the error object RunSignup answers some failed
+// requests with, such as bad credentials or throttling, despite a 200
+// status. Only a missing race is reported as not found; anything else is
+// an unusable response. It returns nil for a body without an error.
+func bodyError(body []byte) error {
+	if !bytes.Contains(body, []byte(`"error"`)) {
+		return nil
+	}
+	var envelope struct {
+		Error json.RawMessage `json:"error"`
+	}
+	if json.Unmarshal(body, &envelope) != nil || len(envelope.Error) == 0 || string(envelope.Error) == "null" {
+		return nil
+	}
+
+	var detail struct {
+		Code    int    `json:"error_code"`
+		Message string `json:"error_msg"`
+	}
+	if json.Unmarshal(envelope.Error, &detail) != nil {
+		// Some endpoints send the error as a bare string
+		json.Unmarshal(envelope.Error, &detail.Message)
+	}
+	err := apperrors.UpstreamUnavailable(fmt.Errorf("API error %d: %s", detail.Code, detail.Message))
+	if detail.Code == runSignupRaceNotFound {
+		return fmt.Errorf("%w: %w", errUpstreamNotFound, err)
+	}
+	return err
+}
@@ -0,0 +1,137 @@
+package storage
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/rbungay/racedatabase-api/internal/api/runsignup/models"
+)
+
+// utcToday is the UTC day usage is counted against
+const utcToday = `(NOW() AT TIME ZONE 'UTC')::date`
+
+const apiKeyColumns = `k.id, k.name, k.prefix, k.rate_limit, k.daily_quota, k.created_at, k.revoked_at,
+	COALESCE(u.requests, 0)`
+
+const apiKeysWithUsage = `
+	SELECT ` + apiKeyColumns + `
+	FROM api_keys k
+	LEFT JOIN api_key_usage u ON u.key_id = k.id AND u.day = ` + utcToday
+
+// CreateAPIKey stores a new key by its hash, filling in its ID and creation
+// time
+func (s *SupabaseStorage) CreateAPIKey(key *models.APIKey, hash []byte) error {
+	return s.db.QueryRow(`
+		INSERT INTO api_keys (name, prefix, key_hash, rate_limit, daily_quota)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, created_at
+	`, key.Name, key.Prefix, hash, key.RateLimit, key.DailyQuota).Scan(&key.ID, &key.CreatedAt)
+}
+
+// APIKeyByHash returns the key with hash, revoked or not, or nil if there
+// is none
+func (s *SupabaseStorage) APIKeyByHash(hash []byte) (*models.APIKey, error) {
+	key, err := scanAPIKey(s.db.QueryRow(apiKeysWithUsage+` WHERE k.key_hash = $1`, hash))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return key, err
+}
+
+// APIKey returns a key with its usage over the last days days, or nil if
+// it does not exist
+func (s *SupabaseStorage) APIKey(id int64, days int) (*models.APIKey, error) {
+	key, err := scanAPIKey(s.db.QueryRow(apiKeysWithUsage+` WHERE k.id = $1`, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := s.db.Query(`
+		SELECT day, requests, last_used_at
+		FROM api_key_usage
+		WHERE key_id = $1 AND day > `+utcToday+` - $2::int
+		ORDER BY day DESC
+	`, id, days)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			usage models.APIKeyUsage
+			day   time.Time
+		)
+		if err := rows.Scan(&day, &usage.Requests, &usage.LastUsedAt); err != nil {
+			return nil, err
+		}
+		usage.Day = day.Format(time.DateOnly)
+		key.Usage = append(key.Usage, usage)
+	}
+	return key, rows.Err()
+}
+
+// APIKeys lists every key, newest first, with its requests today
+func (s *SupabaseStorage) APIKeys() ([]models.APIKey, error) {
+	rows, err := s.db.Query(apiKeysWithUsage + ` ORDER BY k.created_at DESC, k.id DESC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := []models.APIKey{}
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, *key)
+	}
+	return keys, rows.Err()
+}
+
+// CountAPIKeyUse records a request made with a key and returns how many it
+// has made today, this one included
+func (s *SupabaseStorage) CountAPIKeyUse(id int64) (int64, error) {
+	var requests int64
+	err := s.db.QueryRow(`
+		INSERT INTO api_key_usage (key_id, day, requests)
+		VALUES ($1, `+utcToday+`, 1)
+		ON CONFLICT (key_id, day) DO UPDATE SET
+			requests = api_key_usage.requests + 1,
+			last_used_at = NOW()
+		RETURNING requests
+	`, id).Scan(&requests)
+	return requests, err
+}
+
+// RevokeAPIKey marks a key revoked, returning when. A key that was already
+// revoked keeps its original time.
+func (s *SupabaseStorage) RevokeAPIKey(id int64) (time.Time, error) {
+	var revoked time.Time
+	err := s.db.QueryRow(`
+		UPDATE api_keys SET revoked_at = COALESCE(revoked_at, NOW())
+		WHERE id = $1
+		RETURNING revoked_at
+	`, id).Scan(&revoked)
+	return revoked, err
+}
+
+func scanAPIKey(row interface{ Scan(...any) error }) (*models.APIKey, error) {
+	var (
+		key     models.APIKey
+		revoked sql.NullTime
+	)
+	err := row.Scan(&key.ID, &key.Name, &key.Prefix, &key.RateLimit, &key.DailyQuota,
+		&key.CreatedAt, &revoked, &key.RequestsToday)
+	if err != nil {
+		return nil, err
+	}
+	if revoked.Valid {
+		key.RevokedAt = &revoked.Time
+	}
+	return &key, nil
+}
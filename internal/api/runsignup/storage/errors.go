@@ -0,0 +1,12 @@
+package storage
+
+import "regexp"
+
+// credentialParam matches RunSignup credentials in a request URL
+var credentialParam = regexp.MustCompile(`(api_key|api_secret)=[^&\s"]*`)
+
+// errorMessage is the text of err as stored for operators to read, with any
+// RunSignup credentials that made it into the error blanked out
+func errorMessage(err error) string {
+	return credentialParam.ReplaceAllString(err.Error(), "${1}=REDACTED")
+}
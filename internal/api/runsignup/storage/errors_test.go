@@ -0,0 +1,19 @@
+package storage
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestErrorMessage_RedactsCredentials(t *testing.T) {
+	err := errors.New(`Get "http://127.0.0.1:1/races?api_key=KEY&api_secret=SECRET&format=json": dial tcp 127.0.0.1:1: connection refused`)
+
+	message := errorMessage(err)
+	if strings.Contains(message, "KEY") || strings.Contains(message, "SECRET") {
+		t.Errorf("Credentials leaked into %q", message)
+	}
+	if !strings.Contains(message, "api_key=REDACTED&api_secret=REDACTED&format=json") || !strings.Contains(message, "connection refused") {
+		t.Errorf("Expected the rest of the error to be kept, got %q", message)
+	}
+}
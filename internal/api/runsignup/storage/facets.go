@@ -0,0 +1,188 @@
+package storage
+
+import (
+	"database/sql"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/rbungay/racedatabase-api/internal/api/runsignup/constants"
+	"github.com/rbungay/racedatabase-api/internal/api/runsignup/models"
+)
+
+// facetEvent is the per-event data facets are computed from
+type facetEvent struct {
+	category  string
+	eventType string
+	distance  string
+	startTime string
+	fee       *float64
+}
+
+// bucket is a labelled half-open range [min, max)
+type bucket struct {
+	label    string
+	min, max float64
+}
+
+// distanceBuckets are in miles; the race distances sit in narrow buckets of
+// their own so "5K" is not split across neighbours
+var distanceBuckets = []bucket{
+	{"under_5k", 0, 3.0},
+	{"5k", 3.0, 3.2},
+	{"5k_to_10k", 3.2, 6.1},
+	{"10k", 6.1, 6.3},
+	{"10k_to_half", 6.3, 13.0},
+	{"half_marathon", 13.0, 13.2},
+	{"half_to_marathon", 13.2, 26.1},
+	{"marathon", 26.1, 26.3},
+	{"ultra", 26.3, 1e9},
+}
+
+// priceBuckets are in dollars of the lowest race fee
+var priceBuckets = []bucket{
+	{"free", 0, 0.01},
+	{"under_25", 0.01, 25},
+	{"25_to_50", 25, 50},
+	{"50_to_100", 50, 100},
+	{"100_plus", 100, 1e9},
+}
+
+var distancePattern = regexp.MustCompile(`(?i)^\s*([\d.]+)\s*(k|km|kilometers?|m|meters?|mi|miles?|y|yards?)?\b`)
+
+// distanceMiles parses RunSignup distance strings such as "5K", "13.1 Miles",
+// "1 Mile" or "400 Meters", and the common names "Half Marathon" and
+// "Marathon"
+func distanceMiles(distance string) (float64, bool) {
+	lower := strings.ToLower(strings.TrimSpace(distance))
+	switch {
+	case strings.Contains(lower, "half marathon"):
+		return 13.1, true
+	case strings.Contains(lower, "marathon"):
+		return 26.2, true
+	}
+
+	match := distancePattern.FindStringSubmatch(lower)
+	if match == nil {
+		return 0, false
+	}
+	value, err := strconv.ParseFloat(match[1], 64)
+	if err != nil {
+		return 0, false
+	}
+
+	switch unit := match[2]; {
+	case unit == "k" || strings.HasPrefix(unit, "km") || strings.HasPrefix(unit, "kilometer"):
+		return value * 0.621371, true
+	case unit == "m" || strings.HasPrefix(unit, "meter"):
+		return value / 1609.344, true
+	case unit == "y" || strings.HasPrefix(unit, "yard"):
+		return value / 1760, true
+	default:
+		return value, true
+	}
+}
+
+// eventDistanceMiles is the distance_miles stored with an event, which
+// facets are bucketed by in SQL
+func eventDistanceMiles(distance string) sql.NullFloat64 {
+	miles, ok := distanceMiles(distance)
+	return sql.NullFloat64{Float64: miles, Valid: ok}
+}
+
+func bucketFor(buckets []bucket, value float64) string {
+	for _, b := range buckets {
+		if value >= b.min && value < b.max {
+			return b.label
+		}
+	}
+	return ""
+}
+
+// computeFacets counts races per facet value over a filtered result set
+func computeFacets(races []models.Race, events map[int][]facetEvent) *models.Facets {
+	counts := map[string]map[string]int{}
+	add := func(facet string, values map[string]bool) {
+		if counts[facet] == nil {
+			counts[facet] = map[string]int{}
+		}
+		for value := range values {
+			if value != "" {
+				counts[facet][value]++
+			}
+		}
+	}
+
+	for _, race := range races {
+		values := map[string]map[string]bool{
+			"category": {}, "event_type": {}, "distance": {}, "month": {}, "price": {},
+		}
+		for _, event := range events[race.ID] {
+			category := event.category
+			if category == "" {
+				category = string(constants.CategoryOther)
+			}
+			values["category"][category] = true
+			values["event_type"][event.eventType] = true
+			if miles, ok := distanceMiles(event.distance); ok {
+				values["distance"][bucketFor(distanceBuckets, miles)] = true
+			}
+			if start := nullTime(event.startTime); start.Valid {
+				values["month"][start.Time.Format("2006-01")] = true
+			}
+			if event.fee != nil {
+				values["price"][bucketFor(priceBuckets, *event.fee)] = true
+			}
+		}
+		if len(events[race.ID]) == 0 && race.Category != "" {
+			values["category"][race.Category] = true
+		}
+		for facet, set := range values {
+			add(facet, set)
+		}
+		add("city", map[string]bool{race.City: true})
+	}
+
+	return facetsFrom(counts)
+}
+
+// facetsFrom orders the counts per facet value of each facet
+func facetsFrom(counts map[string]map[string]int) *models.Facets {
+	return &models.Facets{
+		Category:  byCount(counts["category"]),
+		EventType: byCount(counts["event_type"]),
+		Distance:  byBucket(counts["distance"], distanceBuckets),
+		Month:     byValue(counts["month"]),
+		Price:     byBucket(counts["price"], priceBuckets),
+		City:      byCount(counts["city"]),
+	}
+}
+
+// byCount orders facet values by descending count, then value
+func byCount(counts map[string]int) []models.FacetCount {
+	facets := byValue(counts)
+	sort.SliceStable(facets, func(i, j int) bool { return facets[i].Count > facets[j].Count })
+	return facets
+}
+
+// byValue orders facet values alphabetically, which is chronological for months
+func byValue(counts map[string]int) []models.FacetCount {
+	facets := []models.FacetCount{}
+	for value, count := range counts {
+		facets = append(facets, models.FacetCount{Value: value, Count: count})
+	}
+	sort.Slice(facets, func(i, j int) bool { return facets[i].Value < facets[j].Value })
+	return facets
+}
+
+// byBucket orders facet values in bucket order
+func byBucket(counts map[string]int, buckets []bucket) []models.FacetCount {
+	facets := []models.FacetCount{}
+	for _, b := range buckets {
+		if count := counts[b.label]; count > 0 {
+			facets = append(facets, models.FacetCount{Value: b.label, Count: count})
+		}
+	}
+	return facets
+}
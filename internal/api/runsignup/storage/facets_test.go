@@ -0,0 +1,159 @@
+package storage
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/rbungay/racedatabase-api/internal/api/runsignup/models"
+)
+
+func TestDistanceMiles(t *testing.T) {
+	for distance, want := range map[string]float64{
+		"5K":            3.107,
+		"10k Run":       6.214,
+		"13.1 Miles":    13.1,
+		"1 Mile":        1,
+		"400 Meters":    0.249,
+		"Half Marathon": 13.1,
+		"Marathon":      26.2,
+	} {
+		got, ok := distanceMiles(distance)
+		if !ok || math.Abs(got-want) > 0.01 {
+			t.Errorf("distanceMiles(%q) = %v, %v; want %v", distance, got, ok, want)
+		}
+	}
+
+	if _, ok := distanceMiles("Kids Dash"); ok {
+		t.Errorf("Expected unparseable distance to be rejected")
+	}
+}
+
+func TestComputeFacets(t *testing.T) {
+	fee25, fee80 := 25.0, 80.0
+	races := []models.Race{
+		{ID: 1, Location: models.Location{City: "Hoboken"}},
+		{ID: 2, Location: models.Location{City: "Hoboken"}},
+		{ID: 3, Location: models.Location{City: "Newark"}},
+	}
+	events := map[int][]facetEvent{
+		1: {
+			{category: "Runs", eventType: "running_race", distance: "5K", startTime: "11/27/2025 08:00", fee: &fee25},
+			{category: "Runs", eventType: "running_race", distance: "1 Mile", startTime: "11/27/2025 09:00"},
+		},
+		2: {{category: "Walks", eventType: "walking_only", distance: "5K", startTime: "12/6/2025 09:00", fee: &fee80}},
+		3: {{category: "Runs", eventType: "trail_race", distance: "Half Marathon", startTime: "11/2/2025 07:00"}},
+	}
+
+	facets := computeFacets(races, events)
+
+	// Race 1 has two running events but is counted once
+	if len(facets.Category) != 2 || facets.Category[0] != (models.FacetCount{Value: "Runs", Count: 2}) {
+		t.Errorf("Unexpected category facets: %+v", facets.Category)
+	}
+	if facets.City[0] != (models.FacetCount{Value: "Hoboken", Count: 2}) {
+		t.Errorf("Unexpected city facets: %+v", facets.City)
+	}
+	wantDistance := []models.FacetCount{{Value: "under_5k", Count: 1}, {Value: "5k", Count: 2}, {Value: "half_marathon", Count: 1}}
+	if len(facets.Distance) != len(wantDistance) {
+		t.Fatalf("Unexpected distance facets: %+v", facets.Distance)
+	}
+	for i := range wantDistance {
+		if facets.Distance[i] != wantDistance[i] {
+			t.Errorf("Unexpected distance facet %d: got %+v, want %+v", i, facets.Distance[i], wantDistance[i])
+		}
+	}
+	if len(facets.Month) != 2 || facets.Month[0] != (models.FacetCount{Value: "2025-11", Count: 2}) {
+		t.Errorf("Unexpected month facets: %+v", facets.Month)
+	}
+	if len(facets.Price) != 2 || facets.Price[0].Value != "25_to_50" || facets.Price[1].Value != "50_to_100" {
+		t.Errorf("Unexpected price facets: %+v", facets.Price)
+	}
+}
+
+// facetRounds are two syncs covering every facet: fees from free to over
+// $100 and missing, distances in and between the buckets, blank categories,
+// a race without events and one in another state. The second sync drops an
+// event, which must no longer be counted.
+func facetRounds() [][]*models.RaceDetails {
+	event := func(id int, category, eventType, distance, start string, fees ...string) models.EventDetails {
+		e := models.EventDetails{EventID: id, Name: fmt.Sprintf("Event %d", id), Category: category,
+			EventType: eventType, Distance: distance, StartTime: start}
+		for i, fee := range fees {
+			e.RegPeriods = append(e.RegPeriods, models.RegistrationPeriod{
+				Opens: fmt.Sprintf("1/%d/2026 00:00", i+1), Closes: fmt.Sprintf("1/%d/2026 23:59", i+1), Fee: fee, ProcFee: "$1.00"})
+		}
+		return e
+	}
+	races := func(dropped bool) []*models.RaceDetails {
+		trot := &models.RaceDetails{ID: 1, Name: "Hoboken Turkey Trot", NextDate: "2026-11-26",
+			Location: models.Location{City: "Hoboken", State: "NJ"},
+			Events: []models.EventDetails{
+				event(11, "Runs", "running_race", "5K", "11/26/2026 08:00", "$30.00", "$25.00"),
+				event(12, "", "running_race", "1 Mile", "11/26/2026 09:00", "$0.00"),
+			}}
+		if !dropped {
+			trot.Events = append(trot.Events, event(13, "Walks", "walking_only", "10K", "11/26/2026 10:00", "$15.00"))
+		}
+		return []*models.RaceDetails{
+			trot,
+			{ID: 2, Name: "Jersey City Half", NextDate: "2026-12-06",
+				Location: models.Location{City: "Jersey City", State: "NJ"},
+				Events: []models.EventDetails{
+					event(21, "Runs", "running_race", "Half Marathon", "12/6/2026 07:00", "$120.00"),
+					event(22, "Runs", "running_race", "8K", "12/6/2026 07:30", "$60.00"),
+					event(23, "Runs", "trail_race", "50K", "1/3/2027 06:00"),
+				}},
+			{ID: 3, Name: "Hoboken Relay", Category: "Relays", NextDate: "2026-10-31",
+				Location: models.Location{City: "Hoboken", State: "NJ"}},
+			{ID: 4, Name: "Philadelphia Marathon", NextDate: "2026-11-22",
+				Location: models.Location{City: "Philadelphia", State: "PA"},
+				Events:   []models.EventDetails{event(41, "Runs", "running_race", "Marathon", "11/22/2026 07:00", "$150.00")}},
+		}
+	}
+	return [][]*models.RaceDetails{races(false), races(true)}
+}
+
+// TestFacets_BackendsAgree counts the same races with MemoryStorage, which
+// computes facets in Go, and SupabaseStorage, which counts them in SQL
+func TestFacets_BackendsAgree(t *testing.T) {
+	db := postgresStorage(t, "facets_test")
+	memory := NewMemoryStorage()
+
+	for round, races := range facetRounds() {
+		if err := db.SaveRaces(races); err != nil {
+			t.Fatalf("Round %d: SaveRaces failed: %v", round, err)
+		}
+		if err := memory.SaveRaces(races); err != nil {
+			t.Fatalf("Round %d: SaveRaces failed: %v", round, err)
+		}
+
+		for _, query := range []models.RaceQuery{{}, {State: "NJ"}, {City: "Hoboken"}} {
+			want, err := memory.RaceFacets(query)
+			if err != nil {
+				t.Fatalf("Round %d: MemoryStorage.RaceFacets failed: %v", round, err)
+			}
+			got, err := db.RaceFacets(query)
+			if err != nil {
+				t.Fatalf("Round %d: SupabaseStorage.RaceFacets failed: %v", round, err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("Round %d: RaceFacets(%+v) differ.\nMemoryStorage:   %+v\nSupabaseStorage: %+v", round, query, *want, *got)
+			}
+		}
+
+		ids := []int{1, 2, 2, 3, 99}
+		want, err := memory.EventFacets(ids)
+		if err != nil {
+			t.Fatalf("Round %d: MemoryStorage.EventFacets failed: %v", round, err)
+		}
+		got, err := db.EventFacets(ids)
+		if err != nil {
+			t.Fatalf("Round %d: SupabaseStorage.EventFacets failed: %v", round, err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("Round %d: EventFacets differ.\nMemoryStorage:   %+v\nSupabaseStorage: %+v", round, *want, *got)
+		}
+	}
+}
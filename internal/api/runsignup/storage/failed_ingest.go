@@ -0,0 +1,86 @@
+package storage
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/rbungay/racedatabase-api/internal/api/runsignup/models"
+)
+
+// RecordFailedIngest adds a race to the retry queue, or counts another
+// attempt if it is already queued. The next retry is due after baseDelay,
+// doubling with every attempt up to maxDelay.
+func (s *SupabaseStorage) RecordFailedIngest(race models.Event, stage string, ingestErr error, baseDelay, maxDelay time.Duration) error {
+	summary, err := json.Marshal(race)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.Exec(`
+		INSERT INTO failed_ingest (race_id, stage, error, summary, next_retry_at)
+		VALUES ($1, $2, $3, $4, NOW() + make_interval(secs => $5))
+		ON CONFLICT (race_id) DO UPDATE SET
+			stage = EXCLUDED.stage,
+			error = EXCLUDED.error,
+			summary = EXCLUDED.summary,
+			attempts = failed_ingest.attempts + 1,
+			last_failed_at = NOW(),
+			next_retry_at = NOW() + make_interval(secs => LEAST($5 * power(2, failed_ingest.attempts), $6))
+	`, race.ID, stage, errorMessage(ingestErr), summary, baseDelay.Seconds(), maxDelay.Seconds())
+	return err
+}
+
+// ResolveFailedIngests removes stored races from the retry queue
+func (s *SupabaseStorage) ResolveFailedIngests(raceIDs []int) error {
+	_, err := s.db.Exec(`DELETE FROM failed_ingest WHERE race_id = ANY($1)`, pq.Array(int64s(raceIDs)))
+	return err
+}
+
+// DueFailedIngests returns up to limit queued races whose retry is due and
+// that have been attempted fewer than maxAttempts times, oldest due first
+func (s *SupabaseStorage) DueFailedIngests(maxAttempts, limit int) ([]models.FailedIngest, error) {
+	return s.queryFailedIngests(`
+		WHERE next_retry_at <= NOW() AND attempts < $1
+		ORDER BY next_retry_at
+		LIMIT $2
+	`, maxAttempts, limit)
+}
+
+// FailedIngests lists up to limit queued races attempted at least
+// minAttempts times, most attempted first
+func (s *SupabaseStorage) FailedIngests(minAttempts, limit int) ([]models.FailedIngest, error) {
+	return s.queryFailedIngests(`
+		WHERE attempts >= $1
+		ORDER BY attempts DESC, last_failed_at DESC
+		LIMIT $2
+	`, minAttempts, limit)
+}
+
+func (s *SupabaseStorage) queryFailedIngests(where string, args ...interface{}) ([]models.FailedIngest, error) {
+	rows, err := s.db.Query(`
+		SELECT race_id, stage, error, attempts, summary, first_failed_at, last_failed_at, next_retry_at
+		FROM failed_ingest
+	`+where, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	failures := []models.FailedIngest{}
+	for rows.Next() {
+		var (
+			f       models.FailedIngest
+			summary []byte
+		)
+		err := rows.Scan(&f.RaceID, &f.Stage, &f.Error, &f.Attempts, &summary,
+			&f.FirstFailedAt, &f.LastFailedAt, &f.NextRetryAt)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(summary, &f.Race); err != nil {
+			return nil, err
+		}
+		failures = append(failures, f)
+	}
+	return failures, rows.Err()
+}
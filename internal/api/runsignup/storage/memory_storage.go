@@ -0,0 +1,195 @@
+package storage
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rbungay/racedatabase-api/internal/api/runsignup/models"
+)
+
+// MemoryStorage keeps races in memory. It backs local development without a
+// database and mirrors SupabaseStorage's search behaviour in tests, except
+// that text search matches whole words where Postgres also matches stems.
+type MemoryStorage struct {
+	mu    sync.RWMutex
+	races map[int]*memoryRace
+}
+
+type memoryRace struct {
+	details   models.RaceDetails
+	deletedAt time.Time
+}
+
+// NewMemoryStorage creates an empty MemoryStorage
+func NewMemoryStorage() *MemoryStorage {
+	return &MemoryStorage{races: make(map[int]*memoryRace)}
+}
+
+// SaveRace stores a copy of the race, replacing any previous version
+func (s *MemoryStorage) SaveRace(race *models.RaceDetails) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	details := *race
+	details.Events = append([]models.EventDetails(nil), race.Events...)
+	s.races[race.ID] = &memoryRace{details: details}
+	return nil
+}
+
+// SaveRaces stores a copy of each race in the batch
+func (s *MemoryStorage) SaveRaces(races []*models.RaceDetails) error {
+	for _, race := range races {
+		if err := s.SaveRace(race); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SoftDeleteRace hides a race from searches that exclude removed races
+func (s *MemoryStorage) SoftDeleteRace(raceID int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if race, exists := s.races[raceID]; exists && race.deletedAt.IsZero() {
+		race.deletedAt = time.Now()
+	}
+	return nil
+}
+
+// SearchRaces applies the same filters, ranking and ordering as
+// SupabaseStorage.SearchRaces
+func (s *MemoryStorage) SearchRaces(query models.RaceQuery) ([]models.Race, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	races := []models.Race{}
+	for _, stored := range s.races {
+		details := stored.details
+		if query.State != "" && details.State != query.State {
+			continue
+		}
+		if query.City != "" && !strings.EqualFold(details.City, query.City) {
+			continue
+		}
+		if query.PostalCode != "" && details.PostalCode != query.PostalCode {
+			continue
+		}
+		if !query.IncludeRemoved && !stored.deletedAt.IsZero() {
+			continue
+		}
+
+		race := stored.race()
+		if query.Text != "" {
+			eventNames := make([]string, 0, len(details.Events))
+			for _, event := range details.Events {
+				eventNames = append(eventNames, event.Name)
+			}
+			rank, snippets, ok := textMatch(query.Text, map[string]string{
+				"name":   details.Name,
+				"events": strings.Join(eventNames, " | "),
+				"city":   details.City,
+			})
+			if !ok {
+				continue
+			}
+			race.Rank = &rank
+			race.Highlights = snippets
+		}
+		races = append(races, race)
+	}
+
+	sort.Slice(races, func(i, j int) bool {
+		if query.Text != "" && *races[i].Rank != *races[j].Rank {
+			return *races[i].Rank > *races[j].Rank
+		}
+		if races[i].Name != races[j].Name {
+			return races[i].Name < races[j].Name
+		}
+		return races[i].ID < races[j].ID
+	})
+
+	if query.Near != nil {
+		races = withinRadius(races, *query.Near, query.RadiusMiles)
+	}
+	return paginate(races, query.Limit, query.Offset), nil
+}
+
+// RaceFacets counts the races matching query (ignoring its limit and offset)
+// by category, event type, distance, month, price and city
+func (s *MemoryStorage) RaceFacets(query models.RaceQuery) (*models.Facets, error) {
+	query.Limit, query.Offset = 0, 0
+	races, err := s.SearchRaces(query)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.facets(races), nil
+}
+
+// EventFacets counts the stored races with ids by the same facets as
+// RaceFacets
+func (s *MemoryStorage) EventFacets(raceIDs []int) (*models.Facets, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	races := []models.Race{}
+	seen := make(map[int]bool, len(raceIDs))
+	for _, id := range raceIDs {
+		if stored, exists := s.races[id]; exists && !seen[id] {
+			seen[id] = true
+			races = append(races, stored.race())
+		}
+	}
+	return s.facets(races), nil
+}
+
+// facets counts races by their stored events. The caller holds s.mu.
+func (s *MemoryStorage) facets(races []models.Race) *models.Facets {
+	events := make(map[int][]facetEvent, len(races))
+	for _, race := range races {
+		stored, exists := s.races[race.ID]
+		if !exists {
+			continue
+		}
+		for _, event := range stored.details.Events {
+			fe := facetEvent{
+				category:  event.Category,
+				eventType: event.EventType,
+				distance:  event.Distance,
+				startTime: event.StartTime,
+			}
+			for _, period := range event.RegPeriods {
+				if fee := cleanFeeString(period.Fee); fe.fee == nil || fee < *fe.fee {
+					fe.fee = &fee
+				}
+			}
+			events[race.ID] = append(events[race.ID], fe)
+		}
+	}
+
+	return computeFacets(races, events)
+}
+
+// race converts the stored details into the search result shape
+func (r *memoryRace) race() models.Race {
+	race := models.Race{
+		ID:          r.details.ID,
+		Name:        r.details.Name,
+		URL:         r.details.URL,
+		ExternalURL: r.details.ExternalURL,
+		LogoURL:     r.details.LogoURL,
+		Timezone:    r.details.Timezone,
+		Category:    r.details.Category,
+		NextDate:    normalizeDate(r.details.NextDate),
+		Location:    r.details.Location,
+	}
+	if !r.deletedAt.IsZero() {
+		race.RemovedAt = r.deletedAt.Format(time.RFC3339)
+	}
+	return race
+}
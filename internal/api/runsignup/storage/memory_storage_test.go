@@ -0,0 +1,113 @@
+package storage
+
+import (
+	"testing"
+
+	"github.com/rbungay/racedatabase-api/internal/api/runsignup/models"
+	"github.com/rbungay/racedatabase-api/pkg/geo"
+)
+
+func seededMemoryStorage(t *testing.T) *MemoryStorage {
+	t.Helper()
+	jerseyCity, hoboken := 40.7178, 40.7454
+	jerseyCityLng, hobokenLng := -74.0466, -74.0279
+
+	s := NewMemoryStorage()
+	for _, race := range []*models.RaceDetails{
+		{
+			ID:       1,
+			Name:     "Hoboken Turkey Trot",
+			Location: models.Location{City: "Hoboken", State: "NJ", Latitude: &hoboken, Longitude: &hobokenLng},
+			Events:   []models.EventDetails{{EventID: 11, Name: "5K Run"}, {EventID: 12, Name: "Kids Fun Run"}},
+		},
+		{
+			ID:       2,
+			Name:     "Liberty State Park 10K",
+			Location: models.Location{City: "Jersey City", State: "NJ", Latitude: &jerseyCity, Longitude: &jerseyCityLng},
+			Events:   []models.EventDetails{{EventID: 21, Name: "Turkey Trot 1 Mile"}},
+		},
+		{
+			ID:       3,
+			Name:     "Color Run Philadelphia",
+			Location: models.Location{City: "Philadelphia", State: "PA"},
+			Events:   []models.EventDetails{{EventID: 31, Name: "Color 5K"}},
+		},
+	} {
+		if err := s.SaveRace(race); err != nil {
+			t.Fatalf("SaveRace failed: %v", err)
+		}
+	}
+	return s
+}
+
+func TestMemoryStorage_TextSearch(t *testing.T) {
+	s := seededMemoryStorage(t)
+
+	races, err := s.SearchRaces(models.RaceQuery{Text: "turkey trot", Limit: 10})
+	if err != nil {
+		t.Fatalf("SearchRaces failed: %v", err)
+	}
+
+	if len(races) != 2 {
+		t.Fatalf("Expected 2 matches for turkey trot, got %d", len(races))
+	}
+	// A match in the race name outranks a match in an event name
+	if races[0].ID != 1 || races[1].ID != 2 {
+		t.Errorf("Unexpected ranking: got IDs %d, %d", races[0].ID, races[1].ID)
+	}
+	if got := races[0].Highlights["name"]; got != "Hoboken <mark>Turkey</mark> <mark>Trot</mark>" {
+		t.Errorf("Unexpected name highlight: %q", got)
+	}
+	if got := races[1].Highlights["events"]; got != "<mark>Turkey</mark> <mark>Trot</mark> 1 Mile" {
+		t.Errorf("Unexpected events highlight: %q", got)
+	}
+}
+
+func TestMemoryStorage_TextSearchRequiresAllTerms(t *testing.T) {
+	s := seededMemoryStorage(t)
+
+	races, err := s.SearchRaces(models.RaceQuery{Text: "color run", Limit: 10})
+	if err != nil {
+		t.Fatalf("SearchRaces failed: %v", err)
+	}
+	if len(races) != 1 || races[0].ID != 3 {
+		t.Errorf("Expected only the color run, got %+v", races)
+	}
+}
+
+func TestMemoryStorage_FiltersAndProximity(t *testing.T) {
+	s := seededMemoryStorage(t)
+	if err := s.SoftDeleteRace(2); err != nil {
+		t.Fatalf("SoftDeleteRace failed: %v", err)
+	}
+
+	races, _ := s.SearchRaces(models.RaceQuery{State: "NJ", Limit: 10})
+	if len(races) != 1 || races[0].ID != 1 {
+		t.Errorf("Expected removed race to be excluded, got %+v", races)
+	}
+
+	races, _ = s.SearchRaces(models.RaceQuery{
+		IncludeRemoved: true,
+		Near:           &geo.Point{Lat: 40.7178, Lng: -74.0466},
+		RadiusMiles:    10,
+		Limit:          10,
+	})
+	if len(races) != 2 || races[0].ID != 2 || races[0].RemovedAt == "" {
+		t.Errorf("Expected nearest removed race first, got %+v", races)
+	}
+}
+
+func TestMemoryStorage_EventFacets(t *testing.T) {
+	s := seededMemoryStorage(t)
+
+	// Races the search found but never stored are not counted
+	facets, err := s.EventFacets([]int{1, 3, 3, 99})
+	if err != nil {
+		t.Fatalf("EventFacets failed: %v", err)
+	}
+
+	want := []models.FacetCount{{Value: "Hoboken", Count: 1}, {Value: "Philadelphia", Count: 1}}
+	if len(facets.City) != len(want) || facets.City[0] != want[0] || facets.City[1] != want[1] {
+		t.Errorf("Unexpected city facets: got %v want %v", facets.City, want)
+	}
+}
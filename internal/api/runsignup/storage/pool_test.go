@@ -0,0 +1,41 @@
+package storage
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPoolConfig_Apply(t *testing.T) {
+	s, _ := newRoundTripStorage(0)
+	PoolConfig{MaxOpenConns: 7, MaxIdleConns: 3, ConnMaxLifetime: time.Minute}.apply(s.db)
+
+	if _, err := s.db.Exec("SELECT 1"); err != nil {
+		t.Fatalf("Exec failed: %v", err)
+	}
+	stats := s.PoolStats()
+	if stats.MaxOpen != 7 {
+		t.Errorf("Expected a pool of 7 connections, got %d", stats.MaxOpen)
+	}
+	if stats.Open != 1 || stats.Idle != 1 || stats.InUse != 0 {
+		t.Errorf("Expected one idle connection after a query, got %+v", stats)
+	}
+}
+
+func TestPoolConfig_ZeroKeepsDefaults(t *testing.T) {
+	s, _ := newRoundTripStorage(0)
+	PoolConfig{}.apply(s.db)
+
+	if stats := s.PoolStats(); stats.MaxOpen != 0 {
+		t.Errorf("Expected an unlimited pool, got %d connections", stats.MaxOpen)
+	}
+}
+
+func TestClose(t *testing.T) {
+	s, _ := newRoundTripStorage(0)
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if err := s.db.Ping(); err == nil || err.Error() != "sql: database is closed" {
+		t.Errorf("Expected the pool to be closed, got %v", err)
+	}
+}
@@ -0,0 +1,84 @@
+package storage
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/rbungay/racedatabase-api/internal/api/runsignup/models"
+)
+
+// recordPrice appends a price_history row for the period unless the latest
+// recorded fee for the same period is unchanged.
+func recordPrice(tx *sql.Tx, eventID int, period models.RegistrationPeriod) error {
+	_, err := tx.Exec(`
+		INSERT INTO price_history (event_id, opens_at, closes_at, race_fee, processing_fee)
+		SELECT $1::bigint, $2::timestamptz, $3::timestamptz, $4::numeric, $5::numeric
+		WHERE NOT EXISTS (
+			SELECT 1 FROM (
+				SELECT race_fee, processing_fee FROM price_history
+				WHERE event_id = $1
+				  AND opens_at IS NOT DISTINCT FROM $2
+				  AND closes_at IS NOT DISTINCT FROM $3
+				ORDER BY recorded_at DESC, id DESC
+				LIMIT 1
+			) latest
+			WHERE latest.race_fee = $4 AND latest.processing_fee = $5
+		)
+	`, eventID,
+		nullTime(period.Opens), nullTime(period.Closes),
+		cleanFeeString(period.Fee), cleanFeeString(period.ProcFee))
+	return err
+}
+
+// GetPriceHistory returns the fee timeline of every event in a race, oldest
+// observation first.
+func (s *SupabaseStorage) GetPriceHistory(raceID int) (*models.RacePriceHistory, error) {
+	rows, err := s.db.Query(`
+		SELECT e.event_id, e.name, ph.opens_at, ph.closes_at, ph.race_fee, ph.processing_fee, ph.recorded_at
+		FROM events e
+		JOIN price_history ph ON ph.event_id = e.event_id
+		WHERE e.race_id = $1
+		ORDER BY e.start_time, e.event_id, ph.opens_at NULLS FIRST, ph.recorded_at, ph.id
+	`, raceID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	history := &models.RacePriceHistory{RaceID: raceID, Events: []models.EventPriceHistory{}}
+	for rows.Next() {
+		var (
+			eventID       int
+			name          string
+			opens, closes sql.NullTime
+			fee, procFee  float64
+			recordedAt    time.Time
+		)
+		if err := rows.Scan(&eventID, &name, &opens, &closes, &fee, &procFee, &recordedAt); err != nil {
+			return nil, err
+		}
+
+		n := len(history.Events)
+		if n == 0 || history.Events[n-1].EventID != eventID {
+			history.Events = append(history.Events, models.EventPriceHistory{EventID: eventID, Name: name})
+			n++
+		}
+		history.Events[n-1].Prices = append(history.Events[n-1].Prices, models.PriceChange{
+			Opens:      formatNullTime(opens),
+			Closes:     formatNullTime(closes),
+			Fee:        fee,
+			ProcFee:    procFee,
+			RecordedAt: recordedAt.Format(time.RFC3339),
+		})
+	}
+
+	return history, rows.Err()
+}
+
+// formatNullTime renders a nullable timestamp as RFC 3339, or "" when NULL
+func formatNullTime(t sql.NullTime) string {
+	if !t.Valid {
+		return ""
+	}
+	return t.Time.Format(time.RFC3339)
+}
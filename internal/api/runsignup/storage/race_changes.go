@@ -0,0 +1,202 @@
+package storage
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/rbungay/racedatabase-api/internal/api/runsignup/models"
+)
+
+// loadRace reads the stored version of a race, locking its row for the rest
+// of the transaction. It returns nil when the race has not been stored yet.
+func loadRace(tx *sql.Tx, raceID int) (*models.RaceDetails, error) {
+	races, err := loadRaces(tx, []int64{int64(raceID)})
+	if err != nil {
+		return nil, err
+	}
+	return races[raceID], nil
+}
+
+// loadRaces reads the stored versions of several races with one query per
+// table, locking their rows for the rest of the transaction. Races that have
+// not been stored yet are missing from the map.
+func loadRaces(tx *sql.Tx, raceIDs []int64) (map[int]*models.RaceDetails, error) {
+	rows, err := tx.Query(`
+		SELECT id, name, url, external_url, logo_url, timezone, address, city, state, postal_code, next_date
+		FROM races WHERE id = ANY($1)
+		ORDER BY id
+		FOR UPDATE
+	`, pq.Array(raceIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	races := make(map[int]*models.RaceDetails)
+	for rows.Next() {
+		var (
+			id                                        int
+			name, url, externalURL, logoURL, timezone sql.NullString
+			address, city, state, postalCode          sql.NullString
+			nextDate                                  sql.NullTime
+		)
+		if err := rows.Scan(&id, &name, &url, &externalURL, &logoURL, &timezone, &address, &city, &state, &postalCode, &nextDate); err != nil {
+			return nil, err
+		}
+		races[id] = &models.RaceDetails{
+			ID:          id,
+			Name:        name.String,
+			URL:         url.String,
+			ExternalURL: externalURL.String,
+			LogoURL:     logoURL.String,
+			Timezone:    timezone.String,
+			NextDate:    formatNullDate(nextDate),
+			Location: models.Location{
+				Address:    address.String,
+				City:       city.String,
+				State:      state.String,
+				PostalCode: postalCode.String,
+			},
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(races) == 0 {
+		return races, nil
+	}
+
+	events, err := tx.Query(`
+		SELECT race_id, event_id, name, start_time, end_time, event_type, distance, registration_opens, category
+		FROM events WHERE race_id = ANY($1) AND deleted_at IS NULL
+		ORDER BY race_id, event_id
+	`, pq.Array(raceIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer events.Close()
+
+	type eventRef struct {
+		race  *models.RaceDetails
+		index int
+	}
+	index := make(map[int]eventRef)
+	for events.Next() {
+		var (
+			raceID                              int
+			event                               models.EventDetails
+			eventName, eventType, distance, cat sql.NullString
+			start, end, regOpens                sql.NullTime
+		)
+		if err := events.Scan(&raceID, &event.EventID, &eventName, &start, &end, &eventType, &distance, &regOpens, &cat); err != nil {
+			return nil, err
+		}
+		race, exists := races[raceID]
+		if !exists {
+			continue
+		}
+		event.Name = eventName.String
+		event.StartTime = formatNullTime(start)
+		event.EndTime = formatNullTime(end)
+		event.EventType = eventType.String
+		event.Distance = distance.String
+		event.RegOpens = formatNullTime(regOpens)
+		event.Category = cat.String
+
+		index[event.EventID] = eventRef{race: race, index: len(race.Events)}
+		race.Events = append(race.Events, event)
+	}
+	if err := events.Err(); err != nil {
+		return nil, err
+	}
+
+	periods, err := tx.Query(`
+		SELECT rp.event_id, rp.opens_at, rp.closes_at, rp.race_fee, rp.processing_fee
+		FROM registration_periods rp
+		JOIN events e ON e.event_id = rp.event_id
+		WHERE e.race_id = ANY($1)
+		ORDER BY rp.event_id, rp.opens_at
+	`, pq.Array(raceIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer periods.Close()
+
+	for periods.Next() {
+		var (
+			eventID       int
+			opens, closes sql.NullTime
+			fee, procFee  float64
+		)
+		if err := periods.Scan(&eventID, &opens, &closes, &fee, &procFee); err != nil {
+			return nil, err
+		}
+		ref, exists := index[eventID]
+		if !exists {
+			continue
+		}
+		event := &ref.race.Events[ref.index]
+		event.RegPeriods = append(event.RegPeriods, models.RegistrationPeriod{
+			Opens:   formatNullTime(opens),
+			Closes:  formatNullTime(closes),
+			Fee:     fmt.Sprintf("%.2f", fee),
+			ProcFee: fmt.Sprintf("%.2f", procFee),
+		})
+	}
+
+	return races, periods.Err()
+}
+
+// recordRaceChange stores a non-empty diff in race_changes
+func recordRaceChange(tx *sql.Tx, raceID int, diff models.RaceDiff) error {
+	if diff.Empty() {
+		return nil
+	}
+
+	changes, err := json.Marshal(diff)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO race_changes (race_id, changes)
+		VALUES ($1, $2)
+	`, raceID, changes)
+	return err
+}
+
+// GetRaceChanges returns the recorded diffs for a race, newest first
+func (s *SupabaseStorage) GetRaceChanges(raceID int) (*models.RaceChangeLog, error) {
+	rows, err := s.db.Query(`
+		SELECT id, changes, detected_at
+		FROM race_changes
+		WHERE race_id = $1
+		ORDER BY detected_at DESC, id DESC
+	`, raceID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	log := &models.RaceChangeLog{RaceID: raceID, Changes: []models.RaceChange{}}
+	for rows.Next() {
+		var (
+			change     models.RaceChange
+			changes    []byte
+			detectedAt time.Time
+		)
+		if err := rows.Scan(&change.ID, &changes, &detectedAt); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(changes, &change.Diff); err != nil {
+			return nil, fmt.Errorf("race change %d: %w", change.ID, err)
+		}
+		change.DetectedAt = detectedAt.Format(time.RFC3339)
+		log.Changes = append(log.Changes, change)
+	}
+
+	return log, rows.Err()
+}
@@ -0,0 +1,137 @@
+package storage
+
+import (
+	"time"
+
+	"github.com/rbungay/racedatabase-api/internal/api/runsignup/models"
+)
+
+// diffRaces compares the stored version of a race with a freshly fetched one
+func diffRaces(stored, fetched *models.RaceDetails) models.RaceDiff {
+	var diff models.RaceDiff
+
+	diff.Fields = diffFields([][3]string{
+		{"name", stored.Name, fetched.Name},
+		{"url", stored.URL, fetched.URL},
+		{"external_race_url", stored.ExternalURL, fetched.ExternalURL},
+		{"logo_url", stored.LogoURL, fetched.LogoURL},
+		{"timezone", stored.Timezone, fetched.Timezone},
+		{"address", stored.Address, fetched.Address},
+		{"city", stored.City, fetched.City},
+		{"state", stored.State, fetched.State},
+		{"postal_code", stored.PostalCode, fetched.PostalCode},
+		{"next_date", normalizeDate(stored.NextDate), normalizeDate(fetched.NextDate)},
+	})
+
+	oldEvents := make(map[int]models.EventDetails, len(stored.Events))
+	for _, event := range stored.Events {
+		oldEvents[event.EventID] = event
+	}
+
+	seen := make(map[int]bool, len(fetched.Events))
+	for _, event := range fetched.Events {
+		seen[event.EventID] = true
+
+		previous, exists := oldEvents[event.EventID]
+		if !exists {
+			diff.AddedEvents = append(diff.AddedEvents, models.EventRef{EventID: event.EventID, Name: event.Name})
+			continue
+		}
+
+		change := models.EventChange{
+			EventID: event.EventID,
+			Name:    event.Name,
+			Fields: diffFields([][3]string{
+				{"name", previous.Name, event.Name},
+				{"start_time", normalizeTime(previous.StartTime), normalizeTime(event.StartTime)},
+				{"end_time", normalizeTime(previous.EndTime), normalizeTime(event.EndTime)},
+				{"event_type", previous.EventType, event.EventType},
+				{"distance", previous.Distance, event.Distance},
+			}),
+			Fees: diffFees(previous.RegPeriods, event.RegPeriods),
+		}
+		if len(change.Fields) > 0 || len(change.Fees) > 0 {
+			diff.ChangedEvents = append(diff.ChangedEvents, change)
+		}
+	}
+
+	for _, event := range stored.Events {
+		if !seen[event.EventID] {
+			diff.RemovedEvents = append(diff.RemovedEvents, models.EventRef{EventID: event.EventID, Name: event.Name})
+		}
+	}
+
+	return diff
+}
+
+// diffFields returns a FieldChange for every {field, old, new} triple that differs
+func diffFields(fields [][3]string) []models.FieldChange {
+	var changes []models.FieldChange
+	for _, f := range fields {
+		if f[1] != f[2] {
+			changes = append(changes, models.FieldChange{Field: f[0], Old: f[1], New: f[2]})
+		}
+	}
+	return changes
+}
+
+// diffFees matches registration periods by their open and close times and
+// reports the ones whose race fee changed, appeared or disappeared
+func diffFees(stored, fetched []models.RegistrationPeriod) []models.FeeChange {
+	type periodKey struct{ opens, closes string }
+	key := func(p models.RegistrationPeriod) periodKey {
+		return periodKey{normalizeTime(p.Opens), normalizeTime(p.Closes)}
+	}
+
+	oldFees := make(map[periodKey]float64, len(stored))
+	for _, period := range stored {
+		oldFees[key(period)] = cleanFeeString(period.Fee)
+	}
+
+	var changes []models.FeeChange
+	seen := make(map[periodKey]bool, len(fetched))
+	for _, period := range fetched {
+		k := key(period)
+		seen[k] = true
+		fee := cleanFeeString(period.Fee)
+
+		previous, exists := oldFees[k]
+		if exists && previous == fee {
+			continue
+		}
+		change := models.FeeChange{Opens: k.opens, Closes: k.closes, NewFee: &fee}
+		if exists {
+			change.OldFee = &previous
+		}
+		changes = append(changes, change)
+	}
+
+	for _, period := range stored {
+		k := key(period)
+		if seen[k] {
+			continue
+		}
+		seen[k] = true
+		fee := oldFees[k]
+		changes = append(changes, models.FeeChange{Opens: k.opens, Closes: k.closes, OldFee: &fee})
+	}
+
+	return changes
+}
+
+// normalizeTime renders any RunSignup or stored timestamp as RFC 3339 so that
+// equal instants compare equal; unparseable values are returned unchanged
+func normalizeTime(t string) string {
+	if parsed := nullTime(t); parsed.Valid {
+		return parsed.Time.UTC().Format(time.RFC3339)
+	}
+	return t
+}
+
+// normalizeDate renders any RunSignup or stored date as YYYY-MM-DD
+func normalizeDate(d string) string {
+	if parsed := nullTime(d); parsed.Valid {
+		return parsed.Time.Format("2006-01-02")
+	}
+	return d
+}
@@ -0,0 +1,88 @@
+package storage
+
+import (
+	"testing"
+
+	"github.com/rbungay/racedatabase-api/internal/api/runsignup/models"
+)
+
+func storedRace() *models.RaceDetails {
+	return &models.RaceDetails{
+		ID:       12345,
+		Name:     "Test Race",
+		URL:      "https://example.com",
+		Timezone: "America/New_York",
+		Events: []models.EventDetails{
+			{
+				EventID:   1,
+				Name:      "5K Run",
+				StartTime: "2025-06-01T08:00:00Z",
+				Distance:  "5K",
+				RegPeriods: []models.RegistrationPeriod{
+					{Opens: "2025-01-01T00:00:00Z", Closes: "2025-05-30T23:59:00Z", Fee: "30.00"},
+				},
+			},
+			{EventID: 2, Name: "Kids Dash", StartTime: "2025-06-01T09:00:00Z"},
+		},
+	}
+}
+
+func TestDiffRaces_NoChanges(t *testing.T) {
+	fetched := storedRace()
+	// RunSignup formats times and fees differently from what we store
+	fetched.Events[0].StartTime = "6/1/2025 08:00"
+	fetched.Events[0].RegPeriods[0] = models.RegistrationPeriod{Opens: "1/1/2025 00:00", Closes: "5/30/2025 23:59", Fee: "$30.00"}
+	fetched.Events[1].StartTime = "6/1/2025 09:00"
+
+	if diff := diffRaces(storedRace(), fetched); !diff.Empty() {
+		t.Errorf("Expected no changes, got %+v", diff)
+	}
+}
+
+func TestDiffRaces_Changes(t *testing.T) {
+	fetched := storedRace()
+	fetched.Name = "Test Race 2025"
+	fetched.Events[0].StartTime = "2025-06-01T07:30:00Z"
+	fetched.Events[0].Distance = "10K"
+	fetched.Events[0].RegPeriods[0].Fee = "$35.00"
+	fetched.Events = []models.EventDetails{fetched.Events[0], {EventID: 3, Name: "Half Marathon"}}
+
+	diff := diffRaces(storedRace(), fetched)
+
+	if len(diff.Fields) != 1 || diff.Fields[0].Field != "name" || diff.Fields[0].New != "Test Race 2025" {
+		t.Errorf("Unexpected race field changes: %+v", diff.Fields)
+	}
+	if len(diff.AddedEvents) != 1 || diff.AddedEvents[0].EventID != 3 {
+		t.Errorf("Unexpected added events: %+v", diff.AddedEvents)
+	}
+	if len(diff.RemovedEvents) != 1 || diff.RemovedEvents[0].EventID != 2 {
+		t.Errorf("Unexpected removed events: %+v", diff.RemovedEvents)
+	}
+	if len(diff.ChangedEvents) != 1 {
+		t.Fatalf("Expected one changed event, got %+v", diff.ChangedEvents)
+	}
+
+	changed := diff.ChangedEvents[0]
+	if len(changed.Fields) != 2 || changed.Fields[0].Field != "start_time" || changed.Fields[1].Field != "distance" {
+		t.Errorf("Unexpected event field changes: %+v", changed.Fields)
+	}
+	if len(changed.Fees) != 1 || *changed.Fees[0].OldFee != 30 || *changed.Fees[0].NewFee != 35 {
+		t.Errorf("Unexpected fee changes: %+v", changed.Fees)
+	}
+}
+
+func TestDiffFees_AddedAndRemovedPeriods(t *testing.T) {
+	stored := []models.RegistrationPeriod{{Opens: "1/1/2025 00:00", Closes: "3/1/2025 00:00", Fee: "$25.00"}}
+	fetched := []models.RegistrationPeriod{{Opens: "3/1/2025 00:00", Closes: "5/30/2025 23:59", Fee: "$35.00"}}
+
+	changes := diffFees(stored, fetched)
+	if len(changes) != 2 {
+		t.Fatalf("Expected two fee changes, got %+v", changes)
+	}
+	if changes[0].OldFee != nil || *changes[0].NewFee != 35 {
+		t.Errorf("Expected added period first, got %+v", changes[0])
+	}
+	if *changes[1].OldFee != 25 || changes[1].NewFee != nil {
+		t.Errorf("Expected removed period second, got %+v", changes[1])
+	}
+}
@@ -0,0 +1,417 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/lib/pq"
+	"github.com/rbungay/racedatabase-api/internal/api/runsignup/constants"
+	"github.com/rbungay/racedatabase-api/internal/api/runsignup/models"
+	"github.com/rbungay/racedatabase-api/pkg/geo"
+)
+
+// raceColumns is the column list read by scanRace
+const raceColumns = `id, name, url, external_url, logo_url, timezone, category,
+	address, city, state, postal_code, latitude, longitude, next_date,
+	last_seen_at, possibly_removed, deleted_at`
+
+// SearchRaces lists stored races, excluding soft-deleted ones unless the
+// query asks for them. Proximity searches are pre-filtered with a bounding
+// box in SQL, then filtered and sorted by haversine distance. Results are
+// served from the search cache when one is set.
+func (s *SupabaseStorage) SearchRaces(query models.RaceQuery) ([]models.Race, error) {
+	if s.searchCache != nil {
+		return s.searchCache.races(query, s.searchRaces)
+	}
+	return s.searchRaces(query)
+}
+
+// raceFilter is the WHERE clause selecting the races a query matches, with
+// its arguments numbered from $1
+type raceFilter struct {
+	conditions []string
+	args       []interface{}
+}
+
+// where adds a condition, replacing each ? in it with the next argument
+func (f *raceFilter) where(condition string, values ...interface{}) {
+	for _, v := range values {
+		f.args = append(f.args, v)
+		condition = strings.Replace(condition, "?", fmt.Sprintf("$%d", len(f.args)), 1)
+	}
+	f.conditions = append(f.conditions, condition)
+}
+
+func (f *raceFilter) sql() string {
+	if len(f.conditions) == 0 {
+		return ""
+	}
+	return " WHERE " + strings.Join(f.conditions, " AND ")
+}
+
+// newRaceFilter filters races by query's location and removal state.
+// Proximity searches only get a bounding box; the caller filters by radius.
+func newRaceFilter(query models.RaceQuery) *raceFilter {
+	f := &raceFilter{}
+	if query.State != "" {
+		f.where("state = ?", query.State)
+	}
+	if query.City != "" {
+		f.where("lower(city) = lower(?)", query.City)
+	}
+	if query.PostalCode != "" {
+		f.where("postal_code = ?", query.PostalCode)
+	}
+	if !query.IncludeRemoved {
+		f.where("deleted_at IS NULL")
+	}
+	if query.Near != nil {
+		min, max := geo.BoundingBox(*query.Near, query.RadiusMiles)
+		f.where("latitude BETWEEN ? AND ?", min.Lat, max.Lat)
+		f.where("longitude BETWEEN ? AND ?", min.Lng, max.Lng)
+	}
+	return f
+}
+
+func (s *SupabaseStorage) searchRaces(query models.RaceQuery) ([]models.Race, error) {
+	f := newRaceFilter(query)
+
+	columns, orderBy := raceColumns, "name, id"
+	if query.Text != "" {
+		f.where("search_vector @@ websearch_to_tsquery('english', ?)", query.Text)
+		tsQuery := fmt.Sprintf("websearch_to_tsquery('english', $%d)", len(f.args))
+		columns += `, ts_rank(search_vector, ` + tsQuery + `),
+			ts_headline('english', coalesce(name, ''), ` + tsQuery + `, '` + headlineOptions + `'),
+			ts_headline('english', coalesce((SELECT string_agg(e.name, ' | ' ORDER BY e.event_id)
+				FROM events e WHERE e.race_id = races.id AND e.deleted_at IS NULL), ''), ` + tsQuery + `, '` + headlineOptions + `'),
+			ts_headline('english', coalesce(city, ''), ` + tsQuery + `, '` + headlineOptions + `')`
+		orderBy = "ts_rank(search_vector, " + tsQuery + ") DESC, name, id"
+	}
+
+	sqlQuery := "SELECT " + columns + " FROM races" + f.sql() + " ORDER BY " + orderBy
+	args := f.args
+	if query.Near == nil && query.Limit > 0 {
+		args = append(args, query.Limit, query.Offset)
+		sqlQuery += fmt.Sprintf(" LIMIT $%d OFFSET $%d", len(args)-1, len(args))
+	}
+
+	rows, err := s.db.Query(sqlQuery, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	races := []models.Race{}
+	for rows.Next() {
+		if query.Text == "" {
+			race, err := scanRace(rows)
+			if err != nil {
+				return nil, err
+			}
+			races = append(races, race)
+			continue
+		}
+
+		var (
+			rank               float64
+			name, events, city string
+		)
+		race, err := scanRace(rows, &rank, &name, &events, &city)
+		if err != nil {
+			return nil, err
+		}
+		race.Rank = &rank
+		race.Highlights = highlights(map[string]string{"name": name, "events": events, "city": city})
+		races = append(races, race)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if query.Near != nil {
+		races = paginate(withinRadius(races, *query.Near, query.RadiusMiles), query.Limit, query.Offset)
+	}
+	return races, nil
+}
+
+// RaceFacets counts the races matching query (ignoring its limit and offset)
+// by category, event type, distance, month, price and city
+func (s *SupabaseStorage) RaceFacets(query models.RaceQuery) (*models.Facets, error) {
+	query.Limit, query.Offset = 0, 0
+	if s.searchCache != nil {
+		return s.searchCache.facets(query, s.raceFacets)
+	}
+	return s.raceFacets(query)
+}
+
+func (s *SupabaseStorage) raceFacets(query models.RaceQuery) (*models.Facets, error) {
+	f := newRaceFilter(query)
+	if query.Near != nil {
+		// The same haversine distance as geo.DistanceMiles
+		f.where(`2 * ?::float8 * asin(least(1, sqrt(
+			power(sin(radians(latitude - ?) / 2), 2) +
+			cos(radians(?)) * cos(radians(latitude)) * power(sin(radians(longitude - ?) / 2), 2)))) <= ?`,
+			geo.EarthRadiusMiles, query.Near.Lat, query.Near.Lat, query.Near.Lng, query.RadiusMiles)
+	}
+	if query.Text != "" {
+		f.where("search_vector @@ websearch_to_tsquery('english', ?)", query.Text)
+	}
+	return s.countFacets("SELECT id, city, category FROM races"+f.sql(), f.args)
+}
+
+// EventFacets counts the races with ids, as returned by an events search,
+// by the same facets as RaceFacets. Races that have not been stored are not
+// counted.
+func (s *SupabaseStorage) EventFacets(raceIDs []int) (*models.Facets, error) {
+	return s.countFacets("SELECT id, city, category FROM races WHERE id = ANY($1)",
+		[]interface{}{pq.Array(int64s(raceIDs))})
+}
+
+// facetCounts counts the races selected by matched, a query returning their
+// id, city and category, per facet value. A race is counted once per value
+// however many of its events share it; races without events count towards
+// their own category. Each event row yields one row with its lowest fee, so
+// nothing depends on how the events table is keyed. Buckets are passed in
+// from distanceBuckets and priceBuckets so they are only defined once.
+const facetCounts = `
+	WITH matched AS (%s),
+	race_events AS (
+		SELECT e.race_id,
+			COALESCE(NULLIF(e.category, ''), '%s') AS category,
+			e.event_type,
+			e.distance_miles,
+			to_char(e.start_time, 'YYYY-MM') AS month,
+			(SELECT MIN(rp.race_fee) FROM registration_periods rp WHERE rp.event_id = e.event_id) AS fee
+		FROM events e
+		JOIN matched m ON m.id = e.race_id
+		WHERE e.deleted_at IS NULL
+	),
+	distance_buckets AS (
+		SELECT * FROM unnest($%d::text[], $%d::float8[], $%d::float8[]) AS b(label, lo, hi)
+	),
+	price_buckets AS (
+		SELECT * FROM unnest($%d::text[], $%d::float8[], $%d::float8[]) AS b(label, lo, hi)
+	)
+	SELECT 'category', category, COUNT(DISTINCT race_id) FROM race_events GROUP BY category
+	UNION ALL
+	SELECT 'category', category, COUNT(*) FROM matched m
+	WHERE category <> '' AND NOT EXISTS (SELECT 1 FROM events e WHERE e.race_id = m.id AND e.deleted_at IS NULL)
+	GROUP BY category
+	UNION ALL
+	SELECT 'event_type', event_type, COUNT(DISTINCT race_id) FROM race_events
+	WHERE event_type <> '' GROUP BY event_type
+	UNION ALL
+	SELECT 'month', month, COUNT(DISTINCT race_id) FROM race_events
+	WHERE month IS NOT NULL GROUP BY month
+	UNION ALL
+	SELECT 'distance', b.label, COUNT(DISTINCT e.race_id) FROM race_events e
+	JOIN distance_buckets b ON e.distance_miles >= b.lo AND e.distance_miles < b.hi
+	GROUP BY b.label
+	UNION ALL
+	SELECT 'price', b.label, COUNT(DISTINCT e.race_id) FROM race_events e
+	JOIN price_buckets b ON e.fee >= b.lo AND e.fee < b.hi
+	GROUP BY b.label
+	UNION ALL
+	SELECT 'city', city, COUNT(*) FROM matched WHERE city <> '' GROUP BY city
+`
+
+func (s *SupabaseStorage) countFacets(matched string, args []interface{}) (*models.Facets, error) {
+	n := len(args)
+	for _, buckets := range [][]bucket{distanceBuckets, priceBuckets} {
+		labels := make([]string, len(buckets))
+		lows := make([]float64, len(buckets))
+		highs := make([]float64, len(buckets))
+		for i, b := range buckets {
+			labels[i], lows[i], highs[i] = b.label, b.min, b.max
+		}
+		args = append(args, pq.Array(labels), pq.Array(lows), pq.Array(highs))
+	}
+	sqlQuery := fmt.Sprintf(facetCounts, matched, constants.CategoryOther, n+1, n+2, n+3, n+4, n+5, n+6)
+
+	rows, err := s.db.Query(sqlQuery, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := map[string]map[string]int{}
+	for rows.Next() {
+		var (
+			facet, value string
+			count        int
+		)
+		if err := rows.Scan(&facet, &value, &count); err != nil {
+			return nil, err
+		}
+		if counts[facet] == nil {
+			counts[facet] = map[string]int{}
+		}
+		// Races with and without events can share a category
+		counts[facet][value] += count
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return facetsFrom(counts), nil
+}
+
+// withinRadius annotates races with their distance from center, drops those
+// further than radiusMiles and sorts the rest nearest first
+func withinRadius(races []models.Race, center geo.Point, radiusMiles float64) []models.Race {
+	nearby := make([]models.Race, 0, len(races))
+	for _, race := range races {
+		if race.Latitude == nil || race.Longitude == nil {
+			continue
+		}
+		distance := geo.DistanceMiles(center, geo.Point{Lat: *race.Latitude, Lng: *race.Longitude})
+		if distance > radiusMiles {
+			continue
+		}
+		distance = math.Round(distance*10) / 10
+		race.DistanceMiles = &distance
+		nearby = append(nearby, race)
+	}
+
+	sort.SliceStable(nearby, func(i, j int) bool {
+		return *nearby[i].DistanceMiles < *nearby[j].DistanceMiles
+	})
+	return nearby
+}
+
+// paginate applies limit and offset to an in-memory result set
+func paginate(races []models.Race, limit, offset int) []models.Race {
+	if offset >= len(races) {
+		return []models.Race{}
+	}
+	races = races[offset:]
+	if limit > 0 && limit < len(races) {
+		races = races[:limit]
+	}
+	return races
+}
+
+// scanRace reads a row selected with raceColumns followed by any extra
+// columns, which are scanned into extra
+func scanRace(rows *sql.Rows, extra ...interface{}) (models.Race, error) {
+	var (
+		race                                       models.Race
+		name, url, externalURL, logoURL, timezone  sql.NullString
+		category, address, city, state, postalCode sql.NullString
+		latitude, longitude                        sql.NullFloat64
+		nextDate, lastSeen, deletedAt              sql.NullTime
+	)
+	dest := []interface{}{&race.ID, &name, &url, &externalURL, &logoURL, &timezone, &category,
+		&address, &city, &state, &postalCode, &latitude, &longitude, &nextDate,
+		&lastSeen, &race.PossiblyRemoved, &deletedAt}
+	err := rows.Scan(append(dest, extra...)...)
+	if err != nil {
+		return race, err
+	}
+
+	race.Name = name.String
+	race.URL = url.String
+	race.ExternalURL = externalURL.String
+	race.LogoURL = logoURL.String
+	race.Timezone = timezone.String
+	race.Category = category.String
+	race.Address = address.String
+	race.City = city.String
+	race.State = state.String
+	race.PostalCode = postalCode.String
+	race.Latitude = floatPtr(latitude)
+	race.Longitude = floatPtr(longitude)
+	race.NextDate = formatNullDate(nextDate)
+	race.LastSeenAt = formatNullTime(lastSeen)
+	race.RemovedAt = formatNullTime(deletedAt)
+	return race, nil
+}
+
+// floatPtr returns nil for a NULL coordinate
+func floatPtr(f sql.NullFloat64) *float64 {
+	if !f.Valid {
+		return nil
+	}
+	return &f.Float64
+}
+
+// formatNullDate renders a nullable date as YYYY-MM-DD, or "" when NULL
+func formatNullDate(t sql.NullTime) string {
+	if !t.Valid {
+		return ""
+	}
+	return t.Time.Format("2006-01-02")
+}
+
+// MarkRacesSeen records that a sync of state returned the given races,
+// restoring any of them that had been soft-deleted
+func (s *SupabaseStorage) MarkRacesSeen(state string, raceIDs []int) error {
+	_, err := s.db.Exec(`
+		UPDATE races SET
+			state = COALESCE(state, $1),
+			last_seen_at = NOW(),
+			missed_syncs = 0,
+			possibly_removed = FALSE,
+			deleted_at = NULL
+		WHERE id = ANY($2)
+	`, state, pq.Array(int64s(raceIDs)))
+	s.invalidateSearches()
+	return err
+}
+
+// FlagUnseenRaces counts a missed sync against every live, upcoming race in
+// state that was not returned, and flags races that reached threshold misses
+// as possibly removed. It returns the IDs of all flagged races.
+func (s *SupabaseStorage) FlagUnseenRaces(state string, seenIDs []int, threshold int) ([]int, error) {
+	rows, err := s.db.Query(`
+		UPDATE races SET
+			missed_syncs = missed_syncs + 1,
+			possibly_removed = missed_syncs + 1 >= $3
+		WHERE state = $1
+		  AND deleted_at IS NULL
+		  AND NOT (id = ANY($2))
+		  AND EXISTS (SELECT 1 FROM events e WHERE e.race_id = races.id AND e.deleted_at IS NULL AND e.start_time >= NOW())
+		RETURNING id, possibly_removed
+	`, state, pq.Array(int64s(seenIDs)), threshold)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var flagged []int
+	for rows.Next() {
+		var (
+			id              int
+			possiblyRemoved bool
+		)
+		if err := rows.Scan(&id, &possiblyRemoved); err != nil {
+			return nil, err
+		}
+		if possiblyRemoved {
+			flagged = append(flagged, id)
+		}
+	}
+
+	if len(flagged) > 0 {
+		s.invalidateSearches()
+	}
+	return flagged, rows.Err()
+}
+
+// SoftDeleteRace hides a race from the API without dropping its history
+func (s *SupabaseStorage) SoftDeleteRace(raceID int) error {
+	_, err := s.db.Exec(`UPDATE races SET deleted_at = NOW() WHERE id = $1 AND deleted_at IS NULL`, raceID)
+	s.invalidateSearches()
+	return err
+}
+
+// int64s converts race IDs for use with pq.Array
+func int64s(ids []int) []int64 {
+	out := make([]int64, len(ids))
+	for i, id := range ids {
+		out[i] = int64(id)
+	}
+	return out
+}
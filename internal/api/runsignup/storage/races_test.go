@@ -0,0 +1,45 @@
+package storage
+
+import (
+	"testing"
+
+	"github.com/rbungay/racedatabase-api/internal/api/runsignup/models"
+	"github.com/rbungay/racedatabase-api/pkg/geo"
+)
+
+func raceAt(id int, lat, lng float64) models.Race {
+	return models.Race{ID: id, Location: models.Location{Latitude: &lat, Longitude: &lng}}
+}
+
+func TestWithinRadius(t *testing.T) {
+	jerseyCity := geo.Point{Lat: 40.7178, Lng: -74.0466}
+	races := []models.Race{
+		raceAt(1, 39.9524, -75.1742), // Philadelphia, ~80 miles
+		raceAt(2, 40.7357, -74.1724), // Newark, ~7 miles
+		raceAt(3, 40.7454, -74.0279), // Hoboken, ~2 miles
+		{ID: 4},                      // not geocoded
+	}
+
+	nearby := withinRadius(races, jerseyCity, 25)
+
+	if len(nearby) != 2 {
+		t.Fatalf("Expected 2 races within 25 miles, got %d", len(nearby))
+	}
+	if nearby[0].ID != 3 || nearby[1].ID != 2 {
+		t.Errorf("Expected nearest first, got IDs %d, %d", nearby[0].ID, nearby[1].ID)
+	}
+	if nearby[0].DistanceMiles == nil || *nearby[0].DistanceMiles > 3 {
+		t.Errorf("Unexpected distance for Hoboken: %v", nearby[0].DistanceMiles)
+	}
+}
+
+func TestPaginate(t *testing.T) {
+	races := []models.Race{{ID: 1}, {ID: 2}, {ID: 3}}
+
+	if page := paginate(races, 2, 1); len(page) != 2 || page[0].ID != 2 {
+		t.Errorf("Unexpected page: %+v", page)
+	}
+	if page := paginate(races, 2, 5); len(page) != 0 {
+		t.Errorf("Expected empty page past the end, got %+v", page)
+	}
+}
@@ -0,0 +1,252 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+
+	"github.com/lib/pq"
+	"github.com/rbungay/racedatabase-api/internal/api/runsignup/models"
+)
+
+// The staging tables SaveRaces copies a batch into. They live until the
+// transaction ends.
+const createStagingTables = `
+	CREATE TEMP TABLE staged_races (
+		id BIGINT, name TEXT, url TEXT, external_url TEXT, logo_url TEXT, timezone TEXT, category TEXT,
+		address TEXT, city TEXT, state TEXT, postal_code TEXT,
+		latitude DOUBLE PRECISION, longitude DOUBLE PRECISION, next_date TIMESTAMPTZ
+	) ON COMMIT DROP;
+	CREATE TEMP TABLE staged_events (
+		event_id BIGINT, race_id BIGINT, name TEXT, start_time TIMESTAMPTZ, end_time TIMESTAMPTZ,
+		event_type TEXT, distance TEXT, distance_miles DOUBLE PRECISION, registration_opens TIMESTAMPTZ, category TEXT
+	) ON COMMIT DROP;
+	CREATE TEMP TABLE staged_periods (
+		event_id BIGINT, opens_at TIMESTAMPTZ, closes_at TIMESTAMPTZ,
+		race_fee NUMERIC(10, 2), processing_fee NUMERIC(10, 2)
+	) ON COMMIT DROP;
+	CREATE TEMP TABLE staged_removed_events (event_id BIGINT) ON COMMIT DROP;
+`
+
+// mergeStagedRaces writes the staged batch with one statement per table,
+// doing for every race what SaveRace does for one
+const mergeStagedRaces = `
+	INSERT INTO races (id, name, url, external_url, logo_url, timezone, category,
+	                   address, city, state, postal_code, latitude, longitude, next_date)
+	SELECT id, name, url, external_url, logo_url, timezone, category,
+	       address, city, NULLIF(state, ''), postal_code, latitude, longitude, next_date
+	FROM staged_races
+	ON CONFLICT (id) DO UPDATE SET
+		name = EXCLUDED.name,
+		url = EXCLUDED.url,
+		external_url = EXCLUDED.external_url,
+		logo_url = EXCLUDED.logo_url,
+		timezone = EXCLUDED.timezone,
+		category = EXCLUDED.category,
+		address = EXCLUDED.address,
+		city = EXCLUDED.city,
+		state = COALESCE(EXCLUDED.state, races.state),
+		postal_code = EXCLUDED.postal_code,
+		latitude = EXCLUDED.latitude,
+		longitude = EXCLUDED.longitude,
+		next_date = EXCLUDED.next_date,
+		updated_at = NOW();
+
+	INSERT INTO events (event_id, race_id, name, start_time, end_time, event_type, distance, distance_miles,
+	                    registration_opens, category)
+	SELECT event_id, race_id, name, start_time, end_time, event_type, distance, distance_miles,
+	       registration_opens, category
+	FROM staged_events
+	ON CONFLICT (event_id) DO UPDATE SET
+		name = EXCLUDED.name,
+		start_time = EXCLUDED.start_time,
+		end_time = EXCLUDED.end_time,
+		event_type = EXCLUDED.event_type,
+		distance = EXCLUDED.distance,
+		distance_miles = EXCLUDED.distance_miles,
+		registration_opens = EXCLUDED.registration_opens,
+		category = EXCLUDED.category,
+		deleted_at = NULL,
+		updated_at = NOW();
+
+	DELETE FROM registration_periods
+	WHERE event_id IN (SELECT event_id FROM staged_events UNION ALL SELECT event_id FROM staged_removed_events);
+
+	UPDATE events SET deleted_at = NOW(), updated_at = NOW()
+	WHERE event_id IN (SELECT event_id FROM staged_removed_events);
+
+	INSERT INTO registration_periods (event_id, opens_at, closes_at, race_fee, processing_fee)
+	SELECT event_id, opens_at, closes_at, race_fee, processing_fee
+	FROM staged_periods;
+
+	INSERT INTO price_history (event_id, opens_at, closes_at, race_fee, processing_fee)
+	SELECT DISTINCT sp.event_id, sp.opens_at, sp.closes_at, sp.race_fee, sp.processing_fee
+	FROM staged_periods sp
+	WHERE NOT EXISTS (
+		SELECT 1 FROM (
+			SELECT race_fee, processing_fee FROM price_history ph
+			WHERE ph.event_id = sp.event_id
+			  AND ph.opens_at IS NOT DISTINCT FROM sp.opens_at
+			  AND ph.closes_at IS NOT DISTINCT FROM sp.closes_at
+			ORDER BY recorded_at DESC, id DESC
+			LIMIT 1
+		) latest
+		WHERE latest.race_fee = sp.race_fee AND latest.processing_fee = sp.processing_fee
+	);
+
+	UPDATE races SET search_vector =
+		setweight(to_tsvector('english', coalesce(name, '')), 'A') ||
+		setweight(to_tsvector('english', coalesce(
+			(SELECT string_agg(e.name, ' ') FROM events e WHERE e.race_id = races.id AND e.deleted_at IS NULL), '')), 'B') ||
+		setweight(to_tsvector('english', coalesce(city, '')), 'C')
+	WHERE id IN (SELECT id FROM staged_races);
+`
+
+// SaveRaces stores a batch of races in one transaction with the same effect
+// as calling SaveRace for each. The batch is copied into staging tables and
+// merged from there, so the number of round trips to the database stays the
+// same however many races, events and registration periods it holds. When a
+// race appears more than once the last version wins.
+func (s *SupabaseStorage) SaveRaces(races []*models.RaceDetails) error {
+	races = latestVersions(races)
+	if len(races) == 0 {
+		return nil
+	}
+
+	tx, err := s.db.BeginTx(context.Background(), nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	raceIDs := make([]int64, len(races))
+	for i, race := range races {
+		raceIDs[i] = int64(race.ID)
+	}
+	// Load the stored versions so we can record what changed
+	previous, err := loadRaces(tx, raceIDs)
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(createStagingTables); err != nil {
+		return err
+	}
+
+	var (
+		raceRows, eventRows, periodRows, removedRows, changeRows [][]any
+		staged                                                   = make(map[int]bool)
+	)
+	for _, race := range races {
+		raceRows = append(raceRows, []any{
+			race.ID, race.Name, race.URL, race.ExternalURL, race.LogoURL, race.Timezone, race.Category,
+			race.Address, race.City, race.State, race.PostalCode,
+			nullFloat(race.Latitude), nullFloat(race.Longitude), nullTime(race.NextDate),
+		})
+
+		for _, event := range race.Events {
+			// An event listed twice would make the upsert touch its row twice
+			if staged[event.EventID] {
+				continue
+			}
+			staged[event.EventID] = true
+
+			eventRows = append(eventRows, []any{
+				event.EventID, race.ID, event.Name,
+				nullTime(event.StartTime), nullTime(event.EndTime),
+				event.EventType, event.Distance, eventDistanceMiles(event.Distance),
+				nullTime(event.RegOpens), event.Category,
+			})
+			for _, period := range event.RegPeriods {
+				periodRows = append(periodRows, []any{
+					event.EventID,
+					nullTime(period.Opens), nullTime(period.Closes),
+					cleanFeeString(period.Fee), cleanFeeString(period.ProcFee),
+				})
+			}
+		}
+
+		if stored := previous[race.ID]; stored != nil {
+			diff := diffRaces(stored, race)
+			for _, removed := range diff.RemovedEvents {
+				removedRows = append(removedRows, []any{removed.EventID})
+			}
+			if !diff.Empty() {
+				changes, err := json.Marshal(diff)
+				if err != nil {
+					return err
+				}
+				// COPY would encode []byte as bytea rather than JSON
+				changeRows = append(changeRows, []any{race.ID, string(changes)})
+			}
+		}
+	}
+
+	copies := []struct {
+		table   string
+		columns []string
+		rows    [][]any
+	}{
+		{"staged_races", []string{"id", "name", "url", "external_url", "logo_url", "timezone", "category",
+			"address", "city", "state", "postal_code", "latitude", "longitude", "next_date"}, raceRows},
+		{"staged_events", []string{"event_id", "race_id", "name", "start_time", "end_time",
+			"event_type", "distance", "distance_miles", "registration_opens", "category"}, eventRows},
+		{"staged_periods", []string{"event_id", "opens_at", "closes_at", "race_fee", "processing_fee"}, periodRows},
+		{"staged_removed_events", []string{"event_id"}, removedRows},
+		{"race_changes", []string{"race_id", "changes"}, changeRows},
+	}
+	for _, c := range copies {
+		if err := copyRows(tx, c.table, c.columns, c.rows); err != nil {
+			return err
+		}
+	}
+
+	if _, err := tx.Exec(mergeStagedRaces); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	s.invalidateSearches()
+	return nil
+}
+
+// copyRows streams rows into table with COPY
+func copyRows(tx *sql.Tx, table string, columns []string, rows [][]any) error {
+	if len(rows) == 0 {
+		return nil
+	}
+
+	stmt, err := tx.Prepare(pq.CopyIn(table, columns...))
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for _, row := range rows {
+		if _, err := stmt.Exec(row...); err != nil {
+			return err
+		}
+	}
+	// An Exec without arguments flushes the copy
+	_, err = stmt.Exec()
+	return err
+}
+
+// latestVersions drops all but the last version of each race in a batch,
+// keeping the batch's order otherwise
+func latestVersions(races []*models.RaceDetails) []*models.RaceDetails {
+	last := make(map[int]int, len(races))
+	for i, race := range races {
+		last[race.ID] = i
+	}
+
+	latest := make([]*models.RaceDetails, 0, len(last))
+	for i, race := range races {
+		if last[race.ID] == i {
+			latest = append(latest, race)
+		}
+	}
+	return latest
+}
@@ -0,0 +1,552 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/rbungay/racedatabase-api/internal/api/runsignup/models"
+)
+
+// roundTripDriver stands in for Postgres across a network. Every statement
+// costs one round trip of latency and returns no rows. Like lib/pq, rows
+// sent during a COPY are buffered and only cost a trip when it is flushed.
+type roundTripDriver struct {
+	latency time.Duration
+	trips   atomic.Int64
+
+	// rows answers queries that need stored data; nil returns none
+	rows func(query string) [][]driver.Value
+
+	mu    sync.Mutex
+	execs []string
+}
+
+// record keeps a statement sent with Exec or a COPY row, with its arguments
+func (d *roundTripDriver) record(query string, args []driver.Value) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.execs = append(d.execs, fmt.Sprintf("%s %v", strings.Join(strings.Fields(query), " "), args))
+}
+
+func (d *roundTripDriver) query(query string) driver.Rows {
+	if d.rows == nil {
+		return noRows{}
+	}
+	return &fakeRows{values: d.rows(query)}
+}
+
+func (d *roundTripDriver) trip() {
+	d.trips.Add(1)
+	if d.latency > 0 {
+		time.Sleep(d.latency)
+	}
+}
+
+func (d *roundTripDriver) Connect(context.Context) (driver.Conn, error) { return roundTripConn{d}, nil }
+func (d *roundTripDriver) Driver() driver.Driver                        { return d }
+func (d *roundTripDriver) Open(string) (driver.Conn, error)             { return roundTripConn{d}, nil }
+
+type roundTripConn struct{ d *roundTripDriver }
+
+func (c roundTripConn) Prepare(query string) (driver.Stmt, error) {
+	c.d.trip()
+	return roundTripStmt{d: c.d, query: query, copy: strings.HasPrefix(strings.TrimSpace(query), "COPY")}, nil
+}
+
+func (c roundTripConn) Close() error { return nil }
+
+func (c roundTripConn) Begin() (driver.Tx, error) {
+	c.d.trip()
+	return roundTripTx{c.d}, nil
+}
+
+func (c roundTripConn) ExecContext(_ context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	c.d.trip()
+	values := make([]driver.Value, len(args))
+	for i, arg := range args {
+		values[i] = arg.Value
+	}
+	c.d.record(query, values)
+	return driver.RowsAffected(0), nil
+}
+
+func (c roundTripConn) QueryContext(_ context.Context, query string, _ []driver.NamedValue) (driver.Rows, error) {
+	c.d.trip()
+	return c.d.query(query), nil
+}
+
+type roundTripTx struct{ d *roundTripDriver }
+
+func (tx roundTripTx) Commit() error   { tx.d.trip(); return nil }
+func (tx roundTripTx) Rollback() error { tx.d.trip(); return nil }
+
+type roundTripStmt struct {
+	d     *roundTripDriver
+	query string
+	copy  bool
+}
+
+func (s roundTripStmt) Close() error  { return nil }
+func (s roundTripStmt) NumInput() int { return -1 }
+
+func (s roundTripStmt) Exec(args []driver.Value) (driver.Result, error) {
+	if !s.copy || len(args) == 0 {
+		s.d.trip()
+	}
+	s.d.record(s.query, args)
+	return driver.RowsAffected(0), nil
+}
+
+func (s roundTripStmt) Query([]driver.Value) (driver.Rows, error) {
+	s.d.trip()
+	return s.d.query(s.query), nil
+}
+
+type noRows struct{}
+
+func (noRows) Columns() []string         { return nil }
+func (noRows) Close() error              { return nil }
+func (noRows) Next([]driver.Value) error { return io.EOF }
+
+// fakeRows returns fixed rows, all with the same number of columns
+type fakeRows struct{ values [][]driver.Value }
+
+func (r *fakeRows) Columns() []string {
+	if len(r.values) == 0 {
+		return nil
+	}
+	return make([]string, len(r.values[0]))
+}
+
+func (r *fakeRows) Close() error { return nil }
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.values) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.values[0])
+	r.values = r.values[1:]
+	return nil
+}
+
+func newRoundTripStorage(latency time.Duration) (*SupabaseStorage, *roundTripDriver) {
+	d := &roundTripDriver{latency: latency}
+	return &SupabaseStorage{db: sql.OpenDB(d)}, d
+}
+
+// raceBatch builds n races of three events with two registration periods each
+func raceBatch(n int) []*models.RaceDetails {
+	races := make([]*models.RaceDetails, n)
+	for i := range races {
+		race := &models.RaceDetails{
+			ID:       1000 + i,
+			Name:     fmt.Sprintf("Race %d", i),
+			NextDate: "2026-11-01",
+			Location: models.Location{City: "Newark", State: "NJ"},
+		}
+		for e := 0; e < 3; e++ {
+			race.Events = append(race.Events, models.EventDetails{
+				EventID:   (1000+i)*10 + e,
+				Name:      fmt.Sprintf("Event %d", e),
+				StartTime: "11/1/2026 08:00",
+				RegPeriods: []models.RegistrationPeriod{
+					{Opens: "1/1/2026 00:00", Closes: "6/1/2026 23:59", Fee: "$30.00", ProcFee: "$3.00"},
+					{Opens: "6/2/2026 00:00", Closes: "10/31/2026 23:59", Fee: "$40.00", ProcFee: "$4.00"},
+				},
+			})
+		}
+		races[i] = race
+	}
+	return races
+}
+
+func TestSaveRaces_RoundTripsDoNotGrowWithBatch(t *testing.T) {
+	trips := func(n int) int64 {
+		s, d := newRoundTripStorage(0)
+		if err := s.SaveRaces(raceBatch(n)); err != nil {
+			t.Fatalf("SaveRaces(%d races) failed: %v", n, err)
+		}
+		return d.trips.Load()
+	}
+
+	small, large := trips(1), trips(200)
+	if small != large {
+		t.Errorf("Expected the same number of round trips for 1 and 200 races, got %d and %d", small, large)
+	}
+}
+
+func TestSaveRaces_FewerRoundTripsThanSaveRace(t *testing.T) {
+	races := raceBatch(20)
+
+	single, singleDriver := newRoundTripStorage(0)
+	for _, race := range races {
+		if err := single.SaveRace(race); err != nil {
+			t.Fatalf("SaveRace failed: %v", err)
+		}
+	}
+	batch, batchDriver := newRoundTripStorage(0)
+	if err := batch.SaveRaces(races); err != nil {
+		t.Fatalf("SaveRaces failed: %v", err)
+	}
+
+	if got, limit := batchDriver.trips.Load(), singleDriver.trips.Load()/10; got > limit {
+		t.Errorf("Expected SaveRaces to take at most %d round trips, took %d", limit, got)
+	}
+}
+
+func TestSaveRaces_EmptyBatch(t *testing.T) {
+	s, d := newRoundTripStorage(0)
+	if err := s.SaveRaces(nil); err != nil {
+		t.Fatalf("SaveRaces failed: %v", err)
+	}
+	if d.trips.Load() != 0 {
+		t.Errorf("Expected an empty batch not to touch the database, got %d round trips", d.trips.Load())
+	}
+}
+
+// storedRaceRows answers the queries loadRaces makes as if race were already
+// stored, without registration periods
+func storedRaceRows(race *models.RaceDetails) func(query string) [][]driver.Value {
+	return func(query string) [][]driver.Value {
+		switch {
+		case strings.Contains(query, "FROM races WHERE id = ANY"):
+			return [][]driver.Value{{int64(race.ID), race.Name, nil, nil, nil, nil, nil, race.Location.City, race.Location.State, nil, nil}}
+		case strings.Contains(query, "FROM events WHERE race_id = ANY"):
+			var rows [][]driver.Value
+			for _, event := range race.Events {
+				rows = append(rows, []driver.Value{int64(race.ID), int64(event.EventID), event.Name, nil, nil, nil, nil, nil, nil})
+			}
+			return rows
+		}
+		return nil
+	}
+}
+
+// TestSaveRaces_SoftDeletesRemovedEvents checks both save paths mark an event
+// dropped upstream as deleted instead of deleting it, which would take its
+// price history with it
+func TestSaveRaces_SoftDeletesRemovedEvents(t *testing.T) {
+	stored := raceBatch(1)[0]
+	synced := raceBatch(1)[0]
+	synced.Events = synced.Events[:2]
+	removed := stored.Events[2].EventID
+
+	tests := []struct {
+		name string
+		save func(s *SupabaseStorage) error
+		// marked is the statement that flags the removed event as deleted
+		marked string
+	}{
+		{"SaveRace", func(s *SupabaseStorage) error { return s.SaveRace(synced) },
+			fmt.Sprintf("UPDATE events SET deleted_at = NOW(), updated_at = NOW() WHERE event_id = $1 [%d]", removed)},
+		{"SaveRaces", func(s *SupabaseStorage) error { return s.SaveRaces([]*models.RaceDetails{synced}) },
+			fmt.Sprintf(`COPY "staged_removed_events" ("event_id") FROM STDIN [%d]`, removed)},
+	}
+	for _, tt := range tests {
+		s, d := newRoundTripStorage(0)
+		d.rows = storedRaceRows(stored)
+		if err := tt.save(s); err != nil {
+			t.Fatalf("%s failed: %v", tt.name, err)
+		}
+
+		var marked, softDeleted bool
+		for _, statement := range d.execs {
+			marked = marked || statement == tt.marked
+			softDeleted = softDeleted || strings.Contains(statement, "UPDATE events SET deleted_at = NOW()")
+			if strings.Contains(statement, "DELETE FROM events") || strings.Contains(statement, "DELETE FROM price_history") {
+				t.Errorf("%s deleted stored rows: %s", tt.name, statement)
+			}
+		}
+		if !marked || !softDeleted {
+			t.Errorf("%s did not soft-delete event %d, sent:\n%s", tt.name, removed, strings.Join(d.execs, "\n"))
+		}
+	}
+}
+
+func TestLatestVersions(t *testing.T) {
+	races := []*models.RaceDetails{{ID: 1, Name: "old"}, {ID: 2}, {ID: 1, Name: "new"}}
+
+	latest := latestVersions(races)
+	if len(latest) != 2 {
+		t.Fatalf("Expected 2 races, got %d", len(latest))
+	}
+	if latest[0].ID != 2 || latest[1].Name != "new" {
+		t.Errorf("Expected race 2 then the last version of race 1, got %+v and %+v", latest[0], latest[1])
+	}
+}
+
+// baseSchema is the part of the schema created in Supabase before the
+// migrations in supabase/migrations, which alter it
+const baseSchema = `
+	CREATE TABLE races (
+		id BIGINT PRIMARY KEY,
+		name TEXT NOT NULL,
+		url TEXT,
+		external_url TEXT,
+		logo_url TEXT,
+		timezone TEXT,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+		updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+	);
+	CREATE TABLE events (
+		event_id BIGINT PRIMARY KEY,
+		race_id BIGINT NOT NULL REFERENCES races (id) ON DELETE CASCADE,
+		name TEXT,
+		start_time TIMESTAMPTZ,
+		end_time TIMESTAMPTZ,
+		event_type TEXT,
+		distance TEXT,
+		registration_opens TIMESTAMPTZ,
+		category TEXT,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+		updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+	);
+	CREATE TABLE registration_periods (
+		id BIGSERIAL PRIMARY KEY,
+		event_id BIGINT NOT NULL REFERENCES events (event_id) ON DELETE CASCADE,
+		opens_at TIMESTAMPTZ,
+		closes_at TIMESTAMPTZ,
+		race_fee NUMERIC(10, 2) NOT NULL,
+		processing_fee NUMERIC(10, 2) NOT NULL
+	);
+`
+
+// postgresStorage gives a test its own schema in the database at
+// TEST_SUPABASE_DB_URL, with the base tables and every migration applied.
+// The test is skipped when the variable is not set.
+func postgresStorage(t *testing.T, schema string) *SupabaseStorage {
+	t.Helper()
+	dbURL := os.Getenv("TEST_SUPABASE_DB_URL")
+	if dbURL == "" {
+		t.Skip("TEST_SUPABASE_DB_URL is not set")
+	}
+
+	admin, err := sql.Open("postgres", dbURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := admin.Exec(fmt.Sprintf(`DROP SCHEMA IF EXISTS %[1]s CASCADE; CREATE SCHEMA %[1]s`, schema)); err != nil {
+		admin.Close()
+		t.Fatalf("Creating schema %s failed: %v", schema, err)
+	}
+	t.Cleanup(func() {
+		admin.Exec(fmt.Sprintf(`DROP SCHEMA IF EXISTS %s CASCADE`, schema))
+		admin.Close()
+	})
+
+	// Every connection in the pool has to see the schema, so it goes in the
+	// connection's parameters rather than a SET
+	if u, err := url.Parse(dbURL); err == nil && (u.Scheme == "postgres" || u.Scheme == "postgresql") {
+		q := u.Query()
+		q.Set("search_path", schema)
+		u.RawQuery = q.Encode()
+		dbURL = u.String()
+	} else {
+		dbURL += " search_path=" + schema
+	}
+	s, err := NewSupabaseStorage(dbURL, PoolConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+
+	migrations, err := filepath.Glob("../../../../supabase/migrations/*.sql")
+	if err != nil || len(migrations) == 0 {
+		t.Fatalf("No migrations found: %v", err)
+	}
+	if _, err := s.db.Exec(baseSchema); err != nil {
+		t.Fatalf("Creating the base schema failed: %v", err)
+	}
+	for _, migration := range migrations {
+		statements, err := os.ReadFile(migration)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := s.db.Exec(string(statements)); err != nil {
+			t.Fatalf("Applying %s failed: %v", filepath.Base(migration), err)
+		}
+	}
+	return s
+}
+
+// storedTables reads back everything SaveRace and SaveRaces write, leaving
+// out generated ids and write times
+var storedTables = map[string]string{
+	"races": `SELECT id, name, url, external_url, logo_url, timezone, category, address, city, state,
+		postal_code, latitude, longitude, next_date, search_vector::text FROM races ORDER BY id`,
+	"events": `SELECT event_id, race_id, name, start_time, end_time, event_type, distance, distance_miles,
+		registration_opens, category, deleted_at IS NOT NULL FROM events ORDER BY event_id`,
+	"registration_periods": `SELECT event_id, opens_at, closes_at, race_fee, processing_fee
+		FROM registration_periods ORDER BY event_id, opens_at, race_fee`,
+	"price_history": `SELECT event_id, opens_at, closes_at, race_fee, processing_fee
+		FROM price_history ORDER BY event_id, opens_at, id`,
+	"race_changes": `SELECT race_id, changes::text FROM race_changes ORDER BY race_id, id`,
+}
+
+func dumpTable(t *testing.T, s *SupabaseStorage, query string) []string {
+	t.Helper()
+	rows, err := s.db.Query(query)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var dump []string
+	for rows.Next() {
+		values := make([]any, len(columns))
+		dest := make([]any, len(columns))
+		for i := range values {
+			dest[i] = &values[i]
+		}
+		if err := rows.Scan(dest...); err != nil {
+			t.Fatal(err)
+		}
+		for i, v := range values {
+			if b, ok := v.([]byte); ok {
+				values[i] = string(b)
+			}
+		}
+		dump = append(dump, fmt.Sprintf("%v", values))
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return dump
+}
+
+// syncRounds are successive syncs of two races: the first stores them, the
+// second renames race 1, changes a fee, drops one event and adds another
+// while race 2 is unchanged, and the third brings the dropped event back
+func syncRounds() [][]*models.RaceDetails {
+	lat, lng := 40.73, -74.17
+	race := func(name string, eventIDs ...int) *models.RaceDetails {
+		r := &models.RaceDetails{
+			ID:       1,
+			Name:     name,
+			URL:      "https://runsignup.com/Race/NJ/Newark/TestRace",
+			NextDate: "2026-11-01",
+			Location: models.Location{Address: "1 Main St", City: "Newark", State: "NJ", PostalCode: "07102", Latitude: &lat, Longitude: &lng},
+		}
+		for _, id := range eventIDs {
+			r.Events = append(r.Events, models.EventDetails{
+				EventID:   id,
+				Name:      fmt.Sprintf("Event %d", id),
+				StartTime: "11/1/2026 08:00",
+				EventType: "running_race",
+				Distance:  "5K",
+				RegPeriods: []models.RegistrationPeriod{
+					{Opens: "1/1/2026 00:00", Closes: "6/1/2026 23:59", Fee: "$30.00", ProcFee: "$3.00"},
+					{Opens: "6/2/2026 00:00", Closes: "10/31/2026 23:59", Fee: "$40.00", ProcFee: "$4.00"},
+				},
+			})
+		}
+		return r
+	}
+	other := func() *models.RaceDetails {
+		r := race("Other Race", 20)
+		r.ID = 2
+		r.Location.City = "Trenton"
+		// The same period listed twice is recorded once
+		r.Events[0].RegPeriods = append(r.Events[0].RegPeriods, r.Events[0].RegPeriods[0])
+		return r
+	}
+
+	renamed := race("Renamed Race", 10, 12)
+	renamed.Events[0].RegPeriods[1].Fee = "$45.00"
+	return [][]*models.RaceDetails{
+		{race("Test Race", 10, 11), other()},
+		{renamed, other()},
+		{race("Renamed Race", 10, 11, 12), other()},
+	}
+}
+
+// TestSaveRaces_MatchesSaveRace saves the same syncs one race at a time with
+// SaveRace and as batches with SaveRaces, and checks both leave the same
+// rows behind
+func TestSaveRaces_MatchesSaveRace(t *testing.T) {
+	single := postgresStorage(t, "save_race_test")
+	batch := postgresStorage(t, "save_races_test")
+
+	for round, races := range syncRounds() {
+		for _, race := range races {
+			if err := single.SaveRace(race); err != nil {
+				t.Fatalf("Round %d: SaveRace failed: %v", round, err)
+			}
+		}
+		if err := batch.SaveRaces(races); err != nil {
+			t.Fatalf("Round %d: SaveRaces failed: %v", round, err)
+		}
+
+		for table, query := range storedTables {
+			want, got := dumpTable(t, single, query), dumpTable(t, batch, query)
+			if strings.Join(got, "\n") != strings.Join(want, "\n") {
+				t.Errorf("Round %d: %s differs.\nSaveRace:\n%s\nSaveRaces:\n%s",
+					round, table, strings.Join(want, "\n"), strings.Join(got, "\n"))
+			}
+		}
+
+		if round == 1 {
+			var deleted bool
+			var prices int
+			err := batch.db.QueryRow(`
+				SELECT deleted_at IS NOT NULL, (SELECT COUNT(*) FROM price_history WHERE event_id = 11)
+				FROM events WHERE event_id = 11
+			`).Scan(&deleted, &prices)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !deleted || prices != 2 {
+				t.Errorf("Expected the dropped event to be soft-deleted with its 2 prices kept, got deleted %v with %d prices", deleted, prices)
+			}
+		}
+	}
+}
+
+// The benchmarks save 100 races over a simulated 500µs round trip, about
+// what a sync sees against a hosted database in the same region:
+//
+//	go test ./internal/api/runsignup/storage -run ^$ -bench SaveRace
+const benchLatency = 500 * time.Microsecond
+
+func BenchmarkSaveRace(b *testing.B) {
+	races := raceBatch(100)
+	s, d := newRoundTripStorage(benchLatency)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, race := range races {
+			if err := s.SaveRace(race); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+	b.ReportMetric(float64(d.trips.Load())/float64(b.N), "trips/op")
+	b.ReportMetric(float64(len(races)*b.N)/b.Elapsed().Seconds(), "races/s")
+}
+
+func BenchmarkSaveRaces(b *testing.B) {
+	races := raceBatch(100)
+	s, d := newRoundTripStorage(benchLatency)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := s.SaveRaces(races); err != nil {
+			b.Fatal(err)
+		}
+	}
+	b.ReportMetric(float64(d.trips.Load())/float64(b.N), "trips/op")
+	b.ReportMetric(float64(len(races)*b.N)/b.Elapsed().Seconds(), "races/s")
+}
@@ -0,0 +1,97 @@
+package storage
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"strconv"
+
+	"github.com/rbungay/racedatabase-api/internal/api/runsignup/models"
+	"github.com/rbungay/racedatabase-api/pkg/cache"
+)
+
+// searchGenerationKey holds a counter included in every search cache key.
+// Incrementing it retires all cached searches at once, on every replica
+// sharing the backend. Counters never expire and are not evicted, so an old
+// generation never comes back.
+const searchGenerationKey = "races:search:generation"
+
+// SearchCache caches stored race searches and facets. Any change to stored
+// races invalidates all of them, since a single race can appear in any
+// number of searches.
+type SearchCache struct {
+	cache *cache.Cache
+}
+
+// NewSearchCache creates a search cache storing results in c
+func NewSearchCache(c *cache.Cache) *SearchCache {
+	return &SearchCache{cache: c}
+}
+
+// Invalidate retires every cached search
+func (sc *SearchCache) Invalidate() {
+	if _, err := sc.cache.Backend().Incr(searchGenerationKey); err != nil {
+		log.Printf("cache: incr %s: %v", searchGenerationKey, err)
+	}
+}
+
+// key identifies a query within the current generation. ok is false when
+// the generation cannot be read, in which case the search is not cached,
+// since results cached under an older generation could be served.
+func (sc *SearchCache) key(kind string, query models.RaceQuery) (key string, ok bool) {
+	generation, err := sc.cache.Backend().Counter(searchGenerationKey)
+	if err != nil {
+		log.Printf("cache: read %s: %v", searchGenerationKey, err)
+		return "", false
+	}
+	encoded, _ := json.Marshal(query)
+	sum := sha256.Sum256(encoded)
+	return "races:" + kind + ":" + strconv.FormatInt(generation, 10) + ":" + hex.EncodeToString(sum[:16]), true
+}
+
+func (sc *SearchCache) races(query models.RaceQuery, search func(models.RaceQuery) ([]models.Race, error)) ([]models.Race, error) {
+	key, ok := sc.key("search", query)
+	if !ok {
+		return search(query)
+	}
+	value, err := sc.cache.Fetch(key, func() ([]byte, error) {
+		races, err := search(query)
+		if err != nil {
+			return nil, err
+		}
+		return json.Marshal(races)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var races []models.Race
+	if err := json.Unmarshal(value, &races); err != nil {
+		return search(query)
+	}
+	return races, nil
+}
+
+func (sc *SearchCache) facets(query models.RaceQuery, count func(models.RaceQuery) (*models.Facets, error)) (*models.Facets, error) {
+	key, ok := sc.key("facets", query)
+	if !ok {
+		return count(query)
+	}
+	value, err := sc.cache.Fetch(key, func() ([]byte, error) {
+		facets, err := count(query)
+		if err != nil {
+			return nil, err
+		}
+		return json.Marshal(facets)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var facets models.Facets
+	if err := json.Unmarshal(value, &facets); err != nil {
+		return count(query)
+	}
+	return &facets, nil
+}
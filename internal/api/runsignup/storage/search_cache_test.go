@@ -0,0 +1,102 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rbungay/racedatabase-api/internal/api/runsignup/models"
+	"github.com/rbungay/racedatabase-api/pkg/cache"
+	"github.com/rbungay/racedatabase-api/pkg/cache/redistest"
+)
+
+func TestSearchCache_InvalidateRetiresSearches(t *testing.T) {
+	store := NewMemoryStorage()
+	store.SaveRace(&models.RaceDetails{ID: 1, Name: "Turkey Trot"})
+
+	calls := 0
+	search := func(query models.RaceQuery) ([]models.Race, error) {
+		calls++
+		return store.SearchRaces(query)
+	}
+
+	sc := NewSearchCache(cache.New(cache.NewMemory(10), time.Minute))
+	for i := 0; i < 2; i++ {
+		races, err := sc.races(models.RaceQuery{}, search)
+		if err != nil || len(races) != 1 {
+			t.Fatalf("Unexpected search result: %v, %v", races, err)
+		}
+	}
+	if calls != 1 {
+		t.Errorf("Expected the repeated search to be cached, got %d calls", calls)
+	}
+
+	store.SaveRace(&models.RaceDetails{ID: 2, Name: "Jingle Bell Run"})
+	sc.Invalidate()
+
+	races, _ := sc.races(models.RaceQuery{}, search)
+	if calls != 2 || len(races) != 2 {
+		t.Errorf("Expected a fresh search after Invalidate: %d calls, %d races", calls, len(races))
+	}
+}
+
+func TestSearchCache_GenerationOutlivesEviction(t *testing.T) {
+	calls := 0
+	count := func(query models.RaceQuery) (*models.Facets, error) {
+		calls++
+		return &models.Facets{}, nil
+	}
+
+	// Room for only two entries, so caching searches evicts older ones
+	backend := cache.NewMemory(2)
+	sc := NewSearchCache(cache.New(backend, time.Minute))
+	sc.facets(models.RaceQuery{State: "NJ"}, count)
+	sc.Invalidate()
+	for _, state := range []string{"NY", "PA", "CT"} {
+		sc.facets(models.RaceQuery{State: state}, count)
+	}
+
+	calls = 0
+	sc.facets(models.RaceQuery{State: "NJ"}, count)
+	if calls != 1 {
+		t.Errorf("Expected a fresh count after Invalidate, got %d calls", calls)
+	}
+	if n, _ := backend.Counter(searchGenerationKey); n != 1 {
+		t.Errorf("Generation = %d after eviction, want 1", n)
+	}
+}
+
+func TestSearchCache_InvalidationIsShared(t *testing.T) {
+	server, err := redistest.NewServer("")
+	if err != nil {
+		t.Fatalf("Failed to start redis stand-in: %v", err)
+	}
+	defer server.Close()
+
+	replica := func() *SearchCache {
+		backend, err := cache.NewRedis(server.URL(), "test:")
+		if err != nil {
+			t.Fatalf("NewRedis failed: %v", err)
+		}
+		t.Cleanup(func() { backend.Close() })
+		return NewSearchCache(cache.New(backend, time.Minute))
+	}
+	first, second := replica(), replica()
+
+	calls := 0
+	count := func(query models.RaceQuery) (*models.Facets, error) {
+		calls++
+		return &models.Facets{}, nil
+	}
+
+	first.facets(models.RaceQuery{State: "NJ"}, count)
+	second.facets(models.RaceQuery{State: "NJ"}, count)
+	if calls != 1 {
+		t.Errorf("Expected replicas to share cached facets, got %d calls", calls)
+	}
+
+	first.Invalidate()
+	second.facets(models.RaceQuery{State: "NJ"}, count)
+	if calls != 2 {
+		t.Errorf("Expected invalidation on one replica to reach the other, got %d calls", calls)
+	}
+}
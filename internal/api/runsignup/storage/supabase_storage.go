@@ -14,23 +14,47 @@ import (
 // SupabaseStorage handles database operations with Supabase
 type SupabaseStorage struct {
     db *sql.DB
+
+    // searchCache, when set, serves repeated searches and is invalidated
+    // whenever stored races change
+    searchCache *SearchCache
 }
 
-// NewSupabaseStorage creates a new instance of SupabaseStorage
-func NewSupabaseStorage(dbURL string) (*SupabaseStorage, error) {
+// NewSupabaseStorage connects to dbURL with a connection pool sized by pool.
+// The storage is meant to be created once and shared; Close releases it.
+func NewSupabaseStorage(dbURL string, pool PoolConfig) (*SupabaseStorage, error) {
     db, err := sql.Open("postgres", dbURL)
     if err != nil {
         return nil, err
     }
-    
+    pool.apply(db)
+
     // Test the connection
     if err := db.Ping(); err != nil {
+        db.Close()
         return nil, err
     }
     
     return &SupabaseStorage{db: db}, nil
 }
 
+// Close closes every connection in the pool once in-flight queries finish
+func (s *SupabaseStorage) Close() error {
+    return s.db.Close()
+}
+
+// SetSearchCache caches SearchRaces and RaceFacets results in c
+func (s *SupabaseStorage) SetSearchCache(c *SearchCache) {
+    s.searchCache = c
+}
+
+// invalidateSearches retires cached searches after stored races change
+func (s *SupabaseStorage) invalidateSearches() {
+    if s.searchCache != nil {
+        s.searchCache.Invalidate()
+    }
+}
+
 // cleanFeeString converts "$20.00" to 20.00
 func cleanFeeString(fee string) float64 {
     // Remove "$" and any whitespace
@@ -43,16 +67,33 @@ func cleanFeeString(fee string) float64 {
     return val
 }
 
+// timeLayouts are the formats RunSignup uses for dates and times
+var timeLayouts = []string{
+    time.RFC3339,
+    "1/2/2006 15:04",
+    "1/2/2006",
+    "2006-01-02 15:04",
+}
+
 // nullTime returns a sql.NullTime for a given time string
 func nullTime(t string) sql.NullTime {
     if t == "" {
         return sql.NullTime{Valid: false}
     }
-    parsed, err := time.Parse(time.RFC3339, t)
-    if err != nil {
-        return sql.NullTime{Valid: false}
+    for _, layout := range timeLayouts {
+        if parsed, err := time.Parse(layout, t); err == nil {
+            return sql.NullTime{Time: parsed, Valid: true}
+        }
+    }
+    return sql.NullTime{Valid: false}
+}
+
+// nullFloat returns a sql.NullFloat64 for an optional coordinate
+func nullFloat(f *float64) sql.NullFloat64 {
+    if f == nil {
+        return sql.NullFloat64{Valid: false}
     }
-    return sql.NullTime{Time: parsed, Valid: true}
+    return sql.NullFloat64{Float64: *f, Valid: true}
 }
 
 // SaveRace stores a race and its associated events in the database
@@ -63,18 +104,35 @@ func (s *SupabaseStorage) SaveRace(race *models.RaceDetails) error {
     }
     defer tx.Rollback()
 
+    // Load the stored version so we can record what changed
+    previous, err := loadRace(tx, race.ID)
+    if err != nil {
+        return err
+    }
+
     // Insert race
     _, err = tx.Exec(`
-        INSERT INTO races (id, name, url, external_url, logo_url, timezone)
-        VALUES ($1, $2, $3, $4, $5, $6)
+        INSERT INTO races (id, name, url, external_url, logo_url, timezone, category,
+                           address, city, state, postal_code, latitude, longitude, next_date)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, NULLIF($10, ''), $11, $12, $13, $14)
         ON CONFLICT (id) DO UPDATE SET
             name = EXCLUDED.name,
             url = EXCLUDED.url,
             external_url = EXCLUDED.external_url,
             logo_url = EXCLUDED.logo_url,
             timezone = EXCLUDED.timezone,
+            category = EXCLUDED.category,
+            address = EXCLUDED.address,
+            city = EXCLUDED.city,
+            state = COALESCE(EXCLUDED.state, races.state),
+            postal_code = EXCLUDED.postal_code,
+            latitude = EXCLUDED.latitude,
+            longitude = EXCLUDED.longitude,
+            next_date = EXCLUDED.next_date,
             updated_at = NOW()
-    `, race.ID, race.Name, race.URL, race.ExternalURL, race.LogoURL, race.Timezone)
+    `, race.ID, race.Name, race.URL, race.ExternalURL, race.LogoURL, race.Timezone, race.Category,
+       race.Address, race.City, race.State, race.PostalCode,
+       nullFloat(race.Latitude), nullFloat(race.Longitude), nullTime(race.NextDate))
     if err != nil {
         return err
     }
@@ -82,26 +140,34 @@ func (s *SupabaseStorage) SaveRace(race *models.RaceDetails) error {
     // Insert events
     for _, event := range race.Events {
         _, err = tx.Exec(`
-            INSERT INTO events (event_id, race_id, name, start_time, end_time, event_type, distance, registration_opens, category)
-            VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+            INSERT INTO events (event_id, race_id, name, start_time, end_time, event_type, distance, distance_miles,
+                                registration_opens, category)
+            VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
             ON CONFLICT (event_id) DO UPDATE SET
                 name = EXCLUDED.name,
                 start_time = EXCLUDED.start_time,
                 end_time = EXCLUDED.end_time,
                 event_type = EXCLUDED.event_type,
                 distance = EXCLUDED.distance,
+                distance_miles = EXCLUDED.distance_miles,
                 registration_opens = EXCLUDED.registration_opens,
                 category = EXCLUDED.category,
+                deleted_at = NULL,
                 updated_at = NOW()
         `, event.EventID, race.ID, event.Name, 
            nullTime(event.StartTime), nullTime(event.EndTime),
-           event.EventType, event.Distance, 
+           event.EventType, event.Distance, eventDistanceMiles(event.Distance),
            nullTime(event.RegOpens), event.Category)
         if err != nil {
             return err
         }
 
-        // Insert registration periods with cleaned fee values
+        // Replace registration periods with cleaned fee values
+        _, err = tx.Exec(`DELETE FROM registration_periods WHERE event_id = $1`, event.EventID)
+        if err != nil {
+            return err
+        }
+
         for _, period := range event.RegPeriods {
             _, err = tx.Exec(`
                 INSERT INTO registration_periods (event_id, opens_at, closes_at, race_fee, processing_fee)
@@ -112,8 +178,48 @@ func (s *SupabaseStorage) SaveRace(race *models.RaceDetails) error {
             if err != nil {
                 return err
             }
+
+            if err := recordPrice(tx, event.EventID, period); err != nil {
+                return err
+            }
         }
     }
 
-    return tx.Commit()
+    if previous != nil {
+        diff := diffRaces(previous, race)
+
+        // Soft-delete events that no longer exist upstream, keeping their
+        // price history
+        for _, removed := range diff.RemovedEvents {
+            if _, err := tx.Exec(`DELETE FROM registration_periods WHERE event_id = $1`, removed.EventID); err != nil {
+                return err
+            }
+            if _, err := tx.Exec(`UPDATE events SET deleted_at = NOW(), updated_at = NOW() WHERE event_id = $1`, removed.EventID); err != nil {
+                return err
+            }
+        }
+
+        if err := recordRaceChange(tx, race.ID, diff); err != nil {
+            return err
+        }
+    }
+
+    // Refresh the full-text search vector now that events are written
+    _, err = tx.Exec(`
+        UPDATE races SET search_vector =
+            setweight(to_tsvector('english', coalesce(name, '')), 'A') ||
+            setweight(to_tsvector('english', coalesce(
+                (SELECT string_agg(e.name, ' ') FROM events e WHERE e.race_id = races.id AND e.deleted_at IS NULL), '')), 'B') ||
+            setweight(to_tsvector('english', coalesce(city, '')), 'C')
+        WHERE id = $1
+    `, race.ID)
+    if err != nil {
+        return err
+    }
+
+    if err := tx.Commit(); err != nil {
+        return err
+    }
+    s.invalidateSearches()
+    return nil
 }
\ No newline at end of file
@@ -0,0 +1,189 @@
+package storage
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/rbungay/racedatabase-api/internal/api/runsignup/models"
+)
+
+const syncJobColumns = `id, status, mode, states, event_types, races_fetched, races_saved,
+	races_failed, error, created_at, started_at, finished_at, updated_at`
+
+// CreateSyncJob stores a new pending job, filling in its ID and creation time
+func (s *SupabaseStorage) CreateSyncJob(job *models.SyncJob) error {
+	job.Status = models.SyncJobPending
+	return s.db.QueryRow(`
+		INSERT INTO sync_jobs (status, mode, states, event_types)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, created_at
+	`, job.Status, job.Mode, pq.Array(job.States), pq.Array(job.EventTypes)).Scan(&job.ID, &job.CreatedAt)
+}
+
+// SyncJob returns a job with its checkpoints, or nil if it does not exist
+func (s *SupabaseStorage) SyncJob(id int64) (*models.SyncJob, error) {
+	job, err := scanSyncJob(s.db.QueryRow(`SELECT `+syncJobColumns+` FROM sync_jobs WHERE id = $1`, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if job.Progress, err = s.SyncCheckpoints(id); err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+// ResumableSyncJob returns the most recent job that failed, was interrupted
+// or was left running by a process that died, with its checkpoints, or nil
+// if there is none. Jobs are updated with every checkpoint, so a running
+// job counts as dead once it has not been updated for staleAfter.
+func (s *SupabaseStorage) ResumableSyncJob(staleAfter time.Duration) (*models.SyncJob, error) {
+	var id int64
+	err := s.db.QueryRow(`
+		SELECT id FROM sync_jobs
+		WHERE status IN ($1, $2)
+		   OR (status = $3 AND updated_at < NOW() - $4::float8 * INTERVAL '1 second')
+		ORDER BY created_at DESC
+		LIMIT 1
+	`, models.SyncJobInterrupted, models.SyncJobFailed, models.SyncJobRunning, staleAfter.Seconds()).Scan(&id)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return s.SyncJob(id)
+}
+
+func scanSyncJob(row *sql.Row) (*models.SyncJob, error) {
+	var (
+		job                models.SyncJob
+		jobErr             sql.NullString
+		started, finished  sql.NullTime
+		states, eventTypes pq.StringArray
+	)
+	err := row.Scan(&job.ID, &job.Status, &job.Mode, &states, &eventTypes,
+		&job.RacesFetched, &job.RacesSaved, &job.RacesFailed, &jobErr,
+		&job.CreatedAt, &started, &finished, &job.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	job.States, job.EventTypes, job.Error = states, eventTypes, jobErr.String
+	if started.Valid {
+		job.StartedAt = &started.Time
+	}
+	if finished.Valid {
+		job.FinishedAt = &finished.Time
+	}
+	return &job, nil
+}
+
+// StartSyncJob marks a job as running and returns when it first started,
+// which stays the same when an interrupted job is resumed
+func (s *SupabaseStorage) StartSyncJob(id int64) (time.Time, error) {
+	var started time.Time
+	err := s.db.QueryRow(`
+		UPDATE sync_jobs SET
+			status = $2,
+			started_at = COALESCE(started_at, NOW()),
+			updated_at = NOW(),
+			finished_at = NULL,
+			error = NULL
+		WHERE id = $1
+		RETURNING started_at
+	`, id, models.SyncJobRunning).Scan(&started)
+	return started, err
+}
+
+// SyncCheckpoints lists a job's checkpoints by state and event type
+func (s *SupabaseStorage) SyncCheckpoints(jobID int64) ([]models.SyncCheckpoint, error) {
+	rows, err := s.db.Query(`
+		SELECT state, event_type, modified_since, next_page, done
+		FROM sync_job_checkpoints
+		WHERE job_id = $1
+		ORDER BY state, event_type
+	`, jobID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var checkpoints []models.SyncCheckpoint
+	for rows.Next() {
+		var (
+			cp            models.SyncCheckpoint
+			modifiedSince sql.NullTime
+		)
+		if err := rows.Scan(&cp.State, &cp.EventType, &modifiedSince, &cp.NextPage, &cp.Done); err != nil {
+			return nil, err
+		}
+		if modifiedSince.Valid {
+			cp.ModifiedSince = &modifiedSince.Time
+		}
+		checkpoints = append(checkpoints, cp)
+	}
+	return checkpoints, rows.Err()
+}
+
+// SaveSyncCheckpoint records a job's position after a page and adds the
+// page's races to the job's progress counts, atomically so a resumed job
+// neither skips nor double counts a page
+func (s *SupabaseStorage) SaveSyncCheckpoint(jobID int64, cp models.SyncCheckpoint, fetched, saved, failed int) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var modifiedSince sql.NullTime
+	if cp.ModifiedSince != nil {
+		modifiedSince = sql.NullTime{Time: *cp.ModifiedSince, Valid: true}
+	}
+	_, err = tx.Exec(`
+		INSERT INTO sync_job_checkpoints (job_id, state, event_type, modified_since, next_page, done)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (job_id, state, event_type) DO UPDATE SET
+			modified_since = EXCLUDED.modified_since,
+			next_page = EXCLUDED.next_page,
+			done = EXCLUDED.done,
+			updated_at = NOW()
+	`, jobID, cp.State, cp.EventType, modifiedSince, cp.NextPage, cp.Done)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`
+		UPDATE sync_jobs SET
+			races_fetched = races_fetched + $2,
+			races_saved = races_saved + $3,
+			races_failed = races_failed + $4,
+			updated_at = NOW()
+		WHERE id = $1
+	`, jobID, fetched, saved, failed)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// FinishSyncJob records the final status of a job and its error, if any
+func (s *SupabaseStorage) FinishSyncJob(id int64, status string, jobErr error) error {
+	var message sql.NullString
+	if jobErr != nil {
+		message = sql.NullString{String: errorMessage(jobErr), Valid: true}
+	}
+	// Interrupted jobs are not finished, since they can be resumed
+	finished := status != models.SyncJobInterrupted
+	_, err := s.db.Exec(`
+		UPDATE sync_jobs SET
+			status = $2,
+			error = $3,
+			updated_at = NOW(),
+			finished_at = CASE WHEN $4 THEN NOW() END
+		WHERE id = $1
+	`, id, status, message, finished)
+	return err
+}
@@ -0,0 +1,43 @@
+package storage
+
+import (
+	"database/sql"
+	"time"
+)
+
+// ClaimSyncRun marks a scheduled job as running unless another process has
+// claimed it within staleAfter, and reports whether the claim succeeded
+func (s *SupabaseStorage) ClaimSyncRun(job string, staleAfter time.Duration) (bool, error) {
+	result, err := s.db.Exec(`
+		INSERT INTO sync_runs (job, running_since, last_started_at)
+		VALUES ($1, NOW(), NOW())
+		ON CONFLICT (job) DO UPDATE SET
+			running_since = NOW(),
+			last_started_at = NOW()
+		WHERE sync_runs.running_since IS NULL
+		   OR sync_runs.running_since < NOW() - make_interval(secs => $2)
+	`, job, staleAfter.Seconds())
+	if err != nil {
+		return false, err
+	}
+	claimed, err := result.RowsAffected()
+	return claimed == 1, err
+}
+
+// FinishSyncRun releases a job's claim and records the outcome of its run
+func (s *SupabaseStorage) FinishSyncRun(job string, synced int, runErr error) error {
+	status, message := "succeeded", sql.NullString{}
+	if runErr != nil {
+		status, message = "failed", sql.NullString{String: errorMessage(runErr), Valid: true}
+	}
+	_, err := s.db.Exec(`
+		UPDATE sync_runs SET
+			running_since = NULL,
+			last_finished_at = NOW(),
+			last_status = $2,
+			last_error = $3,
+			last_synced = $4
+		WHERE job = $1
+	`, job, status, message, synced)
+	return err
+}
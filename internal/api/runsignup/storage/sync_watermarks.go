@@ -0,0 +1,41 @@
+package storage
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/rbungay/racedatabase-api/internal/api/runsignup/models"
+)
+
+// SyncWatermark returns the high-water mark of a state, or nil if the state
+// has never been synced successfully
+func (s *SupabaseStorage) SyncWatermark(state string) (*models.SyncWatermark, error) {
+	var (
+		syncedThrough time.Time
+		lastFull      sql.NullTime
+	)
+	err := s.db.QueryRow(`
+		SELECT synced_through, last_full_sync_at FROM sync_watermarks WHERE state = $1
+	`, state).Scan(&syncedThrough, &lastFull)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &models.SyncWatermark{State: state, SyncedThrough: syncedThrough, LastFullSync: lastFull.Time}, nil
+}
+
+// AdvanceSyncWatermark records a successful sync of a state that started at
+// syncedThrough. The mark never moves backwards.
+func (s *SupabaseStorage) AdvanceSyncWatermark(state string, syncedThrough time.Time, full bool) error {
+	lastFull := sql.NullTime{Time: syncedThrough, Valid: full}
+	_, err := s.db.Exec(`
+		INSERT INTO sync_watermarks (state, synced_through, last_full_sync_at)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (state) DO UPDATE SET
+			synced_through = GREATEST(sync_watermarks.synced_through, EXCLUDED.synced_through),
+			last_full_sync_at = GREATEST(sync_watermarks.last_full_sync_at, EXCLUDED.last_full_sync_at)
+	`, state, syncedThrough, lastFull)
+	return err
+}
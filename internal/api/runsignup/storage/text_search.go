@@ -0,0 +1,122 @@
+package storage
+
+import (
+	"strings"
+	"unicode"
+)
+
+// headlineOptions configures ts_headline to mark matches the same way
+// highlightText does
+const headlineOptions = "StartSel=<mark>, StopSel=</mark>, HighlightAll=true"
+
+// Text search weights per field, matching ts_rank's defaults for the A, B
+// and C weights assigned in the search_vector
+var textWeights = map[string]float64{
+	"name":   1.0,
+	"events": 0.4,
+	"city":   0.2,
+}
+
+// stopWords are ignored in queries and documents, as Postgres' english
+// configuration does
+var stopWords = map[string]bool{
+	"a": true, "an": true, "and": true, "at": true, "for": true, "in": true,
+	"of": true, "on": true, "or": true, "the": true, "to": true, "with": true,
+}
+
+// highlights keeps only the fields whose snippet contains a match
+func highlights(snippets map[string]string) map[string]string {
+	matched := make(map[string]string)
+	for field, snippet := range snippets {
+		if strings.Contains(snippet, "<mark>") {
+			matched[field] = snippet
+		}
+	}
+	if len(matched) == 0 {
+		return nil
+	}
+	return matched
+}
+
+// queryTerms splits a free-text query into lower-cased terms. Words are
+// matched whole, without the stemming Postgres applies, so "trot" does not
+// find "trotting" in MemoryStorage.
+func queryTerms(query string) map[string]bool {
+	terms := make(map[string]bool)
+	for _, word := range strings.FieldsFunc(strings.ToLower(query), isWordSeparator) {
+		if !stopWords[word] {
+			terms[word] = true
+		}
+	}
+	return terms
+}
+
+// matchedTerms returns the query terms that occur in text
+func matchedTerms(text string, terms map[string]bool) map[string]bool {
+	matched := make(map[string]bool)
+	for _, word := range strings.FieldsFunc(strings.ToLower(text), isWordSeparator) {
+		if terms[word] {
+			matched[word] = true
+		}
+	}
+	return matched
+}
+
+// highlightText wraps every word of text that matches a query term in <mark>
+func highlightText(text string, terms map[string]bool) string {
+	var b strings.Builder
+	word := strings.Builder{}
+	flush := func() {
+		if word.Len() == 0 {
+			return
+		}
+		w := word.String()
+		if terms[strings.ToLower(w)] {
+			b.WriteString("<mark>" + w + "</mark>")
+		} else {
+			b.WriteString(w)
+		}
+		word.Reset()
+	}
+
+	for _, r := range text {
+		if isWordSeparator(r) {
+			flush()
+			b.WriteRune(r)
+			continue
+		}
+		word.WriteRune(r)
+	}
+	flush()
+	return b.String()
+}
+
+// textMatch scores fields against a query the way the Postgres search does:
+// every term must appear in some field, and the rank weighs matches by field.
+// ok is false when the fields do not match.
+func textMatch(query string, fields map[string]string) (rank float64, snippets map[string]string, ok bool) {
+	terms := queryTerms(query)
+	if len(terms) == 0 {
+		return 0, nil, false
+	}
+
+	found := make(map[string]bool)
+	snippets = make(map[string]string)
+	for field, text := range fields {
+		matched := matchedTerms(text, terms)
+		for term := range matched {
+			found[term] = true
+		}
+		rank += textWeights[field] * float64(len(matched)) / float64(len(terms))
+		snippets[field] = highlightText(text, terms)
+	}
+
+	if len(found) != len(terms) {
+		return 0, nil, false
+	}
+	return rank, highlights(snippets), true
+}
+
+func isWordSeparator(r rune) bool {
+	return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+}
@@ -0,0 +1,25 @@
+package storage
+
+import "testing"
+
+func TestTextMatch_IgnoresStopWords(t *testing.T) {
+	rank, snippets, ok := textMatch("the color run", map[string]string{"name": "Color Run", "city": "Philadelphia"})
+	if !ok {
+		t.Fatalf("Expected a match")
+	}
+	if rank <= 0 {
+		t.Errorf("Expected a positive rank, got %v", rank)
+	}
+	if _, matched := snippets["city"]; matched {
+		t.Errorf("Expected only matched fields in highlights, got %v", snippets)
+	}
+}
+
+func TestTextMatch_WholeWords(t *testing.T) {
+	if _, _, ok := textMatch("Turkey Trot", map[string]string{"events": "turkey-trot 5K"}); !ok {
+		t.Errorf("Expected a case-insensitive match across punctuation")
+	}
+	if _, _, ok := textMatch("trot", map[string]string{"name": "Turkey Trotting"}); ok {
+		t.Errorf("Expected no match on part of a word")
+	}
+}
@@ -0,0 +1,124 @@
+// Package validation checks API query parameters before they are forwarded
+// to RunSignup, reporting every problem at once.
+package validation
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rbungay/racedatabase-api/internal/api/runsignup/constants"
+	apperrors "github.com/rbungay/racedatabase-api/pkg/errors"
+)
+
+const (
+	dateLayout     = "2006-01-02"
+	maxDistance    = 1000
+	maxRadiusMiles = 500
+)
+
+var zipPattern = regexp.MustCompile(`^\d{5}$`)
+
+// FieldError is a problem with a single query parameter.
+type FieldError = apperrors.FieldError
+
+// Errors is the list of problems found in a request.
+type Errors []FieldError
+
+func (e Errors) Error() string {
+	messages := make([]string, len(e))
+	for i, fe := range e {
+		messages[i] = fe.Field + ": " + fe.Message
+	}
+	return "invalid parameters: " + strings.Join(messages, "; ")
+}
+
+func (e *Errors) add(field, format string, args ...interface{}) {
+	*e = append(*e, FieldError{Field: field, Message: fmt.Sprintf(format, args...)})
+}
+
+// ValidateEventsQuery checks the parameters accepted by the events endpoint
+// and returns nil when they are all valid.
+func ValidateEventsQuery(query url.Values) Errors {
+	var errs Errors
+
+	state := query.Get("state")
+	switch {
+	case state == "":
+		errs.add("state", "is required")
+	case !constants.USPSStateCodes[strings.ToUpper(state)]:
+		errs.add("state", "must be a two-letter USPS state code, got %q", state)
+	}
+
+	if eventType := query.Get("event_type"); eventType != "" && !constants.ValidEventTypes[eventType] {
+		errs.add("event_type", "unknown event type %q", eventType)
+	}
+
+	startDate, startOK := parseDate(&errs, query, "start_date")
+	endDate, endOK := parseDate(&errs, query, "end_date")
+	if startOK && endOK && startDate.After(endDate) {
+		errs.add("end_date", "must not be before start_date")
+	}
+
+	minDistance, minOK := parseNumber(&errs, query, "min_distance", maxDistance)
+	maxDist, maxOK := parseNumber(&errs, query, "max_distance", maxDistance)
+	if minOK && maxOK && minDistance > maxDist {
+		errs.add("max_distance", "must not be less than min_distance")
+	}
+
+	if zipcode := query.Get("zipcode"); zipcode != "" && !zipPattern.MatchString(zipcode) {
+		errs.add("zipcode", "must be a 5-digit ZIP code, got %q", zipcode)
+	}
+
+	if _, ok := parseNumber(&errs, query, "radius", maxRadiusMiles); ok && query.Get("zipcode") == "" {
+		errs.add("radius", "requires zipcode")
+	}
+
+	if facets := query.Get("facets"); facets != "" {
+		if _, err := strconv.ParseBool(facets); err != nil {
+			errs.add("facets", "must be true or false, got %q", facets)
+		}
+	}
+
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
+
+// parseDate reads an optional ISO-8601 (YYYY-MM-DD) date; ok is false when
+// the parameter is missing or invalid
+func parseDate(errs *Errors, query url.Values, field string) (t time.Time, ok bool) {
+	value := query.Get(field)
+	if value == "" {
+		return t, false
+	}
+	t, err := time.Parse(dateLayout, value)
+	if err != nil {
+		errs.add(field, "must be an ISO-8601 date (YYYY-MM-DD), got %q", value)
+		return t, false
+	}
+	return t, true
+}
+
+// parseNumber reads an optional number in [0, max]; ok is false when the
+// parameter is missing or invalid
+func parseNumber(errs *Errors, query url.Values, field string, max float64) (n float64, ok bool) {
+	value := query.Get(field)
+	if value == "" {
+		return 0, false
+	}
+	n, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		errs.add(field, "must be a number, got %q", value)
+		return 0, false
+	}
+	if n < 0 || n > max {
+		errs.add(field, "must be between 0 and %g", max)
+		return 0, false
+	}
+	return n, true
+}
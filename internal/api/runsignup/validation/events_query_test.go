@@ -0,0 +1,52 @@
+package validation
+
+import (
+	"net/url"
+	"testing"
+)
+
+func TestValidateEventsQuery_Valid(t *testing.T) {
+	query, _ := url.ParseQuery("state=nj&event_type=running_race&start_date=2025-01-01&end_date=2025-12-31" +
+		"&min_distance=3.1&max_distance=26.2&zipcode=07302&radius=25&facets=true")
+
+	if errs := ValidateEventsQuery(query); errs != nil {
+		t.Errorf("Expected no errors, got %v", errs)
+	}
+}
+
+func TestValidateEventsQuery_ReportsAllErrors(t *testing.T) {
+	query, _ := url.ParseQuery("state=XX&event_type=sprint&start_date=2025-12-31&end_date=2025-01-01" +
+		"&min_distance=abc&max_distance=-5&zipcode=7302&radius=900&facets=maybe")
+
+	errs := ValidateEventsQuery(query)
+
+	want := map[string]bool{
+		"state": true, "event_type": true, "end_date": true, "min_distance": true,
+		"max_distance": true, "zipcode": true, "radius": true, "facets": true,
+	}
+	if len(errs) != len(want) {
+		t.Fatalf("Expected %d errors, got %d: %v", len(want), len(errs), errs)
+	}
+	for _, fe := range errs {
+		if !want[fe.Field] {
+			t.Errorf("Unexpected error for field %s: %s", fe.Field, fe.Message)
+		}
+	}
+}
+
+func TestValidateEventsQuery_StateRequired(t *testing.T) {
+	errs := ValidateEventsQuery(url.Values{})
+
+	if len(errs) != 1 || errs[0].Field != "state" {
+		t.Errorf("Expected a single state error, got %v", errs)
+	}
+}
+
+func TestValidateEventsQuery_BadDateFormat(t *testing.T) {
+	query, _ := url.ParseQuery("state=NY&start_date=01/01/2025")
+
+	errs := ValidateEventsQuery(query)
+	if len(errs) != 1 || errs[0].Field != "start_date" {
+		t.Errorf("Expected a start_date error, got %v", errs)
+	}
+}
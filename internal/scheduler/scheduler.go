@@ -0,0 +1,152 @@
+// Package scheduler runs background jobs on cron-style schedules. Each run
+// is delayed by a random jitter so replicas don't hit upstream APIs in
+// lockstep, and runs of the same job never overlap: within a process a job
+// waits for its previous run, and across processes a RunStore claims the run.
+package scheduler
+
+import (
+	"context"
+	"log"
+	"math/rand/v2"
+	"sync"
+	"time"
+)
+
+// Job is a named unit of background work
+type Job struct {
+	Name     string
+	Schedule Schedule
+
+	// Jitter is the upper bound of a random delay added to every run
+	Jitter time.Duration
+
+	// Run does the work and returns how many items it synced
+	Run func(ctx context.Context) (int, error)
+}
+
+// RunStore records job runs so that only one process runs a job at a time
+// and the outcome of each job's last run can be inspected
+type RunStore interface {
+	// ClaimSyncRun marks the job as running unless another process holds a
+	// claim younger than staleAfter, and reports whether it was claimed
+	ClaimSyncRun(job string, staleAfter time.Duration) (bool, error)
+
+	// FinishSyncRun releases the claim and records the run's outcome
+	FinishSyncRun(job string, synced int, runErr error) error
+}
+
+// Scheduler runs jobs until its context is cancelled
+type Scheduler struct {
+	store RunStore
+
+	// staleAfter is how long a claim held by a process that died mid-run
+	// blocks other processes from running the job
+	staleAfter time.Duration
+
+	jobs []Job
+	now  func() time.Time
+
+	mu      sync.Mutex
+	running map[string]bool
+}
+
+// New creates a scheduler recording runs in store, which may be nil to run
+// jobs without cross-process overlap prevention. A claim older than
+// staleAfter is assumed to belong to a process that died mid-run.
+func New(store RunStore, staleAfter time.Duration) *Scheduler {
+	return &Scheduler{
+		store:      store,
+		staleAfter: staleAfter,
+		now:        time.Now,
+		running:    make(map[string]bool),
+	}
+}
+
+// Add registers a job. Jobs must be added before Start.
+func (s *Scheduler) Add(job Job) {
+	s.jobs = append(s.jobs, job)
+}
+
+// Start runs every job on its schedule until ctx is cancelled, then waits
+// for in-flight runs to return
+func (s *Scheduler) Start(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, job := range s.jobs {
+		wg.Add(1)
+		go func(job Job) {
+			defer wg.Done()
+			s.loop(ctx, job)
+		}(job)
+	}
+	wg.Wait()
+}
+
+func (s *Scheduler) loop(ctx context.Context, job Job) {
+	for {
+		next := job.Schedule.Next(s.now())
+		if next.IsZero() {
+			log.Printf("scheduler: %s has no upcoming runs", job.Name)
+			return
+		}
+		if job.Jitter > 0 {
+			next = next.Add(rand.N(job.Jitter))
+		}
+
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		s.RunNow(ctx, job)
+	}
+}
+
+// RunNow runs a job immediately unless a run of it is already in progress
+// in this or another process. It reports whether the job ran.
+func (s *Scheduler) RunNow(ctx context.Context, job Job) bool {
+	s.mu.Lock()
+	if s.running[job.Name] {
+		s.mu.Unlock()
+		log.Printf("scheduler: skipping %s, the previous run is still in progress", job.Name)
+		return false
+	}
+	s.running[job.Name] = true
+	s.mu.Unlock()
+
+	defer func() {
+		s.mu.Lock()
+		delete(s.running, job.Name)
+		s.mu.Unlock()
+	}()
+
+	if s.store != nil {
+		claimed, err := s.store.ClaimSyncRun(job.Name, s.staleAfter)
+		if err != nil {
+			log.Printf("scheduler: failed to claim %s: %v", job.Name, err)
+			return false
+		}
+		if !claimed {
+			log.Printf("scheduler: skipping %s, another process is running it", job.Name)
+			return false
+		}
+	}
+
+	log.Printf("scheduler: running %s", job.Name)
+	started := s.now()
+	synced, err := job.Run(ctx)
+	if err != nil {
+		log.Printf("scheduler: %s failed after %v: %v", job.Name, s.now().Sub(started), err)
+	} else {
+		log.Printf("scheduler: %s synced %d in %v", job.Name, synced, s.now().Sub(started))
+	}
+
+	if s.store != nil {
+		if err := s.store.FinishSyncRun(job.Name, synced, err); err != nil {
+			log.Printf("scheduler: failed to record %s: %v", job.Name, err)
+		}
+	}
+	return true
+}
@@ -0,0 +1,138 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakeRunStore hands out one claim per job, like sync_runs shared between
+// processes
+type fakeRunStore struct {
+	mu       sync.Mutex
+	claimed  map[string]bool
+	finished map[string]error
+	synced   map[string]int
+}
+
+func newFakeRunStore() *fakeRunStore {
+	return &fakeRunStore{
+		claimed:  make(map[string]bool),
+		finished: make(map[string]error),
+		synced:   make(map[string]int),
+	}
+}
+
+func (f *fakeRunStore) ClaimSyncRun(job string, staleAfter time.Duration) (bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.claimed[job] {
+		return false, nil
+	}
+	f.claimed[job] = true
+	return true, nil
+}
+
+func (f *fakeRunStore) FinishSyncRun(job string, synced int, runErr error) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.claimed[job] = false
+	f.finished[job] = runErr
+	f.synced[job] = synced
+	return nil
+}
+
+func TestScheduler_RunsOnSchedule(t *testing.T) {
+	store := newFakeRunStore()
+	s := New(store, time.Hour)
+
+	var runs atomic.Int32
+	ctx, cancel := context.WithCancel(context.Background())
+	s.Add(Job{
+		Name:     "sync:NJ",
+		Schedule: every(10 * time.Millisecond),
+		Run: func(ctx context.Context) (int, error) {
+			if runs.Add(1) == 3 {
+				cancel()
+			}
+			return 7, nil
+		},
+	})
+
+	done := make(chan struct{})
+	go func() {
+		s.Start(ctx)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Scheduler did not stop after cancellation")
+	}
+
+	if n := runs.Load(); n != 3 {
+		t.Errorf("Expected 3 runs, got %d", n)
+	}
+	if store.synced["sync:NJ"] != 7 || store.claimed["sync:NJ"] {
+		t.Errorf("Expected the run to be recorded and released: %+v", store)
+	}
+}
+
+func TestScheduler_RunNowSkipsOverlappingRuns(t *testing.T) {
+	s := New(nil, time.Hour)
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	job := Job{
+		Name: "sync:NJ",
+		Run: func(ctx context.Context) (int, error) {
+			close(started)
+			<-release
+			return 0, nil
+		},
+	}
+
+	done := make(chan bool)
+	go func() { done <- s.RunNow(context.Background(), job) }()
+	<-started
+
+	if s.RunNow(context.Background(), job) {
+		t.Errorf("Expected an overlapping run to be skipped")
+	}
+	close(release)
+	if !<-done {
+		t.Errorf("Expected the first run to complete")
+	}
+}
+
+func TestScheduler_RunNowSkipsJobsClaimedElsewhere(t *testing.T) {
+	store := newFakeRunStore()
+	store.claimed["sync:NJ"] = true
+	s := New(store, time.Hour)
+
+	ran := false
+	job := Job{Name: "sync:NJ", Run: func(ctx context.Context) (int, error) {
+		ran = true
+		return 0, nil
+	}}
+	if s.RunNow(context.Background(), job) || ran {
+		t.Errorf("Expected a job claimed by another process to be skipped")
+	}
+}
+
+func TestScheduler_RecordsFailures(t *testing.T) {
+	store := newFakeRunStore()
+	s := New(store, time.Hour)
+
+	failure := errors.New("upstream unavailable")
+	s.RunNow(context.Background(), Job{Name: "sync:NJ", Run: func(ctx context.Context) (int, error) {
+		return 0, failure
+	}})
+
+	if !errors.Is(store.finished["sync:NJ"], failure) {
+		t.Errorf("Expected the failure to be recorded, got %v", store.finished["sync:NJ"])
+	}
+}
@@ -0,0 +1,99 @@
+// Package cache caches byte values in a pluggable Backend: an in-process
+// LRU (Memory) or a Redis-compatible server shared between replicas (Redis).
+// Concurrent fetches of the same missing key share a single call.
+package cache
+
+import (
+	"log"
+	"time"
+)
+
+// Backend stores values with an expiry. A missing or expired key is
+// reported as ok == false, not as an error.
+//
+// Backends also keep counters, which never expire and are not evicted to
+// make room for values. A counter that was never incremented is 0.
+type Backend interface {
+	Get(key string) (value []byte, ok bool, err error)
+	Set(key string, value []byte, ttl time.Duration) error
+	Delete(keys ...string) error
+	Incr(key string) (int64, error)
+	Counter(key string) (int64, error)
+}
+
+// Cache keeps values in a Backend for ttl and records hit and miss counts.
+// Backend errors are logged and treated as misses so an unavailable shared
+// cache never fails a request. It is safe for concurrent use.
+type Cache struct {
+	backend Backend
+	ttl     time.Duration
+
+	group group
+	stats Stats
+}
+
+// New creates a cache storing values in backend for ttl each
+func New(backend Backend, ttl time.Duration) *Cache {
+	return &Cache{backend: backend, ttl: ttl}
+}
+
+// Backend returns the backend values are stored in
+func (c *Cache) Backend() Backend {
+	return c.backend
+}
+
+// Get returns the value for key if it is cached
+func (c *Cache) Get(key string) ([]byte, bool) {
+	value, ok, err := c.backend.Get(key)
+	if err != nil {
+		c.stats.errors.Add(1)
+		log.Printf("cache: get %s: %v", key, err)
+	}
+	if !ok || err != nil {
+		c.stats.misses.Add(1)
+		return nil, false
+	}
+	c.stats.hits.Add(1)
+	return value, true
+}
+
+// Set caches value under key
+func (c *Cache) Set(key string, value []byte) {
+	if err := c.backend.Set(key, value, c.ttl); err != nil {
+		c.stats.errors.Add(1)
+		log.Printf("cache: set %s: %v", key, err)
+	}
+}
+
+// Delete removes keys from the cache
+func (c *Cache) Delete(keys ...string) {
+	if err := c.backend.Delete(keys...); err != nil {
+		c.stats.errors.Add(1)
+		log.Printf("cache: delete %v: %v", keys, err)
+	}
+}
+
+// Fetch returns the cached value for key, or calls fetch and caches its
+// result. Concurrent callers missing the same key wait for a single fetch.
+// Errors are returned to every waiting caller and are not cached.
+func (c *Cache) Fetch(key string, fetch func() ([]byte, error)) ([]byte, error) {
+	if value, ok := c.Get(key); ok {
+		return value, nil
+	}
+
+	value, err, shared := c.group.do(key, func() ([]byte, error) {
+		// A fetch that finished since Get may already have stored it
+		if value, ok, err := c.backend.Get(key); ok && err == nil {
+			return value, nil
+		}
+		value, err := fetch()
+		if err == nil {
+			c.Set(key, value)
+		}
+		return value, err
+	})
+	if shared {
+		c.stats.shared.Add(1)
+	}
+	return value, err
+}
@@ -0,0 +1,120 @@
+package cache
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// failingBackend fails every operation, like an unreachable shared cache
+type failingBackend struct{}
+
+func (failingBackend) Get(key string) ([]byte, bool, error) {
+	return nil, false, errors.New("connection refused")
+}
+
+func (failingBackend) Set(key string, value []byte, ttl time.Duration) error {
+	return errors.New("connection refused")
+}
+
+func (failingBackend) Delete(keys ...string) error {
+	return errors.New("connection refused")
+}
+
+func (failingBackend) Incr(key string) (int64, error) {
+	return 0, errors.New("connection refused")
+}
+
+func (failingBackend) Counter(key string) (int64, error) {
+	return 0, errors.New("connection refused")
+}
+
+func TestCache_Stats(t *testing.T) {
+	c := New(NewMemory(10), time.Minute)
+
+	c.Get("a")
+	c.Set("a", []byte("1"))
+	c.Get("a")
+	c.Get("a")
+
+	stats := c.Stats()
+	if stats.Hits != 2 || stats.Misses != 1 || stats.Entries != 1 {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+}
+
+func TestCache_FetchSharesConcurrentCalls(t *testing.T) {
+	c := New(NewMemory(10), time.Minute)
+
+	var calls atomic.Int32
+	release := make(chan struct{})
+	fetch := func() ([]byte, error) {
+		calls.Add(1)
+		<-release
+		return []byte("value"), nil
+	}
+
+	const callers = 10
+	var wg sync.WaitGroup
+	started := make(chan struct{}, callers)
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			started <- struct{}{}
+			value, err := c.Fetch("key", fetch)
+			if err != nil || string(value) != "value" {
+				t.Errorf("Unexpected result: %q, %v", value, err)
+			}
+		}()
+	}
+	for i := 0; i < callers; i++ {
+		<-started
+	}
+	// Give the callers time to block on the in-flight fetch
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if n := calls.Load(); n != 1 {
+		t.Errorf("Expected one upstream call, got %d", n)
+	}
+	if _, err := c.Fetch("key", fetch); err != nil || calls.Load() != 1 {
+		t.Errorf("Expected a cache hit after the fetch")
+	}
+}
+
+func TestCache_FetchDoesNotCacheErrors(t *testing.T) {
+	c := New(NewMemory(10), time.Minute)
+
+	calls := 0
+	fetch := func() ([]byte, error) {
+		calls++
+		return nil, errors.New("upstream failed")
+	}
+
+	for i := 0; i < 2; i++ {
+		if _, err := c.Fetch("key", fetch); err == nil {
+			t.Errorf("Expected the fetch error")
+		}
+	}
+	if calls != 2 {
+		t.Errorf("Errors should not be cached: got %d calls", calls)
+	}
+}
+
+func TestCache_BackendErrorsAreMisses(t *testing.T) {
+	c := New(failingBackend{}, time.Minute)
+
+	value, err := c.Fetch("key", func() ([]byte, error) { return []byte("value"), nil })
+	if err != nil || string(value) != "value" {
+		t.Errorf("A failing backend should fall through to fetch: got %q, %v", value, err)
+	}
+	c.Delete("key")
+
+	if stats := c.Stats(); stats.Errors == 0 || stats.Misses != 1 {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+}
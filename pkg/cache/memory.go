@@ -0,0 +1,124 @@
+package cache
+
+import (
+	"container/list"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Memory is an in-process Backend holding at most maxEntries values and
+// evicting the least recently used first. It is safe for concurrent use.
+type Memory struct {
+	mu         sync.Mutex
+	maxEntries int
+	order      *list.List // front is most recently used
+	items      map[string]*list.Element
+	counters   map[string]int64
+
+	evictions   atomic.Int64
+	expirations atomic.Int64
+
+	// now is replaced in tests
+	now func() time.Time
+}
+
+type entry struct {
+	key     string
+	value   []byte
+	expires time.Time
+}
+
+// NewMemory creates a Memory backend holding at most maxEntries values; 0
+// means unbounded
+func NewMemory(maxEntries int) *Memory {
+	return &Memory{
+		maxEntries: maxEntries,
+		order:      list.New(),
+		items:      make(map[string]*list.Element),
+		counters:   make(map[string]int64),
+		now:        time.Now,
+	}
+}
+
+// Get returns the value for key if it is present and has not expired
+func (m *Memory) Get(key string) ([]byte, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	elem, ok := m.items[key]
+	if !ok {
+		return nil, false, nil
+	}
+	e := elem.Value.(*entry)
+	if m.now().After(e.expires) {
+		m.removeElement(elem)
+		m.expirations.Add(1)
+		return nil, false, nil
+	}
+
+	m.order.MoveToFront(elem)
+	return e.value, true, nil
+}
+
+// Set stores value under key for ttl, evicting the least recently used entry
+// when the cache is full
+func (m *Memory) Set(key string, value []byte, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	expires := m.now().Add(ttl)
+	if elem, ok := m.items[key]; ok {
+		e := elem.Value.(*entry)
+		e.value, e.expires = value, expires
+		m.order.MoveToFront(elem)
+		return nil
+	}
+
+	m.items[key] = m.order.PushFront(&entry{key: key, value: value, expires: expires})
+	for m.maxEntries > 0 && m.order.Len() > m.maxEntries {
+		m.removeElement(m.order.Back())
+		m.evictions.Add(1)
+	}
+	return nil
+}
+
+// Delete removes keys from the cache
+func (m *Memory) Delete(keys ...string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, key := range keys {
+		if elem, ok := m.items[key]; ok {
+			m.removeElement(elem)
+		}
+	}
+	return nil
+}
+
+// Incr increments the counter under key and returns its new value
+func (m *Memory) Incr(key string) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.counters[key]++
+	return m.counters[key], nil
+}
+
+// Counter returns the value of the counter under key
+func (m *Memory) Counter(key string) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.counters[key], nil
+}
+
+// Len returns the number of entries, including expired ones not yet removed
+func (m *Memory) Len() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.order.Len()
+}
+
+func (m *Memory) removeElement(elem *list.Element) {
+	m.order.Remove(elem)
+	delete(m.items, elem.Value.(*entry).key)
+}
@@ -0,0 +1,90 @@
+package cache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMemory_GetSetDelete(t *testing.T) {
+	m := NewMemory(10)
+
+	if _, ok, _ := m.Get("a"); ok {
+		t.Fatalf("Expected a miss on an empty cache")
+	}
+	m.Set("a", []byte("1"), time.Minute)
+	value, ok, err := m.Get("a")
+	if !ok || err != nil || string(value) != "1" {
+		t.Errorf("Unexpected value: got %q, %v, %v", value, ok, err)
+	}
+
+	m.Set("a", []byte("2"), time.Minute)
+	if value, _, _ := m.Get("a"); string(value) != "2" {
+		t.Errorf("Set should replace the value: got %q", value)
+	}
+
+	m.Delete("a")
+	if _, ok, _ := m.Get("a"); ok {
+		t.Errorf("Expected a miss after Delete")
+	}
+}
+
+func TestMemory_Expiry(t *testing.T) {
+	m := NewMemory(10)
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	m.now = func() time.Time { return now }
+
+	m.Set("a", []byte("1"), time.Minute)
+	now = now.Add(59 * time.Second)
+	if _, ok, _ := m.Get("a"); !ok {
+		t.Errorf("Entry expired early")
+	}
+
+	now = now.Add(2 * time.Second)
+	if _, ok, _ := m.Get("a"); ok {
+		t.Errorf("Expected the entry to have expired")
+	}
+	if m.Len() != 0 {
+		t.Errorf("Expired entry should be removed, got %d entries", m.Len())
+	}
+	if n := m.expirations.Load(); n != 1 {
+		t.Errorf("Unexpected expirations: %d", n)
+	}
+}
+
+func TestMemory_EvictsLeastRecentlyUsed(t *testing.T) {
+	m := NewMemory(2)
+
+	m.Set("a", []byte("1"), time.Minute)
+	m.Set("b", []byte("2"), time.Minute)
+	m.Get("a") // b is now the least recently used
+	m.Set("c", []byte("3"), time.Minute)
+
+	if _, ok, _ := m.Get("b"); ok {
+		t.Errorf("Expected b to be evicted")
+	}
+	for _, key := range []string{"a", "c"} {
+		if _, ok, _ := m.Get(key); !ok {
+			t.Errorf("Expected %s to be kept", key)
+		}
+	}
+	if n := m.evictions.Load(); n != 1 || m.Len() != 2 {
+		t.Errorf("Unexpected evictions %d with %d entries", n, m.Len())
+	}
+}
+
+func TestMemory_CountersAreNotEvicted(t *testing.T) {
+	m := NewMemory(1)
+
+	if n, err := m.Counter("generation"); n != 0 || err != nil {
+		t.Errorf("Unexpected counter before Incr: %d, %v", n, err)
+	}
+	m.Incr("generation")
+	m.Set("a", []byte("1"), time.Minute)
+	m.Set("b", []byte("2"), time.Minute)
+	if n, _ := m.Incr("generation"); n != 2 {
+		t.Errorf("Incr = %d, want 2", n)
+	}
+	if n, _ := m.Counter("generation"); n != 2 {
+		t.Errorf("Counter = %d after filling the cache, want 2", n)
+	}
+}
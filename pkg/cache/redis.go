@@ -0,0 +1,275 @@
+package cache
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	redisPoolSize    = 8
+	redisDialTimeout = 2 * time.Second
+	redisIOTimeout   = time.Second
+)
+
+// Redis is a Backend speaking the Redis protocol (RESP2), usable with Redis,
+// Valkey, KeyDB or any compatible server. Keys are prefixed so several
+// deployments can share a server. It is safe for concurrent use.
+type Redis struct {
+	addr     string
+	password string
+	db       int
+	prefix   string
+
+	conns chan *redisConn
+}
+
+type redisConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+// redisError is an error reply from the server
+type redisError string
+
+func (e redisError) Error() string { return "redis: " + string(e) }
+
+// NewRedis creates a Redis backend for a URL such as
+// redis://:password@host:6379/0. Connections are opened on first use, so an
+// unreachable server surfaces as errors from Get and Set.
+func NewRedis(rawURL, prefix string) (*Redis, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid redis URL: %w", err)
+	}
+	if u.Scheme != "redis" {
+		return nil, fmt.Errorf("invalid redis URL: unsupported scheme %q", u.Scheme)
+	}
+
+	r := &Redis{
+		addr:   u.Host,
+		prefix: prefix,
+		conns:  make(chan *redisConn, redisPoolSize),
+	}
+	if u.Port() == "" {
+		r.addr = net.JoinHostPort(u.Hostname(), "6379")
+	}
+	if password, ok := u.User.Password(); ok {
+		r.password = password
+	}
+	if db := strings.TrimPrefix(u.Path, "/"); db != "" {
+		if r.db, err = strconv.Atoi(db); err != nil {
+			return nil, fmt.Errorf("invalid redis database %q", db)
+		}
+	}
+	return r, nil
+}
+
+// Get returns the value stored under key
+func (r *Redis) Get(key string) ([]byte, bool, error) {
+	reply, err := r.do("GET", r.prefix+key)
+	if err != nil {
+		return nil, false, err
+	}
+	if reply == nil {
+		return nil, false, nil
+	}
+	value, ok := reply.([]byte)
+	if !ok {
+		return nil, false, fmt.Errorf("redis: unexpected GET reply %T", reply)
+	}
+	return value, true, nil
+}
+
+// Set stores value under key for ttl
+func (r *Redis) Set(key string, value []byte, ttl time.Duration) error {
+	args := []string{"SET", r.prefix + key, string(value)}
+	if ms := ttl.Milliseconds(); ms > 0 {
+		args = append(args, "PX", strconv.FormatInt(ms, 10))
+	}
+	_, err := r.do(args...)
+	return err
+}
+
+// Delete removes keys
+func (r *Redis) Delete(keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	args := []string{"DEL"}
+	for _, key := range keys {
+		args = append(args, r.prefix+key)
+	}
+	_, err := r.do(args...)
+	return err
+}
+
+// Incr increments the counter under key with INCR and returns its new
+// value. Counters are stored without a TTL, so servers evicting only keys
+// with one (a volatile-* maxmemory-policy) never evict them.
+func (r *Redis) Incr(key string) (int64, error) {
+	reply, err := r.do("INCR", r.prefix+key)
+	if err != nil {
+		return 0, err
+	}
+	n, ok := reply.(int64)
+	if !ok {
+		return 0, fmt.Errorf("redis: unexpected INCR reply %T", reply)
+	}
+	return n, nil
+}
+
+// Counter returns the value of the counter under key
+func (r *Redis) Counter(key string) (int64, error) {
+	value, ok, err := r.Get(key)
+	if err != nil || !ok {
+		return 0, err
+	}
+	n, err := strconv.ParseInt(string(value), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("redis: %s is not a counter", key)
+	}
+	return n, nil
+}
+
+// Close closes the idle connections
+func (r *Redis) Close() error {
+	for {
+		select {
+		case conn := <-r.conns:
+			conn.Close()
+		default:
+			return nil
+		}
+	}
+}
+
+// do sends a command and reads its reply. Connections that fail are
+// discarded rather than returned to the pool.
+func (r *Redis) do(args ...string) (interface{}, error) {
+	conn, err := r.conn()
+	if err != nil {
+		return nil, err
+	}
+
+	reply, err := conn.roundTrip(args)
+	var replyErr redisError
+	if err != nil && !errors.As(err, &replyErr) {
+		conn.Close()
+		return nil, err
+	}
+
+	select {
+	case r.conns <- conn:
+	default:
+		conn.Close()
+	}
+	return reply, err
+}
+
+// conn takes an idle connection or dials a new one, authenticating and
+// selecting the database
+func (r *Redis) conn() (*redisConn, error) {
+	select {
+	case conn := <-r.conns:
+		return conn, nil
+	default:
+	}
+
+	netConn, err := net.DialTimeout("tcp", r.addr, redisDialTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("redis: %w", err)
+	}
+	conn := &redisConn{Conn: netConn, r: bufio.NewReader(netConn)}
+
+	if r.password != "" {
+		if _, err := conn.roundTrip([]string{"AUTH", r.password}); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	if r.db != 0 {
+		if _, err := conn.roundTrip([]string{"SELECT", strconv.Itoa(r.db)}); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	return conn, nil
+}
+
+func (c *redisConn) roundTrip(args []string) (interface{}, error) {
+	c.SetDeadline(time.Now().Add(redisIOTimeout))
+	if _, err := c.Write(EncodeCommand(args)); err != nil {
+		return nil, fmt.Errorf("redis: %w", err)
+	}
+	return ReadReply(c.r)
+}
+
+// EncodeCommand encodes a command as a RESP array of bulk strings
+func EncodeCommand(args []string) []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(&b, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+	return []byte(b.String())
+}
+
+// ReadReply reads one RESP value: a string for simple strings, []byte for
+// bulk strings, int64 for integers, []interface{} for arrays and nil for
+// null replies. Error replies are returned as errors.
+func ReadReply(r *bufio.Reader) (interface{}, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, fmt.Errorf("redis: %w", err)
+	}
+	line = strings.TrimSuffix(line, "\r\n")
+	if line == "" {
+		return nil, errors.New("redis: empty reply")
+	}
+
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return nil, redisError(line[1:])
+	case ':':
+		return strconv.ParseInt(line[1:], 10, 64)
+	case '$':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, fmt.Errorf("redis: invalid bulk length %q", line)
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		buf := make([]byte, n+2)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, fmt.Errorf("redis: %w", err)
+		}
+		return buf[:n], nil
+	case '*':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, fmt.Errorf("redis: invalid array length %q", line)
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		values := make([]interface{}, n)
+		for i := range values {
+			if values[i], err = ReadReply(r); err != nil {
+				return nil, err
+			}
+		}
+		return values, nil
+	default:
+		return nil, fmt.Errorf("redis: unexpected reply %q", line)
+	}
+}
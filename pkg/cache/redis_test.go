@@ -0,0 +1,146 @@
+package cache_test
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rbungay/racedatabase-api/pkg/cache"
+	"github.com/rbungay/racedatabase-api/pkg/cache/redistest"
+)
+
+func newRedis(t *testing.T, password string) (*cache.Redis, *redistest.Server) {
+	t.Helper()
+	server, err := redistest.NewServer(password)
+	if err != nil {
+		t.Fatalf("Failed to start redis stand-in: %v", err)
+	}
+	t.Cleanup(func() { server.Close() })
+
+	backend, err := cache.NewRedis(server.URL()+"/1", "test:")
+	if err != nil {
+		t.Fatalf("NewRedis failed: %v", err)
+	}
+	t.Cleanup(func() { backend.Close() })
+	return backend, server
+}
+
+func TestRedis_GetSetDelete(t *testing.T) {
+	backend, server := newRedis(t, "secret")
+
+	if _, ok, err := backend.Get("a"); ok || err != nil {
+		t.Fatalf("Expected a miss: %v, %v", ok, err)
+	}
+	if err := backend.Set("a", []byte("hello\r\nworld"), time.Minute); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	value, ok, err := backend.Get("a")
+	if !ok || err != nil || string(value) != "hello\r\nworld" {
+		t.Errorf("Unexpected value: got %q, %v, %v", value, ok, err)
+	}
+
+	keys := server.Keys()
+	if len(keys) != 1 || keys[0] != "test:a" {
+		t.Errorf("Expected prefixed keys, got %v", keys)
+	}
+
+	if err := backend.Delete("a", "missing"); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if _, ok, _ := backend.Get("a"); ok {
+		t.Errorf("Expected a miss after Delete")
+	}
+}
+
+func TestRedis_Counter(t *testing.T) {
+	backend, _ := newRedis(t, "")
+
+	if n, err := backend.Counter("generation"); n != 0 || err != nil {
+		t.Errorf("Unexpected counter before Incr: %d, %v", n, err)
+	}
+	for want := int64(1); want <= 2; want++ {
+		if n, err := backend.Incr("generation"); n != want || err != nil {
+			t.Errorf("Incr = %d, %v; want %d", n, err, want)
+		}
+	}
+	if n, err := backend.Counter("generation"); n != 2 || err != nil {
+		t.Errorf("Counter = %d, %v; want 2", n, err)
+	}
+}
+
+func TestRedis_Expiry(t *testing.T) {
+	backend, _ := newRedis(t, "")
+
+	backend.Set("a", []byte("1"), 20*time.Millisecond)
+	time.Sleep(40 * time.Millisecond)
+	if _, ok, _ := backend.Get("a"); ok {
+		t.Errorf("Expected the entry to have expired")
+	}
+}
+
+func TestRedis_WrongPassword(t *testing.T) {
+	server, err := redistest.NewServer("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+
+	backend, err := cache.NewRedis("redis://:wrong@"+server.URL()[len("redis://:secret@"):], "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := backend.Get("a"); err == nil {
+		t.Errorf("Expected an authentication error")
+	}
+}
+
+func TestRedis_Unavailable(t *testing.T) {
+	backend, server := newRedis(t, "")
+	server.Close()
+
+	c := cache.New(backend, time.Minute)
+	value, err := c.Fetch("a", func() ([]byte, error) { return []byte("fresh"), nil })
+	if err != nil || string(value) != "fresh" {
+		t.Errorf("An unavailable backend should fall through to fetch: got %q, %v", value, err)
+	}
+}
+
+func TestRedis_SharedBetweenCaches(t *testing.T) {
+	backend, server := newRedis(t, "")
+	other, err := cache.NewRedis(server.URL()+"/1", "test:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer other.Close()
+
+	first := cache.New(backend, time.Minute)
+	second := cache.New(other, time.Minute)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			first.Fetch("race:1", func() ([]byte, error) { return []byte("details"), nil })
+		}()
+	}
+	wg.Wait()
+
+	value, ok := second.Get("race:1")
+	if !ok || string(value) != "details" {
+		t.Errorf("Expected the second replica to see the first's entry: got %q, %v", value, ok)
+	}
+
+	second.Delete("race:1")
+	if _, ok := first.Get("race:1"); ok {
+		t.Errorf("Expected the invalidation to be visible to the first replica")
+	}
+}
+
+func TestNewRedis_InvalidURL(t *testing.T) {
+	for _, rawURL := range []string{"http://localhost:6379", "redis://localhost:6379/abc"} {
+		if _, err := cache.NewRedis(rawURL, ""); err == nil {
+			t.Errorf("Expected an error for %s", rawURL)
+		}
+	}
+}
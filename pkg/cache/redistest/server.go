@@ -0,0 +1,195 @@
+// Package redistest runs an in-process stand-in for a Redis server so the
+// Redis cache backend can be tested without one. It implements PING, AUTH,
+// SELECT, GET, SET (with EX and PX), DEL and FLUSHALL.
+package redistest
+
+import (
+	"bufio"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rbungay/racedatabase-api/pkg/cache"
+)
+
+// Server is an in-memory Redis stand-in listening on a local port
+type Server struct {
+	listener net.Listener
+	password string
+
+	mu   sync.Mutex
+	data map[string]item
+	wg   sync.WaitGroup
+}
+
+type item struct {
+	value   string
+	expires time.Time
+}
+
+// NewServer starts a server; clients must AUTH with password when it is set
+func NewServer(password string) (*Server, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Server{listener: listener, password: password, data: make(map[string]item)}
+	s.wg.Add(1)
+	go s.serve()
+	return s, nil
+}
+
+// URL is the redis:// URL clients connect with
+func (s *Server) URL() string {
+	if s.password != "" {
+		return "redis://:" + s.password + "@" + s.listener.Addr().String()
+	}
+	return "redis://" + s.listener.Addr().String()
+}
+
+// Keys returns the unexpired keys, for assertions
+func (s *Server) Keys() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	keys := []string{}
+	for key, it := range s.data {
+		if it.expires.IsZero() || time.Now().Before(it.expires) {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// Close stops the server and waits for its listener to exit
+func (s *Server) Close() error {
+	err := s.listener.Close()
+	s.wg.Wait()
+	return err
+}
+
+func (s *Server) serve() {
+	defer s.wg.Done()
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(conn)
+	}
+}
+
+func (s *Server) handle(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	authed := s.password == ""
+
+	for {
+		reply, err := cache.ReadReply(r)
+		if err != nil {
+			return
+		}
+		values, ok := reply.([]interface{})
+		if !ok || len(values) == 0 {
+			conn.Write([]byte("-ERR protocol error\r\n"))
+			return
+		}
+		args := make([]string, len(values))
+		for i, v := range values {
+			b, _ := v.([]byte)
+			args[i] = string(b)
+		}
+
+		command := strings.ToUpper(args[0])
+		if !authed && command != "AUTH" {
+			conn.Write([]byte("-NOAUTH Authentication required.\r\n"))
+			continue
+		}
+		if command == "AUTH" {
+			if len(args) == 2 && args[1] == s.password {
+				authed = true
+				conn.Write([]byte("+OK\r\n"))
+			} else {
+				conn.Write([]byte("-WRONGPASS invalid password\r\n"))
+			}
+			continue
+		}
+		conn.Write(s.execute(command, args[1:]))
+	}
+}
+
+func (s *Server) execute(command string, args []string) []byte {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch command {
+	case "PING":
+		return []byte("+PONG\r\n")
+	case "SELECT":
+		return []byte("+OK\r\n")
+	case "FLUSHALL":
+		s.data = make(map[string]item)
+		return []byte("+OK\r\n")
+	case "GET":
+		if len(args) != 1 {
+			return wrongArgs(command)
+		}
+		it, ok := s.data[args[0]]
+		if !ok || (!it.expires.IsZero() && time.Now().After(it.expires)) {
+			delete(s.data, args[0])
+			return []byte("$-1\r\n")
+		}
+		return []byte("$" + strconv.Itoa(len(it.value)) + "\r\n" + it.value + "\r\n")
+	case "SET":
+		if len(args) != 2 && len(args) != 4 {
+			return wrongArgs(command)
+		}
+		it := item{value: args[1]}
+		if len(args) == 4 {
+			n, err := strconv.Atoi(args[3])
+			if err != nil || n <= 0 {
+				return []byte("-ERR invalid expire time in 'set' command\r\n")
+			}
+			switch strings.ToUpper(args[2]) {
+			case "EX":
+				it.expires = time.Now().Add(time.Duration(n) * time.Second)
+			case "PX":
+				it.expires = time.Now().Add(time.Duration(n) * time.Millisecond)
+			default:
+				return []byte("-ERR syntax error\r\n")
+			}
+		}
+		s.data[args[0]] = it
+		return []byte("+OK\r\n")
+	case "INCR":
+		if len(args) != 1 {
+			return wrongArgs(command)
+		}
+		it := s.data[args[0]]
+		n, err := strconv.ParseInt(it.value, 10, 64)
+		if it.value != "" && err != nil {
+			return []byte("-ERR value is not an integer or out of range\r\n")
+		}
+		it.value = strconv.FormatInt(n+1, 10)
+		s.data[args[0]] = it
+		return []byte(":" + it.value + "\r\n")
+	case "DEL":
+		deleted := 0
+		for _, key := range args {
+			if _, ok := s.data[key]; ok {
+				delete(s.data, key)
+				deleted++
+			}
+		}
+		return []byte(":" + strconv.Itoa(deleted) + "\r\n")
+	default:
+		return []byte("-ERR unknown command '" + command + "'\r\n")
+	}
+}
+
+func wrongArgs(command string) []byte {
+	return []byte("-ERR wrong number of arguments for '" + strings.ToLower(command) + "' command\r\n")
+}
@@ -0,0 +1,44 @@
+package cache
+
+import "sync"
+
+// call is a fetch in progress or completed
+type call struct {
+	wg    sync.WaitGroup
+	value []byte
+	err   error
+}
+
+// group deduplicates concurrent calls for the same key
+type group struct {
+	mu    sync.Mutex
+	calls map[string]*call
+}
+
+// do runs fn once for all concurrent callers with the same key. shared is
+// true for callers that received another caller's result.
+func (g *group) do(key string, fn func() ([]byte, error)) (value []byte, err error, shared bool) {
+	g.mu.Lock()
+	if g.calls == nil {
+		g.calls = make(map[string]*call)
+	}
+	if c, ok := g.calls[key]; ok {
+		g.mu.Unlock()
+		c.wg.Wait()
+		return c.value, c.err, true
+	}
+
+	c := &call{}
+	c.wg.Add(1)
+	g.calls[key] = c
+	g.mu.Unlock()
+
+	c.value, c.err = fn()
+	c.wg.Done()
+
+	g.mu.Lock()
+	delete(g.calls, key)
+	g.mu.Unlock()
+
+	return c.value, c.err, false
+}
@@ -0,0 +1,49 @@
+package cache
+
+import (
+	"expvar"
+	"sync/atomic"
+)
+
+// Stats counts cache activity
+type Stats struct {
+	hits   atomic.Int64
+	misses atomic.Int64
+	shared atomic.Int64
+	errors atomic.Int64
+}
+
+// Snapshot is a point-in-time copy of a cache's counters. Evictions,
+// Expirations and Entries are only reported for a Memory backend.
+type Snapshot struct {
+	Hits        int64 `json:"hits"`
+	Misses      int64 `json:"misses"`
+	Shared      int64 `json:"shared"`
+	Errors      int64 `json:"errors"`
+	Evictions   int64 `json:"evictions,omitempty"`
+	Expirations int64 `json:"expirations,omitempty"`
+	Entries     int   `json:"entries,omitempty"`
+}
+
+// Stats returns the cache's current counters. Shared counts misses that were
+// served by another caller's in-flight fetch instead of a new one.
+func (c *Cache) Stats() Snapshot {
+	snapshot := Snapshot{
+		Hits:   c.stats.hits.Load(),
+		Misses: c.stats.misses.Load(),
+		Shared: c.stats.shared.Load(),
+		Errors: c.stats.errors.Load(),
+	}
+	if m, ok := c.backend.(*Memory); ok {
+		snapshot.Evictions = m.evictions.Load()
+		snapshot.Expirations = m.expirations.Load()
+		snapshot.Entries = m.Len()
+	}
+	return snapshot
+}
+
+// Publish exposes the cache's counters as the expvar variable name, served
+// at /debug/vars by expvar.Handler. It panics if name is already published.
+func (c *Cache) Publish(name string) {
+	expvar.Publish(name, expvar.Func(func() interface{} { return c.Stats() }))
+}
@@ -1 +1,173 @@
-package errors
\ No newline at end of file
+// Package errors defines the typed errors shared by services and handlers
+// and renders them as RFC 7807 application/problem+json responses.
+//
+// Import it under an alias (apperrors) to avoid clashing with the standard
+// library errors package.
+package errors
+
+import (
+	stderrors "errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// Kind classifies an error by how the API should report it.
+type Kind int
+
+const (
+	// KindInternal is any error without a more specific kind.
+	KindInternal Kind = iota
+	KindNotFound
+	KindInvalidArgument
+	KindMethodNotAllowed
+	KindUpstreamUnavailable
+	KindRateLimited
+	KindTimeout
+	KindUnauthenticated
+	KindConflict
+	KindUnavailable
+)
+
+// codes are the machine-readable problem codes for each kind
+var codes = map[Kind]string{
+	KindInternal:            "internal",
+	KindNotFound:            "not_found",
+	KindInvalidArgument:     "invalid_argument",
+	KindMethodNotAllowed:    "method_not_allowed",
+	KindUpstreamUnavailable: "upstream_unavailable",
+	KindRateLimited:         "rate_limited",
+	KindTimeout:             "timeout",
+	KindUnauthenticated:     "unauthenticated",
+	KindConflict:            "conflict",
+	KindUnavailable:         "unavailable",
+}
+
+// statuses are the HTTP statuses for each kind
+var statuses = map[Kind]int{
+	KindInternal:            http.StatusInternalServerError,
+	KindNotFound:            http.StatusNotFound,
+	KindInvalidArgument:     http.StatusBadRequest,
+	KindMethodNotAllowed:    http.StatusMethodNotAllowed,
+	KindUpstreamUnavailable: http.StatusBadGateway,
+	KindRateLimited:         http.StatusTooManyRequests,
+	KindTimeout:             http.StatusGatewayTimeout,
+	KindUnauthenticated:     http.StatusUnauthorized,
+	KindConflict:            http.StatusConflict,
+	KindUnavailable:         http.StatusServiceUnavailable,
+}
+
+func (k Kind) String() string {
+	return codes[k]
+}
+
+// FieldError is a problem with a single request parameter.
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// Error is an error with a Kind and a message that is safe to show clients.
+// The underlying cause is kept for logs but never sent in responses.
+type Error struct {
+	Kind    Kind
+	Message string
+	Fields  []FieldError
+
+	// RetryAfter is how long a rate limited client should wait, if known.
+	RetryAfter time.Duration
+
+	Err error
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("%s: %v", e.Message, e.Err)
+	}
+	return e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Wrap returns an error of kind with a client-safe message and a cause.
+func Wrap(kind Kind, err error, message string) *Error {
+	return &Error{Kind: kind, Message: message, Err: err}
+}
+
+// NotFound reports a missing resource.
+func NotFound(format string, args ...interface{}) *Error {
+	return &Error{Kind: KindNotFound, Message: fmt.Sprintf(format, args...)}
+}
+
+// InvalidArgument reports a bad request parameter.
+func InvalidArgument(format string, args ...interface{}) *Error {
+	return &Error{Kind: KindInvalidArgument, Message: fmt.Sprintf(format, args...)}
+}
+
+// InvalidFields reports several bad request parameters at once.
+func InvalidFields(fields []FieldError) *Error {
+	return &Error{
+		Kind:    KindInvalidArgument,
+		Message: "One or more request parameters are invalid.",
+		Fields:  fields,
+	}
+}
+
+// MethodNotAllowed reports a request with an unsupported HTTP method.
+func MethodNotAllowed(method string) *Error {
+	return &Error{Kind: KindMethodNotAllowed, Message: fmt.Sprintf("Method %s is not allowed.", method)}
+}
+
+// UpstreamUnavailable reports a failed or unusable response from RunSignup.
+func UpstreamUnavailable(err error) *Error {
+	return &Error{Kind: KindUpstreamUnavailable, Message: "RunSignup is unavailable, please try again later.", Err: err}
+}
+
+// RateLimited reports that RunSignup or this API is throttling requests.
+func RateLimited(err error, retryAfter time.Duration) *Error {
+	return &Error{Kind: KindRateLimited, Message: "Too many requests, please slow down.", Err: err, RetryAfter: retryAfter}
+}
+
+// QuotaExceeded reports that a client has used up its requests for the
+// day, until retryAfter.
+func QuotaExceeded(retryAfter time.Duration) *Error {
+	return &Error{Kind: KindRateLimited, Message: "Daily request quota exceeded.", RetryAfter: retryAfter}
+}
+
+// Timeout reports that an upstream call did not finish in time.
+func Timeout(err error) *Error {
+	return &Error{Kind: KindTimeout, Message: "RunSignup did not respond in time.", Err: err}
+}
+
+// Unauthenticated reports a request without valid credentials.
+func Unauthenticated(message string) *Error {
+	return &Error{Kind: KindUnauthenticated, Message: message}
+}
+
+// Conflict reports a request that conflicts with the resource's state.
+func Conflict(format string, args ...interface{}) *Error {
+	return &Error{Kind: KindConflict, Message: fmt.Sprintf(format, args...)}
+}
+
+// Unavailable reports that this API cannot serve the request right now,
+// such as while it shuts down.
+func Unavailable(message string) *Error {
+	return &Error{Kind: KindUnavailable, Message: message}
+}
+
+// KindOf returns the kind of the first *Error in err's chain, or
+// KindInternal when there is none.
+func KindOf(err error) Kind {
+	var e *Error
+	if stderrors.As(err, &e) {
+		return e.Kind
+	}
+	return KindInternal
+}
+
+// HTTPStatus returns the HTTP status code for err.
+func HTTPStatus(err error) int {
+	return statuses[KindOf(err)]
+}
@@ -0,0 +1,97 @@
+package errors
+
+import (
+	"encoding/json"
+	stderrors "errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHTTPStatus(t *testing.T) {
+	cause := stderrors.New("API error: status 500 - response: <html>")
+	for _, tc := range []struct {
+		err  error
+		want int
+	}{
+		{NotFound("race %d not found", 1), http.StatusNotFound},
+		{InvalidArgument("bad"), http.StatusBadRequest},
+		{UpstreamUnavailable(cause), http.StatusBadGateway},
+		{RateLimited(cause, 0), http.StatusTooManyRequests},
+		{QuotaExceeded(time.Hour), http.StatusTooManyRequests},
+		{Timeout(cause), http.StatusGatewayTimeout},
+		{Unauthenticated("no key"), http.StatusUnauthorized},
+		{Conflict("job %d finished", 1), http.StatusConflict},
+		{Unavailable("shutting down"), http.StatusServiceUnavailable},
+		{fmt.Errorf("wrapped: %w", NotFound("gone")), http.StatusNotFound},
+		{cause, http.StatusInternalServerError},
+	} {
+		if got := HTTPStatus(tc.err); got != tc.want {
+			t.Errorf("HTTPStatus(%v) = %d, want %d", tc.err, got, tc.want)
+		}
+	}
+}
+
+func TestWriteProblem_HidesCause(t *testing.T) {
+	cause := stderrors.New("API error: status 500 - response: secret upstream body")
+	req := httptest.NewRequest("GET", "/runsignup/events", nil)
+	rr := httptest.NewRecorder()
+
+	WriteProblem(rr, req, UpstreamUnavailable(cause))
+
+	if rr.Code != http.StatusBadGateway {
+		t.Errorf("Unexpected status: got %d, want %d", rr.Code, http.StatusBadGateway)
+	}
+	if ct := rr.Header().Get("Content-Type"); ct != ProblemContentType {
+		t.Errorf("Unexpected content type: %q", ct)
+	}
+	if strings.Contains(rr.Body.String(), "secret upstream body") {
+		t.Errorf("Problem body leaked the upstream response: %s", rr.Body.String())
+	}
+
+	var problem Problem
+	if err := json.Unmarshal(rr.Body.Bytes(), &problem); err != nil {
+		t.Fatalf("Failed to parse problem: %v", err)
+	}
+	if problem.Code != "upstream_unavailable" || problem.Instance != "/runsignup/events" || problem.Title != "Bad Gateway" {
+		t.Errorf("Unexpected problem: %+v", problem)
+	}
+}
+
+func TestWriteProblem_InvalidFieldsAndRetryAfter(t *testing.T) {
+	req := httptest.NewRequest("GET", "/runsignup/events", nil)
+
+	rr := httptest.NewRecorder()
+	WriteProblem(rr, req, InvalidFields([]FieldError{{Field: "state", Message: "is required"}}))
+
+	var problem Problem
+	if err := json.Unmarshal(rr.Body.Bytes(), &problem); err != nil {
+		t.Fatalf("Failed to parse problem: %v", err)
+	}
+	if len(problem.Errors) != 1 || problem.Errors[0].Field != "state" {
+		t.Errorf("Expected field errors in problem, got %+v", problem)
+	}
+
+	rr = httptest.NewRecorder()
+	WriteProblem(rr, req, RateLimited(nil, 30*time.Second))
+	if got := rr.Header().Get("Retry-After"); got != "30" {
+		t.Errorf("Unexpected Retry-After: %q", got)
+	}
+}
+
+func TestWriteProblem_UntypedErrorIsGeneric(t *testing.T) {
+	req := httptest.NewRequest("GET", "/races", nil)
+	rr := httptest.NewRecorder()
+
+	WriteProblem(rr, req, stderrors.New("pq: password authentication failed"))
+
+	if strings.Contains(rr.Body.String(), "password") {
+		t.Errorf("Problem body leaked an internal error: %s", rr.Body.String())
+	}
+	if rr.Code != http.StatusInternalServerError {
+		t.Errorf("Unexpected status: got %d, want %d", rr.Code, http.StatusInternalServerError)
+	}
+}
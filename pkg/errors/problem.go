@@ -0,0 +1,74 @@
+package errors
+
+import (
+	"encoding/json"
+	stderrors "errors"
+	"log"
+	"net/http"
+	"strconv"
+)
+
+// ProblemContentType is the media type of RFC 7807 responses.
+const ProblemContentType = "application/problem+json"
+
+// Problem is an RFC 7807 problem details body. Code and Errors are
+// extension members.
+type Problem struct {
+	Type     string       `json:"type"`
+	Title    string       `json:"title"`
+	Status   int          `json:"status"`
+	Detail   string       `json:"detail,omitempty"`
+	Instance string       `json:"instance,omitempty"`
+	Code     string       `json:"code"`
+	Errors   []FieldError `json:"errors,omitempty"`
+}
+
+// NewProblem builds the problem details for err. Only client-safe messages
+// are included; anything else is reported as a generic internal error.
+func NewProblem(r *http.Request, err error) Problem {
+	kind := KindOf(err)
+	status := statuses[kind]
+
+	problem := Problem{
+		Type:     "about:blank",
+		Title:    http.StatusText(status),
+		Status:   status,
+		Detail:   PublicMessage(err),
+		Instance: r.URL.Path,
+		Code:     kind.String(),
+	}
+
+	var e *Error
+	if stderrors.As(err, &e) {
+		problem.Errors = e.Fields
+	}
+	return problem
+}
+
+// PublicMessage returns the client-safe message for err, or a generic one
+// when err carries none.
+func PublicMessage(err error) string {
+	var e *Error
+	if stderrors.As(err, &e) {
+		return e.Message
+	}
+	return "An unexpected error occurred."
+}
+
+// WriteProblem writes err as an application/problem+json response. Server
+// side failures are logged with their full cause.
+func WriteProblem(w http.ResponseWriter, r *http.Request, err error) {
+	problem := NewProblem(r, err)
+	if problem.Status >= http.StatusInternalServerError {
+		log.Printf("%s %s: %v", r.Method, r.URL.Path, err)
+	}
+
+	var e *Error
+	if stderrors.As(err, &e) && e.RetryAfter > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(int(e.RetryAfter.Seconds()+0.5)))
+	}
+
+	w.Header().Set("Content-Type", ProblemContentType)
+	w.WriteHeader(problem.Status)
+	json.NewEncoder(w).Encode(problem)
+}
@@ -0,0 +1,95 @@
+//go:build ignore
+
+// gen_zipcodes downloads the Census Bureau ZCTA Gazetteer file and rewrites
+// zcta_centroids.csv with the internal point of every ZIP Code Tabulation Area.
+// Given the path of an already downloaded gazetteer zip, it reads that instead:
+//
+//	go run gen_zipcodes.go 2023_Gaz_zcta_national.zip
+package main
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+)
+
+const gazetteerURL = "https://www2.census.gov/geo/docs/maps-data/data/gazetteer/2023_Gazetteer/2023_Gaz_zcta_national.zip"
+
+// minZCTAs guards against writing a truncated file; the 2023 gazetteer has
+// 33,791 ZCTAs
+const minZCTAs = 33000
+
+func main() {
+	var body []byte
+	var err error
+	if len(os.Args) > 1 {
+		body, err = os.ReadFile(os.Args[1])
+	} else {
+		body, err = download(gazetteerURL)
+	}
+	if err != nil {
+		log.Fatalf("Failed to read gazetteer: %v", err)
+	}
+
+	archive, err := zip.NewReader(bytes.NewReader(body), int64(len(body)))
+	if err != nil || len(archive.File) == 0 {
+		log.Fatalf("Failed to open gazetteer archive: %v", err)
+	}
+	file, err := archive.File[0].Open()
+	if err != nil {
+		log.Fatalf("Failed to open %s: %v", archive.File[0].Name, err)
+	}
+	defer file.Close()
+
+	// Tab separated: GEOID ALAND AWATER ALAND_SQMI AWATER_SQMI INTPTLAT INTPTLONG
+	var rows [][]string
+	scanner := bufio.NewScanner(file)
+	for first := true; scanner.Scan(); first = false {
+		fields := strings.Fields(scanner.Text())
+		if first || len(fields) < 7 {
+			continue
+		}
+		rows = append(rows, []string{fields[0], fields[5], fields[6]})
+	}
+	if err := scanner.Err(); err != nil {
+		log.Fatalf("Failed to parse gazetteer: %v", err)
+	}
+	if len(rows) < minZCTAs {
+		log.Fatalf("Gazetteer only has %d ZCTAs, expected at least %d", len(rows), minZCTAs)
+	}
+	sort.Slice(rows, func(i, j int) bool { return rows[i][0] < rows[j][0] })
+
+	out, err := os.Create("zcta_centroids.csv")
+	if err != nil {
+		log.Fatalf("Failed to create zcta_centroids.csv: %v", err)
+	}
+	defer out.Close()
+
+	w := csv.NewWriter(out)
+	w.Write([]string{"zip", "lat", "lng"})
+	w.WriteAll(rows)
+	if err := w.Error(); err != nil {
+		log.Fatalf("Failed to write zcta_centroids.csv: %v", err)
+	}
+	fmt.Printf("Wrote %d ZIP centroids\n", len(rows))
+}
+
+func download(url string) ([]byte, error) {
+	resp, err := http.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("status %d", resp.StatusCode)
+	}
+	return io.ReadAll(resp.Body)
+}
@@ -0,0 +1,52 @@
+// Package geo provides great-circle distance calculations and US ZIP code
+// centroid lookups for proximity search.
+package geo
+
+import "math"
+
+// EarthRadiusMiles is the mean radius of the Earth
+const EarthRadiusMiles = 3958.8
+
+// Point is a latitude/longitude pair in decimal degrees.
+type Point struct {
+	Lat float64
+	Lng float64
+}
+
+// Valid reports whether the point lies within the latitude/longitude range
+func (p Point) Valid() bool {
+	return p.Lat >= -90 && p.Lat <= 90 && p.Lng >= -180 && p.Lng <= 180
+}
+
+// DistanceMiles returns the haversine distance between two points in miles.
+func DistanceMiles(a, b Point) float64 {
+	lat1 := radians(a.Lat)
+	lat2 := radians(b.Lat)
+	dLat := radians(b.Lat - a.Lat)
+	dLng := radians(b.Lng - a.Lng)
+
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(lat1)*math.Cos(lat2)*math.Sin(dLng/2)*math.Sin(dLng/2)
+	return 2 * EarthRadiusMiles * math.Asin(math.Min(1, math.Sqrt(h)))
+}
+
+// BoundingBox returns the corners of a box that contains every point within
+// radiusMiles of center, for cheap pre-filtering before DistanceMiles.
+func BoundingBox(center Point, radiusMiles float64) (min, max Point) {
+	dLat := degrees(radiusMiles / EarthRadiusMiles)
+
+	// Longitude degrees shrink toward the poles; near them the box spans
+	// every longitude.
+	dLng := 180.0
+	if cosLat := math.Cos(radians(center.Lat)); cosLat > 1e-6 {
+		dLng = math.Min(180, dLat/cosLat)
+	}
+
+	min = Point{Lat: math.Max(-90, center.Lat-dLat), Lng: math.Max(-180, center.Lng-dLng)}
+	max = Point{Lat: math.Min(90, center.Lat+dLat), Lng: math.Min(180, center.Lng+dLng)}
+	return min, max
+}
+
+func radians(deg float64) float64 { return deg * math.Pi / 180 }
+
+func degrees(rad float64) float64 { return rad * 180 / math.Pi }
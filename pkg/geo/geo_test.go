@@ -0,0 +1,61 @@
+package geo
+
+import (
+	"math"
+	"testing"
+)
+
+func TestDistanceMiles(t *testing.T) {
+	newYork := Point{Lat: 40.7506, Lng: -73.9972}
+	philadelphia := Point{Lat: 39.9524, Lng: -75.1742}
+
+	got := DistanceMiles(newYork, philadelphia)
+	if math.Abs(got-82) > 2 {
+		t.Errorf("Unexpected distance New York to Philadelphia: got %.1f, want about 82", got)
+	}
+	if d := DistanceMiles(newYork, newYork); d != 0 {
+		t.Errorf("Expected zero distance to self, got %v", d)
+	}
+}
+
+func TestBoundingBox_ContainsRadius(t *testing.T) {
+	center := Point{Lat: 40.7506, Lng: -73.9972}
+	min, max := BoundingBox(center, 25)
+
+	// Points just under 25 miles due north and due east must be inside the box
+	north := Point{Lat: center.Lat + 24.9/69.1, Lng: center.Lng}
+	east := Point{Lat: center.Lat, Lng: center.Lng + 24.9/(69.1*math.Cos(radians(center.Lat)))}
+	for _, p := range []Point{north, east} {
+		if d := DistanceMiles(center, p); d > 25 {
+			t.Fatalf("Test point %+v is %.2f miles away", p, d)
+		}
+		if p.Lat < min.Lat || p.Lat > max.Lat || p.Lng < min.Lng || p.Lng > max.Lng {
+			t.Errorf("Point %+v outside bounding box %+v - %+v", p, min, max)
+		}
+	}
+}
+
+func TestLookupZIP(t *testing.T) {
+	p, ok := LookupZIP("07302-1234")
+	if !ok {
+		t.Fatalf("Expected 07302 to be found")
+	}
+	if math.Abs(p.Lat-40.7178) > 0.01 || math.Abs(p.Lng+74.0466) > 0.01 {
+		t.Errorf("Unexpected centroid for 07302: %+v", p)
+	}
+
+	if _, ok := LookupZIP("00000"); ok {
+		t.Errorf("Expected 00000 to be unknown")
+	}
+}
+
+func TestZCTACentroids_Complete(t *testing.T) {
+	centroids, err := parseCentroids(zctaCentroids)
+	if err != nil {
+		t.Fatalf("Failed to parse ZIP centroids: %v", err)
+	}
+	// The 2023 Census gazetteer has 33,791 ZCTAs
+	if len(centroids) < 33000 {
+		t.Errorf("zcta_centroids.csv has %d ZIPs, want every ZCTA; run go generate ./pkg/geo", len(centroids))
+	}
+}
@@ -0,0 +1,72 @@
+package geo
+
+//go:generate go run gen_zipcodes.go
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/csv"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// zctaCentroids holds "zip,lat,lng" rows for US ZIP Code Tabulation Areas.
+// Regenerate it from the Census Gazetteer with `go generate ./pkg/geo`.
+//
+//go:embed zcta_centroids.csv
+var zctaCentroids []byte
+
+var (
+	zipOnce      sync.Once
+	zipCentroids map[string]Point
+	zipLoadErr   error
+)
+
+// LookupZIP returns the centroid of a 5-digit US ZIP code.
+func LookupZIP(zip string) (Point, bool) {
+	zipOnce.Do(func() {
+		zipCentroids, zipLoadErr = parseCentroids(zctaCentroids)
+	})
+	if zipLoadErr != nil {
+		return Point{}, false
+	}
+
+	zip = strings.TrimSpace(zip)
+	if len(zip) > 5 {
+		// ZIP+4 codes share the centroid of their 5-digit ZIP
+		zip = zip[:5]
+	}
+	p, ok := zipCentroids[zip]
+	return p, ok
+}
+
+// parseCentroids reads the embedded "zip,lat,lng" dataset
+func parseCentroids(data []byte) (map[string]Point, error) {
+	records, err := csv.NewReader(bytes.NewReader(data)).ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read ZIP centroids: %w", err)
+	}
+
+	centroids := make(map[string]Point, len(records))
+	for i, record := range records {
+		if i == 0 && record[0] == "zip" {
+			continue
+		}
+		if len(record) != 3 {
+			return nil, fmt.Errorf("ZIP centroids line %d: expected 3 fields, got %d", i+1, len(record))
+		}
+		lat, err := strconv.ParseFloat(record[1], 64)
+		if err != nil {
+			return nil, fmt.Errorf("ZIP centroids line %d: %w", i+1, err)
+		}
+		lng, err := strconv.ParseFloat(record[2], 64)
+		if err != nil {
+			return nil, fmt.Errorf("ZIP centroids line %d: %w", i+1, err)
+		}
+		centroids[record[0]] = Point{Lat: lat, Lng: lng}
+	}
+
+	return centroids, nil
+}
@@ -0,0 +1,14 @@
+-- Fee observations per registration period. A row is written the first time a
+-- period is seen and again whenever its fee changes between syncs.
+CREATE TABLE IF NOT EXISTS price_history (
+    id BIGSERIAL PRIMARY KEY,
+    event_id BIGINT NOT NULL REFERENCES events (event_id) ON DELETE CASCADE,
+    opens_at TIMESTAMPTZ,
+    closes_at TIMESTAMPTZ,
+    race_fee NUMERIC(10, 2) NOT NULL,
+    processing_fee NUMERIC(10, 2) NOT NULL,
+    recorded_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS price_history_period_idx
+    ON price_history (event_id, opens_at, closes_at, recorded_at DESC);
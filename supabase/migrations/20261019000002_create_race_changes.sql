@@ -0,0 +1,10 @@
+-- Structured diffs between the stored and freshly ingested version of a race.
+CREATE TABLE IF NOT EXISTS race_changes (
+    id BIGSERIAL PRIMARY KEY,
+    race_id BIGINT NOT NULL REFERENCES races (id) ON DELETE CASCADE,
+    changes JSONB NOT NULL,
+    detected_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS race_changes_race_idx
+    ON race_changes (race_id, detected_at DESC);
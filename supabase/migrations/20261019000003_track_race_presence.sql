@@ -0,0 +1,10 @@
+-- Track when each race was last returned by a RunSignup sync so races removed
+-- upstream can be flagged and soft-deleted.
+ALTER TABLE races
+    ADD COLUMN IF NOT EXISTS state TEXT,
+    ADD COLUMN IF NOT EXISTS last_seen_at TIMESTAMPTZ,
+    ADD COLUMN IF NOT EXISTS missed_syncs INTEGER NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS possibly_removed BOOLEAN NOT NULL DEFAULT FALSE,
+    ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMPTZ;
+
+CREATE INDEX IF NOT EXISTS races_state_idx ON races (state) WHERE deleted_at IS NULL;
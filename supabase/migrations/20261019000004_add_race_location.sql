@@ -0,0 +1,14 @@
+-- Search summary fields persisted on ingest so location queries can be
+-- answered without calling RunSignup.
+ALTER TABLE races
+    ADD COLUMN IF NOT EXISTS category TEXT,
+    ADD COLUMN IF NOT EXISTS address TEXT,
+    ADD COLUMN IF NOT EXISTS city TEXT,
+    ADD COLUMN IF NOT EXISTS postal_code TEXT,
+    ADD COLUMN IF NOT EXISTS latitude DOUBLE PRECISION,
+    ADD COLUMN IF NOT EXISTS longitude DOUBLE PRECISION,
+    ADD COLUMN IF NOT EXISTS next_date DATE;
+
+CREATE INDEX IF NOT EXISTS races_city_idx ON races (state, city);
+CREATE INDEX IF NOT EXISTS races_postal_code_idx ON races (postal_code);
+CREATE INDEX IF NOT EXISTS races_next_date_idx ON races (next_date);
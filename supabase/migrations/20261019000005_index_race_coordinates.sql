@@ -0,0 +1,4 @@
+-- Supports the bounding-box pre-filter used by proximity search.
+CREATE INDEX IF NOT EXISTS races_coordinates_idx
+    ON races (latitude, longitude)
+    WHERE deleted_at IS NULL;
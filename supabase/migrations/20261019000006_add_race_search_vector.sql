@@ -0,0 +1,11 @@
+-- Full-text search over race name (weight A), event names (B) and city (C).
+-- SaveRace refreshes the vector after writing a race's events.
+ALTER TABLE races ADD COLUMN IF NOT EXISTS search_vector TSVECTOR;
+
+UPDATE races SET search_vector =
+    setweight(to_tsvector('english', coalesce(name, '')), 'A') ||
+    setweight(to_tsvector('english', coalesce(
+        (SELECT string_agg(e.name, ' ') FROM events e WHERE e.race_id = races.id), '')), 'B') ||
+    setweight(to_tsvector('english', coalesce(city, '')), 'C');
+
+CREATE INDEX IF NOT EXISTS races_search_vector_idx ON races USING GIN (search_vector);
@@ -0,0 +1,12 @@
+-- One row per scheduled sync job with the outcome of its last run.
+-- running_since doubles as a claim so only one process runs a job at a time;
+-- a claim left behind by a crashed process expires after the stale timeout.
+CREATE TABLE IF NOT EXISTS sync_runs (
+    job TEXT PRIMARY KEY,
+    running_since TIMESTAMPTZ,
+    last_started_at TIMESTAMPTZ,
+    last_finished_at TIMESTAMPTZ,
+    last_status TEXT,
+    last_error TEXT,
+    last_synced INTEGER NOT NULL DEFAULT 0
+);
@@ -0,0 +1,8 @@
+-- Per-state high-water mark for incremental syncs. Incremental syncs only ask
+-- RunSignup for races modified since synced_through; a full sync runs when
+-- last_full_sync_at is older than SYNC_FULL_EVERY.
+CREATE TABLE IF NOT EXISTS sync_watermarks (
+    state TEXT PRIMARY KEY,
+    synced_through TIMESTAMPTZ NOT NULL,
+    last_full_sync_at TIMESTAMPTZ
+);
@@ -0,0 +1,32 @@
+-- Sync jobs and their per-state, per-event-type checkpoints. A checkpoint is
+-- saved after every page of races, so an interrupted job resumes from the
+-- first page it had not finished.
+CREATE TABLE IF NOT EXISTS sync_jobs (
+    id BIGSERIAL PRIMARY KEY,
+    status TEXT NOT NULL DEFAULT 'pending',
+    mode TEXT NOT NULL,
+    states TEXT[] NOT NULL,
+    event_types TEXT[] NOT NULL,
+    races_fetched INTEGER NOT NULL DEFAULT 0,
+    races_saved INTEGER NOT NULL DEFAULT 0,
+    races_failed INTEGER NOT NULL DEFAULT 0,
+    error TEXT,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    started_at TIMESTAMPTZ,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    finished_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS sync_jobs_status_idx ON sync_jobs (status, created_at DESC);
+
+CREATE TABLE IF NOT EXISTS sync_job_checkpoints (
+    job_id BIGINT NOT NULL REFERENCES sync_jobs (id) ON DELETE CASCADE,
+    state TEXT NOT NULL,
+    event_type TEXT NOT NULL,
+    -- NULL for a full sync of the state
+    modified_since TIMESTAMPTZ,
+    next_page INTEGER NOT NULL DEFAULT 1,
+    done BOOLEAN NOT NULL DEFAULT FALSE,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (job_id, state, event_type)
+);
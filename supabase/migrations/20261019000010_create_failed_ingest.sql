@@ -0,0 +1,15 @@
+-- Races whose details could not be fetched from RunSignup or saved. Retries
+-- back off exponentially through next_retry_at; a row is deleted once the
+-- race is stored. summary is the search result the race was found in.
+CREATE TABLE IF NOT EXISTS failed_ingest (
+    race_id BIGINT PRIMARY KEY,
+    stage TEXT NOT NULL,
+    error TEXT NOT NULL,
+    attempts INTEGER NOT NULL DEFAULT 1,
+    summary JSONB NOT NULL,
+    first_failed_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    last_failed_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    next_retry_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS failed_ingest_next_retry_idx ON failed_ingest (next_retry_at);
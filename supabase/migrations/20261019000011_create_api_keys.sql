@@ -0,0 +1,23 @@
+-- Keys issued to the API's consumers. Only a SHA-256 hash of each key is
+-- stored; prefix is its first characters, kept so admins can tell keys
+-- apart. rate_limit is requests per minute and daily_quota requests per UTC
+-- day, 0 meaning unlimited.
+CREATE TABLE IF NOT EXISTS api_keys (
+    id BIGSERIAL PRIMARY KEY,
+    name TEXT NOT NULL,
+    prefix TEXT NOT NULL,
+    key_hash BYTEA NOT NULL UNIQUE,
+    rate_limit INTEGER NOT NULL,
+    daily_quota INTEGER NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    revoked_at TIMESTAMPTZ
+);
+
+-- Requests made with each key per UTC day
+CREATE TABLE IF NOT EXISTS api_key_usage (
+    key_id BIGINT NOT NULL REFERENCES api_keys (id) ON DELETE CASCADE,
+    day DATE NOT NULL,
+    requests BIGINT NOT NULL DEFAULT 0,
+    last_used_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (key_id, day)
+);
@@ -0,0 +1,21 @@
+-- Each event's distance in miles, parsed from its distance label when the
+-- event is saved, so race facets can bucket distances in SQL. Existing rows
+-- are backfilled here with the same parsing rules.
+ALTER TABLE events ADD COLUMN IF NOT EXISTS distance_miles DOUBLE PRECISION;
+
+UPDATE events e SET distance_miles = CASE
+        WHEN parsed.label LIKE '%half marathon%' THEN 13.1
+        WHEN parsed.label LIKE '%marathon%' THEN 26.2
+        WHEN parsed.m[2] IN ('k', 'km', 'kilometer', 'kilometers') THEN parsed.m[1]::DOUBLE PRECISION * 0.621371
+        WHEN parsed.m[2] IN ('m', 'meter', 'meters') THEN parsed.m[1]::DOUBLE PRECISION / 1609.344
+        WHEN parsed.m[2] IN ('y', 'yard', 'yards') THEN parsed.m[1]::DOUBLE PRECISION / 1760
+        ELSE parsed.m[1]::DOUBLE PRECISION
+    END
+FROM (
+    SELECT event_id, lower(trim(distance)) AS label,
+        regexp_match(lower(trim(distance)), '^([\d.]+)\s*(kilometers?|km|k|meters?|miles?|mi|m|yards?|y)?\y') AS m
+    FROM events
+) parsed
+WHERE parsed.event_id = e.event_id
+  AND e.distance_miles IS NULL
+  AND (parsed.label LIKE '%marathon%' OR parsed.m[1] ~ '^(\d+\.?\d*|\.\d+)$');
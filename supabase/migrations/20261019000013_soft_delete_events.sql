@@ -0,0 +1,6 @@
+-- Events removed upstream are soft-deleted instead of deleted, so the fee
+-- history kept for them is not erased through price_history's ON DELETE
+-- CASCADE. An event that reappears is restored by the next save.
+ALTER TABLE events ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMPTZ;
+
+CREATE INDEX IF NOT EXISTS events_live_race_id_idx ON events (race_id) WHERE deleted_at IS NULL;